@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+	"github.com/cyrusbiotechnology/argo/workflow/util"
+	"github.com/spf13/cobra"
+)
+
+func NewApproveCommand() *cobra.Command {
+	return newApprovalCommand(wfv1.ApprovalDecisionApprove, "approve", "approved")
+}
+
+// newApprovalCommand returns a command recording the decision of the kubeconfig user on an approval node.
+// The name of the user is declared by the client, it is not authenticated.
+func newApprovalCommand(decision wfv1.ApprovalDecision, verb string, pastVerb string) *cobra.Command {
+	var (
+		approvalOpts util.ApprovalOpts
+	)
+	var command = &cobra.Command{
+		Use:   fmt.Sprintf("%s WORKFLOW", verb),
+		Short: fmt.Sprintf("%s a node awaiting approval", verb),
+		Long: fmt.Sprintf(`%s a node awaiting approval.
+
+The decision is recorded under the name of the user of the current kubeconfig context, or of the
+user impersonated with --as. This name is not authenticated: anyone allowed to update the workflow
+can record a decision under any name, so the users allowed by the approval template are advisory.
+Access to approvals is governed by the RBAC permission to update workflows.`, strings.Title(verb)),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 1 {
+				cmd.HelpFunc()(cmd, args)
+				os.Exit(1)
+			}
+			InitWorkflowClient()
+			approvalOpts.Approver = getKubeconfigUser()
+			approvalOpts.Decision = decision
+			wfName := args[0]
+			err := util.DecideWorkflowApproval(wfClient, wfName, approvalOpts)
+			if err != nil {
+				log.Fatalf("Failed to %s %s: %+v", verb, wfName, err)
+			}
+			if approvalOpts.NodeName != "" {
+				fmt.Printf("workflow %s node %s %s as %s\n", wfName, approvalOpts.NodeName, pastVerb, approvalOpts.Approver)
+			} else {
+				fmt.Printf("workflow %s %s as %s\n", wfName, pastVerb, approvalOpts.Approver)
+			}
+		},
+	}
+	command.Flags().StringVar(&approvalOpts.NodeName, "node", "", fmt.Sprintf("%s the approval node with this name or ID, required if several nodes await approval", verb))
+	command.Flags().StringVar(&approvalOpts.Comment, "comment", "", "comment recorded with the decision")
+	return command
+}
+
+// getKubeconfigUser returns the name of the user of the current kubeconfig context. The user
+// impersonated by the kubeconfig or the --as flag takes precedence. It is read from the local
+// configuration, not verified against the API server.
+func getKubeconfigUser() string {
+	config, err := clientConfig.RawConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	contextName := config.CurrentContext
+	if configOverrides.CurrentContext != "" {
+		contextName = configOverrides.CurrentContext
+	}
+	var userName string
+	if kubeContext, ok := config.Contexts[contextName]; ok {
+		userName = kubeContext.AuthInfo
+	}
+	if configOverrides.Context.AuthInfo != "" {
+		userName = configOverrides.Context.AuthInfo
+	}
+	if authInfo, ok := config.AuthInfos[userName]; ok && authInfo.Impersonate != "" {
+		userName = authInfo.Impersonate
+	}
+	if configOverrides.AuthInfo.Impersonate != "" {
+		userName = configOverrides.AuthInfo.Impersonate
+	}
+	if userName == "" {
+		log.Fatal("Failed to determine the user of the kubeconfig")
+	}
+	return userName
+}
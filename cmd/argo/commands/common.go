@@ -21,6 +21,7 @@ import (
 var (
 	restConfig       *rest.Config
 	clientConfig     clientcmd.ClientConfig
+	configOverrides  clientcmd.ConfigOverrides
 	clientset        *kubernetes.Clientset
 	wfClientset      *versioned.Clientset
 	wfClient         v1alpha1.WorkflowInterface
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/cyrusbiotechnology/argo/workflow/util"
 	"github.com/argoproj/pkg/humanize"
 	"github.com/spf13/cobra"
+	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 )
@@ -163,7 +165,7 @@ func printWorkflowHelper(wf *wfv1.Workflow, getArgs getFlags) {
 		fmt.Println()
 		// apply a dummy FgDefault format to align tabwriter with the rest of the columns
 		if getArgs.output == "wide" {
-			fmt.Fprintf(w, "%s\tPODNAME\tDURATION\tARTIFACTS\tMESSAGE\n", ansiFormat("STEP", FgDefault))
+			fmt.Fprintf(w, "%s\tPODNAME\tDURATION\tARTIFACTS\tHOST\tRESOURCES\tSCHEDULING\tEXIT CODE\tMESSAGE\n", ansiFormat("STEP", FgDefault))
 		} else {
 			fmt.Fprintf(w, "%s\tPODNAME\tDURATION\tMESSAGE\n", ansiFormat("STEP", FgDefault))
 		}
@@ -191,10 +193,14 @@ type nodeInfoInterface interface {
 	getID() string
 	getNodeStatus(wf *wfv1.Workflow) wfv1.NodeStatus
 	getStartTime(wf *wfv1.Workflow) metav1.Time
+	getExitHook() renderNode
+	setExitHook(hook renderNode)
 }
 
 type nodeInfo struct {
 	id string
+	// exitHook is the exit hook of a step or task, which is rendered beneath it
+	exitHook renderNode
 }
 
 func (n *nodeInfo) getID() string {
@@ -209,6 +215,14 @@ func (n *nodeInfo) getStartTime(wf *wfv1.Workflow) metav1.Time {
 	return wf.Status.Nodes[n.id].StartedAt
 }
 
+func (n *nodeInfo) getExitHook() renderNode {
+	return n.exitHook
+}
+
+func (n *nodeInfo) setExitHook(hook renderNode) {
+	n.exitHook = hook
+}
+
 // Interface to represent Nodes in render form types
 type renderNode interface {
 	// Render this renderNode and its children
@@ -245,6 +259,28 @@ func isExecutionNode(node wfv1.NodeType) bool {
 	return (node == wfv1.NodeTypePod) || (node == wfv1.NodeTypeSkipped) || (node == wfv1.NodeTypeSuspend)
 }
 
+// exitHookParentID returns the ID of the step or task the node is the exit hook of, if it is one.
+// The exit handler of the workflow is not the exit hook of a step or task.
+func exitHookParentID(wf *wfv1.Workflow, node wfv1.NodeStatus) (string, bool) {
+	suffix := "." + onExitSuffix
+	if !strings.HasSuffix(node.Name, suffix) || node.Name == wf.ObjectMeta.Name+suffix {
+		return "", false
+	}
+	parentID := wf.NodeID(strings.TrimSuffix(node.Name, suffix))
+	if _, ok := wf.Status.Nodes[parentID]; !ok {
+		return "", false
+	}
+	return parentID, true
+}
+
+// withExitHook appends the exit hook of the node, if any, to the children to render
+func withExitHook(n nodeInfoInterface, children []renderNode) []renderNode {
+	if n.getExitHook() == nil {
+		return children
+	}
+	return append(children[:len(children):len(children)], n.getExitHook())
+}
+
 func insertSorted(wf *wfv1.Workflow, sortedArray []renderNode, item renderNode) []renderNode {
 	insertTime := item.getStartTime(wf)
 	var index int
@@ -321,6 +357,26 @@ func convertToRenderTrees(wf *wfv1.Workflow) map[string]renderNode {
 	// Maps non Boundary render Children name -> *nonBoundaryParentNode
 	nonBoundaryParentChildrenMap := make(map[string]*nonBoundaryParentNode)
 
+	// Used to store all render nodes so exit hooks can attach to their step or task
+	// Maps node ID -> renderNode
+	renderNodeMap := make(map[string]renderNode)
+	// Used to store the exit hooks of steps and tasks, which are rendered beneath them rather than
+	// in their boundary
+	// Maps step or task node ID -> exit hook renderNode
+	exitHookMap := make(map[string]renderNode)
+
+	// attach attaches render node n to its parent, or keeps it aside if it is an exit hook.
+	// Returns if it is a possible root
+	attach := func(n renderNode, status wfv1.NodeStatus) bool {
+		renderNodeMap[n.getID()] = n
+		if parentID, ok := exitHookParentID(wf, status); ok {
+			exitHookMap[parentID] = n
+			return false
+		}
+		return attachToParent(wf, n, nonBoundaryParentChildrenMap,
+			status.BoundaryID, boundaryNodeMap, parentBoundaryMap)
+	}
+
 	// We have to do a 2 pass approach because anything that is a child
 	// of a nonBoundaryParent and also has a boundaryID we may not know which
 	// parent to attach to if we didn't see the nonBoundaryParent earlier
@@ -349,8 +405,7 @@ func convertToRenderTrees(wf *wfv1.Workflow) map[string]renderNode {
 			n := boundaryNode{nodeInfo: nodeInfo{id: id}}
 			boundaryNodeMap[id] = &n
 			// Attach to my parent if needed
-			if attachToParent(wf, &n, nonBoundaryParentChildrenMap,
-				status.BoundaryID, boundaryNodeMap, parentBoundaryMap) {
+			if attach(&n, status) {
 				renderTreeRoots[n.getID()] = &n
 			}
 			// Attach nodes who are in my boundary already seen before me to me
@@ -364,8 +419,7 @@ func convertToRenderTrees(wf *wfv1.Workflow) map[string]renderNode {
 				return nil
 			}
 			// Attach to my parent if needed
-			if attachToParent(wf, nPtr, nonBoundaryParentChildrenMap,
-				status.BoundaryID, boundaryNodeMap, parentBoundaryMap) {
+			if attach(nPtr, status) {
 				renderTreeRoots[nPtr.getID()] = nPtr
 			}
 			// All children attach directly to the nonBoundaryParents since they are already created
@@ -373,14 +427,22 @@ func convertToRenderTrees(wf *wfv1.Workflow) map[string]renderNode {
 		case isExecutionNode(status.Type):
 			n := executionNode{nodeInfo: nodeInfo{id: id}}
 			// Attach to my parent if needed
-			if attachToParent(wf, &n, nonBoundaryParentChildrenMap,
-				status.BoundaryID, boundaryNodeMap, parentBoundaryMap) {
+			if attach(&n, status) {
 				renderTreeRoots[n.getID()] = &n
 			}
 			// Execution nodes don't have other render nodes as children
 		}
 	}
 
+	// Attach the exit hooks to their step or task
+	for parentID, hook := range exitHookMap {
+		if parent, ok := renderNodeMap[parentID]; ok {
+			parent.setExitHook(hook)
+		} else {
+			renderTreeRoots[hook.getID()] = hook
+		}
+	}
+
 	return renderTreeRoots
 }
 
@@ -453,18 +515,22 @@ func printNode(w *tabwriter.Writer, wf *wfv1.Workflow, node wfv1.NodeStatus, dep
 	} else if node.TemplateName != "" {
 		nodeName = fmt.Sprintf("%s (%s)", nodeName, node.TemplateName)
 	}
+	message := node.Message
+	if node.InfrastructureFailure != "" {
+		message = fmt.Sprintf("%s (infrastructure failure: %s)", message, node.InfrastructureFailure)
+	}
 	var args []interface{}
 	duration := humanize.RelativeDurationShort(node.StartedAt.Time, node.FinishedAt.Time)
 	if node.Type == wfv1.NodeTypePod {
-		args = []interface{}{nodePrefix, nodeName, node.ID, duration, node.Message}
+		args = []interface{}{nodePrefix, nodeName, node.ID, duration, message}
 	} else {
-		args = []interface{}{nodePrefix, nodeName, "", "", node.Message}
+		args = []interface{}{nodePrefix, nodeName, "", "", message}
 	}
 	if getArgs.output == "wide" {
 		msg := args[len(args)-1]
 		args[len(args)-1] = getArtifactsString(node)
-		args = append(args, msg)
-		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\n", args...)
+		args = append(args, node.HostNodeName, getResourcesString(node), getSchedulingLatencyString(node), getExitCodeString(node), msg)
+		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", args...)
 	} else {
 		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\n", args...)
 	}
@@ -478,9 +544,10 @@ func (nodeInfo *boundaryNode) renderNodes(w *tabwriter.Writer, wf *wfv1.Workflow
 		printNode(w, wf, nodeInfo.getNodeStatus(wf), depth, nodePrefix, childPrefix, getArgs)
 	}
 
-	for i, nInfo := range nodeInfo.boundaryContained {
+	children := withExitHook(nodeInfo, nodeInfo.boundaryContained)
+	for i, nInfo := range children {
 		renderChild(w, wf, nInfo, depth, nodePrefix, childPrefix, filtered, i,
-			len(nodeInfo.boundaryContained)-1, childIndent, getArgs)
+			len(children)-1, childIndent, getArgs)
 	}
 }
 
@@ -491,9 +558,18 @@ func (nodeInfo *nonBoundaryParentNode) renderNodes(w *tabwriter.Writer, wf *wfv1
 		printNode(w, wf, nodeInfo.getNodeStatus(wf), depth, nodePrefix, childPrefix, getArgs)
 	}
 
-	for i, nInfo := range nodeInfo.children {
+	children := nodeInfo.children
+	if !filtered {
+		children = withExitHook(nodeInfo, children)
+	}
+	for i, nInfo := range children {
 		renderChild(w, wf, nInfo, depth, nodePrefix, childPrefix, filtered, i,
-			len(nodeInfo.children)-1, childIndent, getArgs)
+			len(children)-1, childIndent, getArgs)
+	}
+	// The exit hook of a retried step is rendered beneath its only attempt when the retry node is
+	// filtered
+	if hook := nodeInfo.getExitHook(); hook != nil && filtered {
+		renderChild(w, wf, hook, depth, nodePrefix, childPrefix, false, 0, 0, false, getArgs)
 	}
 }
 
@@ -503,6 +579,10 @@ func (nodeInfo *executionNode) renderNodes(w *tabwriter.Writer, wf *wfv1.Workflo
 	if !filtered {
 		printNode(w, wf, nodeInfo.getNodeStatus(wf), depth, nodePrefix, childPrefix, getArgs)
 	}
+	// Execution nodes don't have other render nodes as children, except for their exit hook
+	if hook := nodeInfo.getExitHook(); hook != nil {
+		renderChild(w, wf, hook, depth, nodePrefix, childPrefix, false, 0, 0, false, getArgs)
+	}
 }
 
 func getArtifactsString(node wfv1.NodeStatus) string {
@@ -515,3 +595,42 @@ func getArtifactsString(node wfv1.NodeStatus) string {
 	}
 	return strings.Join(artNames, ",")
 }
+
+// getResourcesString returns the resource requests of a node, followed by its limits
+func getResourcesString(node wfv1.NodeStatus) string {
+	if node.Resources == nil {
+		return ""
+	}
+	formatList := func(list apiv1.ResourceList) string {
+		var names []string
+		for name := range list {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+		resources := []string{}
+		for _, name := range names {
+			quantity := list[apiv1.ResourceName(name)]
+			resources = append(resources, fmt.Sprintf("%s=%s", name, quantity.String()))
+		}
+		return strings.Join(resources, ",")
+	}
+	resources := formatList(node.Resources.Requests)
+	if len(node.Resources.Limits) > 0 {
+		resources = fmt.Sprintf("%s (limits: %s)", resources, formatList(node.Resources.Limits))
+	}
+	return strings.TrimSpace(resources)
+}
+
+func getSchedulingLatencyString(node wfv1.NodeStatus) string {
+	if node.SchedulingLatency == nil {
+		return ""
+	}
+	return node.SchedulingLatency.Duration.String()
+}
+
+func getExitCodeString(node wfv1.NodeStatus) string {
+	if node.ExitCode == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d", *node.ExitCode)
+}
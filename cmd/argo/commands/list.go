@@ -256,6 +256,9 @@ func workflowStatus(wf *wfv1.Workflow) wfv1.NodePhase {
 		if util.IsWorkflowTerminated(wf) {
 			return "Failed (Terminated)"
 		}
+		if util.IsWorkflowStopped(wf) {
+			return "Failed (Stopped)"
+		}
 		return wf.Status.Phase
 	case "", wfv1.NodePending:
 		if !wf.ObjectMeta.CreationTimestamp.IsZero() {
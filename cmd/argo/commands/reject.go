@@ -0,0 +1,10 @@
+package commands
+
+import (
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+	"github.com/spf13/cobra"
+)
+
+func NewRejectCommand() *cobra.Command {
+	return newApprovalCommand(wfv1.ApprovalDecisionReject, "reject", "rejected")
+}
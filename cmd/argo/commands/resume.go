@@ -10,6 +10,9 @@ import (
 )
 
 func NewResumeCommand() *cobra.Command {
+	var (
+		resumeOpts util.ResumeOpts
+	)
 	var command = &cobra.Command{
 		Use:   "resume WORKFLOW1 WORKFLOW2...",
 		Short: "resume a workflow",
@@ -20,13 +23,19 @@ func NewResumeCommand() *cobra.Command {
 			}
 			InitWorkflowClient()
 			for _, wfName := range args {
-				err := util.ResumeWorkflow(wfClient, wfName)
+				err := util.ResumeWorkflowNodes(wfClient, wfName, resumeOpts)
 				if err != nil {
 					log.Fatalf("Failed to resume %s: %+v", wfName, err)
 				}
-				fmt.Printf("workflow %s resumed\n", wfName)
+				if resumeOpts.NodeName != "" {
+					fmt.Printf("workflow %s node %s resumed\n", wfName, resumeOpts.NodeName)
+				} else {
+					fmt.Printf("workflow %s resumed\n", wfName)
+				}
 			}
 		},
 	}
+	command.Flags().StringVar(&resumeOpts.NodeName, "node", "", "resume only the suspended node with this name or ID")
+	command.Flags().StringArrayVarP(&resumeOpts.Parameters, "parameter", "p", []string{}, "supply an output parameter of the resumed nodes (NAME=VALUE)")
 	return command
 }
@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/argoproj/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/cyrusbiotechnology/argo/workflow/util"
+)
+
+func NewStopCommand() *cobra.Command {
+	var (
+		gracePeriod time.Duration
+	)
+	var command = &cobra.Command{
+		Use:   "stop WORKFLOW WORKFLOW2...",
+		Short: "stop a workflow, letting running steps complete and running the exit handler",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				cmd.HelpFunc()(cmd, args)
+				os.Exit(1)
+			}
+			var gracePeriodPtr *time.Duration
+			if cmd.Flags().Changed("grace-period") {
+				gracePeriodPtr = &gracePeriod
+			}
+			InitWorkflowClient()
+			for _, name := range args {
+				err := util.StopWorkflow(wfClient, name, gracePeriodPtr)
+				errors.CheckError(err)
+				fmt.Printf("Workflow '%s' stopped\n", name)
+			}
+		},
+	}
+	command.Flags().DurationVar(&gracePeriod, "grace-period", 0, "Time given to running steps to complete before they are sent SIGTERM (e.g. 30s, 5m). Running steps are left to complete if omitted")
+	return command
+}
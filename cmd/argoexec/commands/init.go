@@ -24,6 +24,7 @@ func loadArtifacts() error {
 	wfExecutor := initExecutor()
 	defer wfExecutor.HandleError()
 	defer stats.LogStats()
+	defer wfExecutor.FlushTraces()
 
 	// Download input artifacts
 	err := wfExecutor.StageFiles()
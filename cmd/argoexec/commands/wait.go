@@ -27,6 +27,7 @@ func waitContainer() error {
 	wfExecutor := initExecutor()
 	defer wfExecutor.HandleError()
 	defer stats.LogStats()
+	defer wfExecutor.FlushTraces()
 	stats.StartStatsTicker(5 * time.Minute)
 
 	defer func() {
@@ -4,11 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"hash/fnv"
+	"strconv"
 	"strings"
 
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // TemplateType is the type of a template
@@ -22,6 +24,7 @@ const (
 	TemplateTypeResource  TemplateType = "Resource"
 	TemplateTypeDAG       TemplateType = "DAG"
 	TemplateTypeSuspend   TemplateType = "Suspend"
+	TemplateTypeApproval  TemplateType = "Approval"
 	TemplateTypeUnknown   TemplateType = "Unknown"
 )
 
@@ -53,6 +56,17 @@ const (
 	NodeTypeSuspend   NodeType = "Suspend"
 )
 
+// InfrastructureFailureReason is the reason of a node failure caused by the infrastructure
+type InfrastructureFailureReason string
+
+// Infrastructure failure reasons
+const (
+	InfrastructureFailureEvicted    InfrastructureFailureReason = "Evicted"
+	InfrastructureFailureNodeLost   InfrastructureFailureReason = "NodeLost"
+	InfrastructureFailurePreempted  InfrastructureFailureReason = "Preempted"
+	InfrastructureFailurePodDeleted InfrastructureFailureReason = "PodDeleted"
+)
+
 // PodGCStrategy is the strategy when to delete completed pods for GC.
 type PodGCStrategy string
 
@@ -64,6 +78,17 @@ const (
 	PodGCOnWorkflowSuccess    PodGCStrategy = "OnWorkflowSuccess"
 )
 
+// ShutdownStrategy is the strategy used to shutdown a running workflow
+type ShutdownStrategy string
+
+// ShutdownStrategy
+const (
+	// ShutdownStrategyStop stops scheduling new nodes, lets the running nodes complete and runs the exit handler
+	ShutdownStrategyStop ShutdownStrategy = "Stop"
+	// ShutdownStrategyTerminate kills the running pods and does not schedule any new node
+	ShutdownStrategyTerminate ShutdownStrategy = "Terminate"
+)
+
 // TemplateGetter is an interface to get templates.
 type TemplateGetter interface {
 	GetNamespace() string
@@ -208,6 +233,14 @@ type WorkflowSpec struct {
 	// terminate a Running workflow
 	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
 
+	// Shutdown shuts down the running workflow according to the strategy: Stop or Terminate
+	Shutdown ShutdownStrategy `json:"shutdown,omitempty"`
+
+	// ShutdownGracePeriodSeconds is the time given to the running pods of a stopped workflow to
+	// complete, after which their containers are sent SIGTERM. Running pods are left to complete
+	// if omitted. Pods of the exit handler are not affected.
+	ShutdownGracePeriodSeconds *int64 `json:"shutdownGracePeriodSeconds,omitempty"`
+
 	// Priority is used if controller is configured to process limited number of workflows in parallel. Workflows with higher priority are processed first.
 	Priority *int32 `json:"priority,omitempty"`
 
@@ -297,6 +330,9 @@ type Template struct {
 	// Suspend template subtype which can suspend a workflow when reaching the step
 	Suspend *SuspendTemplate `json:"suspend,omitempty"`
 
+	// Approval template subtype which suspends a workflow until the step is approved or rejected
+	Approval *ApprovalTemplate `json:"approval,omitempty"`
+
 	// Volumes is a list of volumes that can be mounted by containers in a template.
 	// +patchStrategy=merge
 	// +patchMergeKey=name
@@ -324,9 +360,19 @@ type Template struct {
 	// This field is only applicable to container and script templates.
 	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
 
+	// Timeout is the duration in seconds relative to the start of the node that the template may
+	// run, after which the controller fails the node and the nodes it started, and kills their pods.
+	// Unlike activeDeadlineSeconds, it applies to every template type. A timed out node is retried
+	// according to the retry strategy.
+	Timeout *int64 `json:"timeout,omitempty"`
+
 	// RetryStrategy describes how to retry a template when it fails
 	RetryStrategy *RetryStrategy `json:"retryStrategy,omitempty"`
 
+	// PendingPolicy fails the node when its pod is pending for too long, or for a fatal reason.
+	// Overrides the pending policy of the controller.
+	PendingPolicy *PendingPolicy `json:"pendingPolicy,omitempty"`
+
 	// Parallelism limits the max total parallel pods that can execute at the same time within the
 	// boundaries of this template invocation. If additional steps/dag templates are invoked, the
 	// pods created by those templates will not be counted towards this total.
@@ -443,8 +489,69 @@ type Parameter struct {
 	// GlobalName exports an output parameter to the global scope, making it available as
 	// '{{workflow.outputs.parameters.XXXX}} and in workflow.status.outputs.parameters
 	GlobalName string `json:"globalName,omitempty"`
+
+	// Enum is the list of the values allowed for an input parameter
+	Enum []string `json:"enum,omitempty"`
+
+	// Pattern is a regular expression which the value of an input parameter must match
+	Pattern string `json:"pattern,omitempty"`
+
+	// Type is the type of the value of an input parameter: string (default), int, number, bool or json
+	Type ParameterType `json:"type,omitempty"`
+
+	// Schema is a JSON schema which the value of an input parameter must conform to. The value is
+	// decoded according to the type of the parameter, which defaults to json when a schema is given.
+	Schema *ParameterSchema `json:"schema,omitempty"`
+}
+
+// ParameterType is the type of the value of an input parameter
+type ParameterType string
+
+// ParameterType values
+const (
+	ParameterTypeString ParameterType = "string"
+	ParameterTypeInt    ParameterType = "int"
+	ParameterTypeNumber ParameterType = "number"
+	ParameterTypeBool   ParameterType = "bool"
+	ParameterTypeJSON   ParameterType = "json"
+)
+
+// ParameterSchema is the JSON schema of an input parameter
+type ParameterSchema struct {
+	Value interface{} `json:"value,omitempty"`
 }
 
+// DeepCopyInto is an custom deepcopy function to deal with our use of the interface{} type
+func (s *ParameterSchema) DeepCopyInto(out *ParameterSchema) {
+	inBytes, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	err = json.Unmarshal(inBytes, out)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// UnmarshalJSON implements the json.Unmarshaller interface.
+func (s *ParameterSchema) UnmarshalJSON(value []byte) error {
+	return json.Unmarshal(value, &s.Value)
+}
+
+// MarshalJSON implements the json.Marshaller interface.
+func (s ParameterSchema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Value)
+}
+
+// OpenAPISchemaType is used by the kube-openapi generator when constructing
+// the OpenAPI spec of this type.
+// See: https://github.com/kubernetes/kube-openapi/tree/master/pkg/generators
+func (s ParameterSchema) OpenAPISchemaType() []string { return []string{"object"} }
+
+// OpenAPISchemaFormat is used by the kube-openapi generator when constructing
+// the OpenAPI spec of this type.
+func (s ParameterSchema) OpenAPISchemaFormat() string { return "" }
+
 // ValueFrom describes a location in which to obtain the value to a parameter
 type ValueFrom struct {
 	// Path in the container to retrieve an output parameter value from in container templates
@@ -537,6 +644,9 @@ type ArtifactLocation struct {
 
 	// GCS contains GCS artifact location details
 	GCS *GCSArtifact `json:"gcs,omitempty"`
+
+	// Aggregate contains the artifacts produced by the items of a loop
+	Aggregate *AggregateArtifact `json:"aggregate,omitempty"`
 }
 
 type ArtifactRepositoryRef struct {
@@ -584,12 +694,41 @@ type WorkflowStep struct {
 	// WithSequence expands a step into a numeric sequence
 	WithSequence *Sequence `json:"withSequence,omitempty"`
 
+	// WithMatrix expands a step into multiple parallel steps from every combination of the values
+	// of the axes, referenced as {{item.<axis>}}
+	WithMatrix []MatrixAxis `json:"withMatrix,omitempty"`
+
+	// WithArtifact expands a step into multiple parallel steps from the items stored in an
+	// artifact of a previous step
+	WithArtifact *LoopArtifact `json:"withArtifact,omitempty"`
+
+	// BatchSize groups the items of a loop into batches of up to BatchSize items, each expanded
+	// into a step which receives the items of its batch as a JSON list in {{item}}, and the
+	// indexes of its first and last items in {{batch.start}} and {{batch.end}}
+	BatchSize int32 `json:"batchSize,omitempty"`
+
 	// When is an expression in which the step should conditionally execute
 	When string `json:"when,omitempty"`
 
 	// ContinueOn makes argo to proceed with the following step even if this step fails.
 	// Errors and Failed states can be specified
 	ContinueOn *ContinueOn `json:"continueOn,omitempty"`
+
+	// FailureTolerance is the number, or the percentage, of the items expanded from withItems,
+	// withParam, withSequence, withMatrix or withArtifact which are allowed to fail without failing
+	// the step group
+	FailureTolerance *intstr.IntOrString `json:"failureTolerance,omitempty"`
+
+	// Race makes the steps of the step group race each other: the first step to succeed makes the
+	// step group succeed, and the other steps are terminated and skipped. The outputs of the step
+	// group, available as {{steps.<name>.outputs.*}} for every step of the group, are those of the
+	// winner. To be set on all the steps of the group
+	Race bool `json:"race,omitempty"`
+
+	// OnExit is a template reference which is invoked after the step completes, irrespective of
+	// its success, failure, or error. The phase of the step is available as {{status}} and its
+	// outputs as {{outputs.result}} and {{outputs.parameters.<name>}}
+	OnExit string `json:"onExit,omitempty"`
 }
 
 var _ TemplateHolder = &WorkflowStep{}
@@ -606,6 +745,12 @@ func (step *WorkflowStep) IsResolvable() bool {
 	return true
 }
 
+// IsLoop returns whether the step is expanded into items by withItems, withParam, withSequence,
+// withMatrix or withArtifact
+func (step *WorkflowStep) IsLoop() bool {
+	return len(step.WithItems) > 0 || step.WithParam != "" || step.WithSequence != nil || len(step.WithMatrix) > 0 || step.WithArtifact != nil
+}
+
 // Item expands a single workflow step into multiple parallel steps
 // The value of Item can be a map, string, bool, or number
 type Item struct {
@@ -627,6 +772,43 @@ type Sequence struct {
 	Format string `json:"format,omitempty"`
 }
 
+// MatrixAxis is a named axis of a withMatrix loop. Its values are either the items of a list, or
+// the items of a JSON list.
+type MatrixAxis struct {
+	// Name of the axis
+	Name string `json:"name"`
+
+	// Items are the values of the axis
+	Items []Item `json:"items,omitempty"`
+
+	// Param is a JSON list holding the values of the axis, e.g. {{steps.generate.outputs.result}}
+	Param string `json:"param,omitempty"`
+}
+
+// LoopArtifactFormat is the format of the items stored in the artifact of a withArtifact loop
+type LoopArtifactFormat string
+
+// LoopArtifactFormat values
+const (
+	// LoopArtifactFormatJSON is a JSON list of items
+	LoopArtifactFormatJSON LoopArtifactFormat = "json"
+	// LoopArtifactFormatLines is an item per line. The lines holding a JSON object are parsed
+	// into a map, the others are strings. The empty lines are ignored.
+	LoopArtifactFormatLines LoopArtifactFormat = "lines"
+)
+
+// LoopArtifact is the artifact holding the items of a withArtifact loop. The items are loaded
+// from the artifact repository by the controller, so the artifact is not limited in size like
+// the parameters, although the number of items is.
+type LoopArtifact struct {
+	// From is the reference to an output artifact of a previous step or task,
+	// e.g. {{steps.generate.outputs.artifacts.samples}}
+	From string `json:"from"`
+
+	// Format of the artifact (default: json)
+	Format LoopArtifactFormat `json:"format,omitempty"`
+}
+
 // DeepCopyInto is an custom deepcopy function to deal with our use of the interface{} type
 func (i *Item) DeepCopyInto(out *Item) {
 	inBytes, err := json.Marshal(i)
@@ -720,6 +902,14 @@ type WorkflowStatus struct {
 	// Nodes is a mapping between a node ID and the node's status.
 	Nodes map[string]NodeStatus `json:"nodes,omitempty"`
 
+	// LoopItems caches the items loaded from the artifacts of the withArtifact loops, as
+	// compressed and base64 encoded JSON lists, keyed by the name of the node of the loop
+	LoopItems map[string]string `json:"loopItems,omitempty"`
+
+	// DynamicDAGTasks records the tasks generated at runtime for the DAGs with tasksFrom, keyed by
+	// the name of the node of the DAG
+	DynamicDAGTasks map[string][]DAGTask `json:"dynamicDAGTasks,omitempty"`
+
 	// StoredTemplates is a mapping between a template ref and the node's status.
 	StoredTemplates map[string]Template `json:"storedTemplates,omitempty"`
 
@@ -738,6 +928,74 @@ type WorkflowStatus struct {
 type RetryStrategy struct {
 	// Limit is the maximum number of attempts when retrying a container
 	Limit *int32 `json:"limit,omitempty"`
+
+	// InfrastructureLimit is the maximum number of attempts when retrying a container whose pod
+	// failed because of the infrastructure (e.g. evicted, preempted or lost with its node). These
+	// attempts are not counted against Limit. If omitted, the default of the controller is used, or
+	// these failures are counted against Limit if the controller has no default
+	InfrastructureLimit *int32 `json:"infrastructureLimit,omitempty"`
+
+	// ResourceEscalation increases the resources of the retries of a container which ran out of
+	// memory or CPU
+	ResourceEscalation *ResourceEscalation `json:"resourceEscalation,omitempty"`
+}
+
+// PendingPolicy fails the nodes whose pod is pending for too long, or for a reason which will not
+// resolve by itself. The pod is deleted and the node failed, and retried according to its retry strategy.
+type PendingPolicy struct {
+	// MaxDuration is the maximum duration in seconds for which the pod of a node can be pending
+	MaxDuration *int32 `json:"maxDuration,omitempty"`
+
+	// FailureReasons are the reasons which fail the node as soon as its pod is pending because of them.
+	// They are matched against the waiting reasons of the containers of the pod (e.g. ImagePullBackOff,
+	// ErrImagePull, CreateContainerConfigError) and its scheduling reason (Unschedulable).
+	FailureReasons []string `json:"failureReasons,omitempty"`
+}
+
+// Merge returns the pending policy overriding the fields of the policy with the ones set in override
+func (p *PendingPolicy) Merge(override *PendingPolicy) *PendingPolicy {
+	if p == nil {
+		return override
+	}
+	merged := p.DeepCopy()
+	if override != nil {
+		if override.MaxDuration != nil {
+			merged.MaxDuration = override.MaxDuration
+		}
+		if override.FailureReasons != nil {
+			merged.FailureReasons = override.FailureReasons
+		}
+	}
+	return merged
+}
+
+// DefaultResourceEscalationFactor is the default factor of a resource escalation
+const DefaultResourceEscalationFactor = "2"
+
+// ResourceEscalation increases the resources of the main container at each retry following a failure
+// caused by the exhaustion of a resource. The memory is escalated when the container was OOMKilled.
+// The CPU is only escalated when opted in with CPUOnDeadlineExceeded.
+type ResourceEscalation struct {
+	// Factor multiplies the requests and limits of the exhausted resource at each retry, e.g. "1.5"
+	// (default: "2")
+	Factor string `json:"factor,omitempty"`
+
+	// Max caps the escalated requests and limits of the resources
+	Max apiv1.ResourceList `json:"max,omitempty"`
+
+	// CPUOnDeadlineExceeded escalates the CPU when the pod exceeded its activeDeadlineSeconds. A pod
+	// also exceeds its deadline for reasons unrelated to its CPU, such as a slow dependency, so this
+	// is only meant for containers known to be throttled when they run out of time.
+	CPUOnDeadlineExceeded bool `json:"cpuOnDeadlineExceeded,omitempty"`
+}
+
+// GetFactor returns the factor of the resource escalation
+func (r *ResourceEscalation) GetFactor() (float64, error) {
+	factor := r.Factor
+	if factor == "" {
+		factor = DefaultResourceEscalationFactor
+	}
+	return strconv.ParseFloat(factor, 64)
 }
 
 // NodeStatus contains status information about an individual node in the workflow
@@ -784,6 +1042,14 @@ type NodeStatus struct {
 	// A human readable message indicating details about why the node is in this condition.
 	Message string `json:"message,omitempty"`
 
+	// InfrastructureFailure classifies the failure of the node when it was caused by the
+	// infrastructure running its pod, rather than by the node itself
+	InfrastructureFailure InfrastructureFailureReason `json:"infrastructureFailure,omitempty"`
+
+	// InfrastructureRetries is the number of times the pod of the node was recreated after failing
+	// because of the infrastructure
+	InfrastructureRetries int32 `json:"infrastructureRetries,omitempty"`
+
 	// Time at which this node started
 	StartedAt metav1.Time `json:"startedAt,omitempty"`
 
@@ -796,6 +1062,29 @@ type NodeStatus struct {
 	// Daemoned tracks whether or not this node was daemoned and need to be terminated
 	Daemoned *bool `json:"daemoned,omitempty"`
 
+	// Resources are the effective resource requirements of the main container of pod nodes
+	Resources *apiv1.ResourceRequirements `json:"resources,omitempty"`
+
+	// ExitCode is the exit code of the main container of pod nodes, or of the last attempt of retry nodes
+	ExitCode *int32 `json:"exitCode,omitempty"`
+
+	// ErrorConditions are the names of the error conditions of the template raised by the pod of pod
+	// nodes, or by the last attempt of retry nodes
+	ErrorConditions []string `json:"errorConditions,omitempty"`
+
+	// HostNodeName is the name of the Kubernetes node the pod of the node ran on
+	HostNodeName string `json:"hostNodeName,omitempty"`
+
+	// SchedulingLatency is the duration between the creation of the pod of the node and its scheduling
+	SchedulingLatency *metav1.Duration `json:"schedulingLatency,omitempty"`
+
+	// ExhaustedResources are the resources which the main container of pod nodes ran out of
+	ExhaustedResources []apiv1.ResourceName `json:"exhaustedResources,omitempty"`
+
+	// TimedOut is set on the nodes failed because the node, or the node which started them, exceeded
+	// the timeout of its template
+	TimedOut bool `json:"timedOut,omitempty"`
+
 	// Inputs captures input parameter values and artifact locations supplied to this template invocation
 	Inputs *Inputs `json:"inputs,omitempty"`
 
@@ -818,6 +1107,9 @@ type NodeStatus struct {
 	// a DAG/steps template invokes another DAG/steps template. In other words, the outbound nodes of
 	// a template, will be a superset of the outbound nodes of its last children.
 	OutboundNodes []string `json:"outboundNodes,omitempty"`
+
+	// Approval captures the approval policy and the decisions made for approval nodes
+	Approval *ApprovalStatus `json:"approval,omitempty"`
 }
 
 func (n NodeStatus) String() string {
@@ -1075,6 +1367,19 @@ func (r *RawArtifact) HasLocation() bool {
 	return r != nil
 }
 
+// AggregateArtifact is the manifest of the artifacts produced by the items of a loop, referenced as
+// the output artifact of the loop, e.g. {{steps.process.outputs.artifacts.result}}. It is loaded as
+// a directory with an entry per index of the loop.
+type AggregateArtifact struct {
+	// Artifacts are the artifacts of the items of the loop, named after their index in the loop.
+	// The items which did not produce the artifact are omitted.
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+func (a *AggregateArtifact) HasLocation() bool {
+	return a != nil
+}
+
 // HTTPArtifact allows an file served on HTTP to be placed as an input artifact in a container
 type HTTPArtifact struct {
 	// URL of the artifact
@@ -1161,6 +1466,9 @@ func (tmpl *Template) GetType() TemplateType {
 	if tmpl.Suspend != nil {
 		return TemplateTypeSuspend
 	}
+	if tmpl.Approval != nil {
+		return TemplateTypeApproval
+	}
 	return TemplateTypeUnknown
 }
 
@@ -1192,6 +1500,12 @@ type DAGTemplate struct {
 	// +patchMergeKey=name
 	Tasks []DAGTask `json:"tasks" patchStrategy:"merge" patchMergeKey:"name"`
 
+	// TasksFrom generates the tasks of the DAG at runtime, from a JSON list of tasks held by an input
+	// parameter or an input artifact of the template, i.e. {{inputs.parameters.<name>}} or
+	// {{inputs.artifacts.<name>}}. The tasks are validated like the static tasks, and recorded in
+	// the workflow status. Not to be used with Tasks
+	TasksFrom string `json:"tasksFrom,omitempty"`
+
 	// This flag is for DAG logic. The DAG logic has a built-in "fail fast" feature to stop scheduling new steps,
 	// as soon as it detects that one of the DAG nodes is failed. Then it waits until all DAG nodes are completed
 	// before failing the DAG itself.
@@ -1218,6 +1532,11 @@ type DAGTask struct {
 	// Dependencies are name of other targets which this depends on
 	Dependencies []string `json:"dependencies,omitempty"`
 
+	// Depends is an expression on the results of other tasks which this depends on, as an
+	// alternative to Dependencies, e.g. `A.Succeeded || (B.Failed && !C.Skipped)`. A bare task
+	// name holds when the task would satisfy Dependencies.
+	Depends string `json:"depends,omitempty"`
+
 	// WithItems expands a task into multiple parallel tasks from the items in the list
 	WithItems []Item `json:"withItems,omitempty"`
 
@@ -1228,12 +1547,41 @@ type DAGTask struct {
 	// WithSequence expands a task into a numeric sequence
 	WithSequence *Sequence `json:"withSequence,omitempty"`
 
+	// WithMatrix expands a task into multiple parallel tasks from every combination of the values
+	// of the axes, referenced as {{item.<axis>}}
+	WithMatrix []MatrixAxis `json:"withMatrix,omitempty"`
+
+	// WithArtifact expands a task into multiple parallel tasks from the items stored in an
+	// artifact of a previous task
+	WithArtifact *LoopArtifact `json:"withArtifact,omitempty"`
+
+	// BatchSize groups the items of a loop into batches of up to BatchSize items, each expanded
+	// into a task which receives the items of its batch as a JSON list in {{item}}, and the
+	// indexes of its first and last items in {{batch.start}} and {{batch.end}}
+	BatchSize int32 `json:"batchSize,omitempty"`
+
 	// When is an expression in which the task should conditionally execute
 	When string `json:"when,omitempty"`
 
 	// ContinueOn makes argo to proceed with the following step even if this step fails.
 	// Errors and Failed states can be specified
 	ContinueOn *ContinueOn `json:"continueOn,omitempty"`
+
+	// FailureTolerance is the number, or the percentage, of the items expanded from withItems,
+	// withParam, withSequence, withMatrix or withArtifact which are allowed to fail without failing
+	// the task group
+	FailureTolerance *intstr.IntOrString `json:"failureTolerance,omitempty"`
+
+	// Race makes the tasks expanded from withItems, withParam, withSequence, withMatrix or
+	// withArtifact race each other: the first task to succeed makes the task group succeed, and the
+	// other tasks are terminated and skipped. The outputs of the task group, available as
+	// {{tasks.<name>.outputs.*}}, are those of the winner
+	Race bool `json:"race,omitempty"`
+
+	// OnExit is a template reference which is invoked after the task completes, irrespective of
+	// its success, failure, or error. The phase of the task is available as {{status}} and its
+	// outputs as {{outputs.result}} and {{outputs.parameters.<name>}}
+	OnExit string `json:"onExit,omitempty"`
 }
 
 var _ TemplateHolder = &DAGTask{}
@@ -1250,12 +1598,96 @@ func (t *DAGTask) IsResolvable() bool {
 	return true
 }
 
+// IsLoop returns whether the task is expanded into items by withItems, withParam, withSequence,
+// withMatrix or withArtifact
+func (t *DAGTask) IsLoop() bool {
+	return len(t.WithItems) > 0 || t.WithParam != "" || t.WithSequence != nil || len(t.WithMatrix) > 0 || t.WithArtifact != nil
+}
+
 // SuspendTemplate is a template subtype to suspend a workflow at a predetermined point in time
 type SuspendTemplate struct {
 	// Duration is the seconds to wait before automatically resuming a template
 	Duration *int32 `json:"duration,omitempty" protobuf:"bytes,1,opt,name=duration"`
 }
 
+// ApprovalDecision is the decision of an approver
+type ApprovalDecision string
+
+// Possible approval decisions
+const (
+	ApprovalDecisionApprove ApprovalDecision = "Approve"
+	ApprovalDecisionReject  ApprovalDecision = "Reject"
+)
+
+// ApprovalTemplate is a template subtype to suspend a workflow until the step is approved or rejected
+// by the allowed approvers
+type ApprovalTemplate struct {
+	// Users are the names of the users allowed to approve or reject the step. Anyone is allowed to
+	// approve or reject the step if no user is specified. The names of the approvers are declared by
+	// the clients and not authenticated, so this list is advisory: access to approvals is governed by
+	// the RBAC permission to update workflows.
+	Users []string `json:"users,omitempty"`
+
+	// Groups is not supported and rejected by validation, as the groups of the approvers cannot be
+	// authenticated. A step which only lists groups cannot be approved or rejected by anyone.
+	Groups []string `json:"groups,omitempty"`
+
+	// Required is the number of approvals from distinct approvers required to approve the step
+	// (default: 1). A single rejection rejects the step.
+	Required *int32 `json:"required,omitempty"`
+
+	// Timeout is the seconds to wait for the decision of the approvers before applying the default decision
+	Timeout *int32 `json:"timeout,omitempty"`
+
+	// DefaultDecision is the decision applied when the timeout elapses. One of: Approve, Reject (default)
+	DefaultDecision ApprovalDecision `json:"defaultDecision,omitempty"`
+}
+
+// GetRequired returns the number of approvals required to approve the step
+func (a *ApprovalTemplate) GetRequired() int32 {
+	if a.Required == nil {
+		return 1
+	}
+	return *a.Required
+}
+
+// IsAllowed returns whether the user is allowed to approve or reject the step
+func (a *ApprovalTemplate) IsAllowed(user string) bool {
+	if len(a.Users) == 0 && len(a.Groups) == 0 {
+		return true
+	}
+	for _, u := range a.Users {
+		if u == user {
+			return true
+		}
+	}
+	return false
+}
+
+// ApprovalStatus is the approval policy and the decisions made for an approval node
+type ApprovalStatus struct {
+	ApprovalTemplate `json:",inline"`
+
+	// Decisions are the decisions of the approvers, in the order they were made
+	Decisions []ApprovalRecord `json:"decisions,omitempty"`
+}
+
+// ApprovalRecord records the decision of an approver
+type ApprovalRecord struct {
+	// Approver is the name of the user who made the decision, as declared by the client. It is not
+	// authenticated.
+	Approver string `json:"approver"`
+
+	// Decision is the decision of the approver
+	Decision ApprovalDecision `json:"decision"`
+
+	// Comment is an optional comment of the approver
+	Comment string `json:"comment,omitempty"`
+
+	// Time is the time at which the decision was made
+	Time metav1.Time `json:"time"`
+}
+
 // GetArtifactByName returns an input artifact by its name
 func (in *Inputs) GetArtifactByName(name string) *Artifact {
 	for _, art := range in.Artifacts {
@@ -1329,7 +1761,8 @@ func (a *Artifact) HasLocation() bool {
 		a.Artifactory.HasLocation() ||
 		a.Raw.HasLocation() ||
 		a.HDFS.HasLocation() ||
-		a.GCS.HasLocation()
+		a.GCS.HasLocation() ||
+		a.Aggregate.HasLocation()
 }
 
 // GetTemplateByName retrieves a defined template by its name
@@ -1401,33 +1834,54 @@ func (wf *Workflow) getStoredTemplateName(templateScope string, holder TemplateH
 }
 
 // ContinueOn defines if a workflow should continue even if a task or step fails/errors.
-// It can be specified if the workflow should continue when the pod errors, fails or both.
+// It can be specified if the workflow should continue when the pod errors, fails or both,
+// or only when it fails with specific exit codes or error conditions.
 type ContinueOn struct {
 	// +optional
 	Error bool `json:"error,omitempty"`
 	// +optional
 	Failed bool `json:"failed,omitempty"`
+	// ExitCodes are the exit codes of the main container for which a failure is continued
+	// +optional
+	ExitCodes []int32 `json:"exitCodes,omitempty"`
+	// Conditions are the names of the error conditions of the template for which a failure is continued
+	// +optional
+	Conditions []string `json:"conditions,omitempty"`
 }
 
-func continues(c *ContinueOn, phase NodePhase) bool {
+func continues(c *ContinueOn, node *NodeStatus) bool {
 	if c == nil {
 		return false
 	}
-	if c.Error && phase == NodeError {
+	if c.Error && node.Phase == NodeError {
 		return true
 	}
-	if c.Failed && phase == NodeFailed {
+	if c.Failed && node.Phase == NodeFailed {
 		return true
 	}
+	if node.Phase == NodeFailed && node.ExitCode != nil {
+		for _, exitCode := range c.ExitCodes {
+			if exitCode == *node.ExitCode {
+				return true
+			}
+		}
+	}
+	for _, condition := range c.Conditions {
+		for _, errorCondition := range node.ErrorConditions {
+			if condition == errorCondition {
+				return true
+			}
+		}
+	}
 	return false
 }
 
 // ContinuesOn returns whether the DAG should be proceeded if the task fails or errors.
-func (t *DAGTask) ContinuesOn(phase NodePhase) bool {
-	return continues(t.ContinueOn, phase)
+func (t *DAGTask) ContinuesOn(node *NodeStatus) bool {
+	return continues(t.ContinueOn, node)
 }
 
 // ContinuesOn returns whether the StepGroup should be proceeded if the task fails or errors.
-func (s *WorkflowStep) ContinuesOn(phase NodePhase) bool {
-	return continues(s.ContinueOn, phase)
+func (s *WorkflowStep) ContinuesOn(node *NodeStatus) bool {
+	return continues(s.ContinueOn, node)
 }
@@ -6,9 +6,111 @@ package v1alpha1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AggregateArtifact) DeepCopyInto(out *AggregateArtifact) {
+	*out = *in
+	if in.Artifacts != nil {
+		in, out := &in.Artifacts, &out.Artifacts
+		*out = make([]Artifact, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AggregateArtifact.
+func (in *AggregateArtifact) DeepCopy() *AggregateArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(AggregateArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApprovalRecord) DeepCopyInto(out *ApprovalRecord) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApprovalRecord.
+func (in *ApprovalRecord) DeepCopy() *ApprovalRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(ApprovalRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApprovalStatus) DeepCopyInto(out *ApprovalStatus) {
+	*out = *in
+	in.ApprovalTemplate.DeepCopyInto(&out.ApprovalTemplate)
+	if in.Decisions != nil {
+		in, out := &in.Decisions, &out.Decisions
+		*out = make([]ApprovalRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApprovalStatus.
+func (in *ApprovalStatus) DeepCopy() *ApprovalStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ApprovalStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApprovalTemplate) DeepCopyInto(out *ApprovalTemplate) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Required != nil {
+		in, out := &in.Required, &out.Required
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApprovalTemplate.
+func (in *ApprovalTemplate) DeepCopy() *ApprovalTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ApprovalTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ArchiveStrategy) DeepCopyInto(out *ArchiveStrategy) {
 	*out = *in
@@ -135,6 +237,11 @@ func (in *ArtifactLocation) DeepCopyInto(out *ArtifactLocation) {
 		*out = new(GCSArtifact)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Aggregate != nil {
+		in, out := &in.Aggregate, &out.Aggregate
+		*out = new(AggregateArtifact)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -210,6 +317,16 @@ func (in *ArtifactoryAuth) DeepCopy() *ArtifactoryAuth {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ContinueOn) DeepCopyInto(out *ContinueOn) {
 	*out = *in
+	if in.ExitCodes != nil {
+		in, out := &in.ExitCodes, &out.ExitCodes
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -249,9 +366,26 @@ func (in *DAGTask) DeepCopyInto(out *DAGTask) {
 		*out = new(Sequence)
 		**out = **in
 	}
+	if in.WithMatrix != nil {
+		in, out := &in.WithMatrix, &out.WithMatrix
+		*out = make([]MatrixAxis, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.WithArtifact != nil {
+		in, out := &in.WithArtifact, &out.WithArtifact
+		*out = new(LoopArtifact)
+		**out = **in
+	}
 	if in.ContinueOn != nil {
 		in, out := &in.ContinueOn, &out.ContinueOn
 		*out = new(ContinueOn)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailureTolerance != nil {
+		in, out := &in.FailureTolerance, &out.FailureTolerance
+		*out = new(intstr.IntOrString)
 		**out = **in
 	}
 	return
@@ -544,6 +678,45 @@ func (in *Item) DeepCopy() *Item {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoopArtifact) DeepCopyInto(out *LoopArtifact) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoopArtifact.
+func (in *LoopArtifact) DeepCopy() *LoopArtifact {
+	if in == nil {
+		return nil
+	}
+	out := new(LoopArtifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatrixAxis) DeepCopyInto(out *MatrixAxis) {
+	*out = *in
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Item, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatrixAxis.
+func (in *MatrixAxis) DeepCopy() *MatrixAxis {
+	if in == nil {
+		return nil
+	}
+	out := new(MatrixAxis)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Metadata) DeepCopyInto(out *Metadata) {
 	*out = *in
@@ -589,6 +762,31 @@ func (in *NodeStatus) DeepCopyInto(out *NodeStatus) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExitCode != nil {
+		in, out := &in.ExitCode, &out.ExitCode
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ErrorConditions != nil {
+		in, out := &in.ErrorConditions, &out.ErrorConditions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SchedulingLatency != nil {
+		in, out := &in.SchedulingLatency, &out.SchedulingLatency
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ExhaustedResources != nil {
+		in, out := &in.ExhaustedResources, &out.ExhaustedResources
+		*out = make([]v1.ResourceName, len(*in))
+		copy(*out, *in)
+	}
 	if in.Inputs != nil {
 		in, out := &in.Inputs, &out.Inputs
 		*out = new(Inputs)
@@ -609,6 +807,11 @@ func (in *NodeStatus) DeepCopyInto(out *NodeStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Approval != nil {
+		in, out := &in.Approval, &out.Approval
+		*out = new(ApprovalStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -691,6 +894,15 @@ func (in *Parameter) DeepCopyInto(out *Parameter) {
 		*out = new(ValueFrom)
 		**out = **in
 	}
+	if in.Enum != nil {
+		in, out := &in.Enum, &out.Enum
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Schema != nil {
+		in, out := &in.Schema, &out.Schema
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -704,6 +916,42 @@ func (in *Parameter) DeepCopy() *Parameter {
 	return out
 }
 
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ParameterSchema.
+func (in *ParameterSchema) DeepCopy() *ParameterSchema {
+	if in == nil {
+		return nil
+	}
+	out := new(ParameterSchema)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingPolicy) DeepCopyInto(out *PendingPolicy) {
+	*out = *in
+	if in.MaxDuration != nil {
+		in, out := &in.MaxDuration, &out.MaxDuration
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailureReasons != nil {
+		in, out := &in.FailureReasons, &out.FailureReasons
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingPolicy.
+func (in *PendingPolicy) DeepCopy() *PendingPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PendingPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodGC) DeepCopyInto(out *PodGC) {
 	*out = *in
@@ -736,6 +984,29 @@ func (in *RawArtifact) DeepCopy() *RawArtifact {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceEscalation) DeepCopyInto(out *ResourceEscalation) {
+	*out = *in
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceEscalation.
+func (in *ResourceEscalation) DeepCopy() *ResourceEscalation {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceEscalation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceTemplate) DeepCopyInto(out *ResourceTemplate) {
 	*out = *in
@@ -760,6 +1031,16 @@ func (in *RetryStrategy) DeepCopyInto(out *RetryStrategy) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.InfrastructureLimit != nil {
+		in, out := &in.InfrastructureLimit, &out.InfrastructureLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ResourceEscalation != nil {
+		in, out := &in.ResourceEscalation, &out.ResourceEscalation
+		*out = new(ResourceEscalation)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -950,6 +1231,11 @@ func (in *Template) DeepCopyInto(out *Template) {
 		*out = new(SuspendTemplate)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Approval != nil {
+		in, out := &in.Approval, &out.Approval
+		*out = new(ApprovalTemplate)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
 		*out = make([]v1.Volume, len(*in))
@@ -981,11 +1267,21 @@ func (in *Template) DeepCopyInto(out *Template) {
 		*out = new(int64)
 		**out = **in
 	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(int64)
+		**out = **in
+	}
 	if in.RetryStrategy != nil {
 		in, out := &in.RetryStrategy, &out.RetryStrategy
 		*out = new(RetryStrategy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PendingPolicy != nil {
+		in, out := &in.PendingPolicy, &out.PendingPolicy
+		*out = new(PendingPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Parallelism != nil {
 		in, out := &in.Parallelism, &out.Parallelism
 		*out = new(int64)
@@ -1262,6 +1558,11 @@ func (in *WorkflowSpec) DeepCopyInto(out *WorkflowSpec) {
 		*out = new(int64)
 		**out = **in
 	}
+	if in.ShutdownGracePeriodSeconds != nil {
+		in, out := &in.ShutdownGracePeriodSeconds, &out.ShutdownGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
 	if in.Priority != nil {
 		in, out := &in.Priority, &out.Priority
 		*out = new(int32)
@@ -1314,6 +1615,30 @@ func (in *WorkflowStatus) DeepCopyInto(out *WorkflowStatus) {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.LoopItems != nil {
+		in, out := &in.LoopItems, &out.LoopItems
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DynamicDAGTasks != nil {
+		in, out := &in.DynamicDAGTasks, &out.DynamicDAGTasks
+		*out = make(map[string][]DAGTask, len(*in))
+		for key, val := range *in {
+			var outVal []DAGTask
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]DAGTask, len(*in))
+				for i := range *in {
+					(*in)[i].DeepCopyInto(&(*out)[i])
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
 	if in.StoredTemplates != nil {
 		in, out := &in.StoredTemplates, &out.StoredTemplates
 		*out = make(map[string]Template, len(*in))
@@ -1377,9 +1702,26 @@ func (in *WorkflowStep) DeepCopyInto(out *WorkflowStep) {
 		*out = new(Sequence)
 		**out = **in
 	}
+	if in.WithMatrix != nil {
+		in, out := &in.WithMatrix, &out.WithMatrix
+		*out = make([]MatrixAxis, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.WithArtifact != nil {
+		in, out := &in.WithArtifact, &out.WithArtifact
+		*out = new(LoopArtifact)
+		**out = **in
+	}
 	if in.ContinueOn != nil {
 		in, out := &in.ContinueOn, &out.ContinueOn
 		*out = new(ContinueOn)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailureTolerance != nil {
+		in, out := &in.FailureTolerance, &out.FailureTolerance
+		*out = new(intstr.IntOrString)
 		**out = **in
 	}
 	return
@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"github.com/cyrusbiotechnology/argo/errors"
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+	"github.com/cyrusbiotechnology/argo/workflow/artifacts/artifactory"
+	"github.com/cyrusbiotechnology/argo/workflow/artifacts/gcs"
+	"github.com/cyrusbiotechnology/argo/workflow/artifacts/git"
+	"github.com/cyrusbiotechnology/argo/workflow/artifacts/hdfs"
+	"github.com/cyrusbiotechnology/argo/workflow/artifacts/http"
+	"github.com/cyrusbiotechnology/argo/workflow/artifacts/raw"
+	"github.com/cyrusbiotechnology/argo/workflow/artifacts/s3"
+	"github.com/cyrusbiotechnology/argo/workflow/common"
+)
+
+// NewDriver initializes an instance of an artifact driver. The secrets of the artifact are read
+// from the volume mounts of the resource interface.
+func NewDriver(art *wfv1.Artifact, ri common.ResourceInterface) (ArtifactDriver, error) {
+	if art.S3 != nil {
+		var accessKey string
+		var secretKey string
+
+		if art.S3.AccessKeySecret.Name != "" {
+			accessKeyBytes, err := ri.GetSecretFromVolMount(art.S3.AccessKeySecret.Name, art.S3.AccessKeySecret.Key)
+			if err != nil {
+				return nil, err
+			}
+			accessKey = string(accessKeyBytes)
+			secretKeyBytes, err := ri.GetSecretFromVolMount(art.S3.SecretKeySecret.Name, art.S3.SecretKeySecret.Key)
+			if err != nil {
+				return nil, err
+			}
+			secretKey = string(secretKeyBytes)
+		}
+
+		driver := s3.S3ArtifactDriver{
+			Endpoint:  art.S3.Endpoint,
+			AccessKey: accessKey,
+			SecretKey: secretKey,
+			Secure:    art.S3.Insecure == nil || !*art.S3.Insecure,
+			Region:    art.S3.Region,
+			RoleARN:   art.S3.RoleARN,
+		}
+		return &driver, nil
+	}
+	if art.GCS != nil {
+		credsJSONData, err := ri.GetSecretFromVolMount(art.GCS.CredentialsSecret.Name, art.GCS.CredentialsSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver := gcs.GCSArtifactDriver{
+			CredsJSONData: credsJSONData,
+		}
+		return &driver, nil
+	}
+	if art.GCS != nil {
+		driver := gcs.GCSArtifactDriver{}
+		return &driver, nil
+	}
+	if art.HTTP != nil {
+		return &http.HTTPArtifactDriver{}, nil
+	}
+	if art.Git != nil {
+		gitDriver := git.GitArtifactDriver{
+			InsecureIgnoreHostKey: art.Git.InsecureIgnoreHostKey,
+		}
+		if art.Git.UsernameSecret != nil {
+			usernameBytes, err := ri.GetSecretFromVolMount(art.Git.UsernameSecret.Name, art.Git.UsernameSecret.Key)
+			if err != nil {
+				return nil, err
+			}
+			gitDriver.Username = string(usernameBytes)
+		}
+		if art.Git.PasswordSecret != nil {
+			passwordBytes, err := ri.GetSecretFromVolMount(art.Git.PasswordSecret.Name, art.Git.PasswordSecret.Key)
+			if err != nil {
+				return nil, err
+			}
+			gitDriver.Password = string(passwordBytes)
+		}
+		if art.Git.SSHPrivateKeySecret != nil {
+			sshPrivateKeyBytes, err := ri.GetSecretFromVolMount(art.Git.SSHPrivateKeySecret.Name, art.Git.SSHPrivateKeySecret.Key)
+			if err != nil {
+				return nil, err
+			}
+			gitDriver.SSHPrivateKey = string(sshPrivateKeyBytes)
+		}
+
+		return &gitDriver, nil
+	}
+	if art.Artifactory != nil {
+		usernameBytes, err := ri.GetSecretFromVolMount(art.Artifactory.UsernameSecret.Name, art.Artifactory.UsernameSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		passwordBytes, err := ri.GetSecretFromVolMount(art.Artifactory.PasswordSecret.Name, art.Artifactory.PasswordSecret.Key)
+		if err != nil {
+			return nil, err
+		}
+		driver := artifactory.ArtifactoryArtifactDriver{
+			Username: string(usernameBytes),
+			Password: string(passwordBytes),
+		}
+		return &driver, nil
+
+	}
+	if art.HDFS != nil {
+		return hdfs.CreateDriver(ri, art.HDFS)
+	}
+	if art.Raw != nil {
+		return &raw.RawArtifactDriver{}, nil
+	}
+
+	return nil, errors.Errorf(errors.CodeBadRequest, "Unsupported artifact driver for %s", art.Name)
+}
@@ -53,6 +53,12 @@ const (
 	AnnotationKeyExecutionControl = workflow.WorkflowFullName + "/execution"
 	//AnnotationKeyErrors is the annotation key containing extended fatal error information
 	AnnotationKeyErrors = workflow.WorkflowFullName + "/errors"
+	// AnnotationKeyTraceContext is the pod metadata annotation key containing the W3C trace context
+	// of the node span, which the executor uses as the parent of its own spans
+	AnnotationKeyTraceContext = workflow.WorkflowFullName + "/trace-context"
+	// AnnotationKeyInfrastructureRetries is the pod metadata annotation key containing the number of
+	// times the pod of the node was recreated after failing because of the infrastructure
+	AnnotationKeyInfrastructureRetries = workflow.WorkflowFullName + "/infrastructure-retries"
 	//AnnotationKeyWarnings is the annotation key containing extended
 	AnnotationKeyWarnings = workflow.WorkflowFullName + "/warnings"
 
@@ -100,6 +106,8 @@ const (
 	EnvVarKubeletPort = "ARGO_KUBELET_PORT"
 	// EnvVarKubeletInsecure is used to disable the TLS verification
 	EnvVarKubeletInsecure = "ARGO_KUBELET_INSECURE"
+	// EnvVarTracingConfig contains the JSON tracing configuration used by the executor to export spans
+	EnvVarTracingConfig = "ARGO_TRACING_CONFIG"
 
 	// ContainerRuntimeExecutorDocker to use docker as container runtime executor
 	ContainerRuntimeExecutorDocker = "docker"
@@ -129,6 +137,8 @@ const (
 	GlobalVarWorkflowPriority = "workflow.priority"
 	// LocalVarPodName is a step level variable that references the name of the pod
 	LocalVarPodName = "pod.name"
+	// LocalVarStatus is a variable of step and task exit hooks that references the phase of the step or task
+	LocalVarStatus = "status"
 
 	KubeConfigDefaultMountPath    = "/kube/config"
 	KubeConfigDefaultVolumeName   = "kubeconfig"
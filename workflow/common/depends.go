@@ -0,0 +1,218 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Knetic/govaluate"
+
+	"github.com/cyrusbiotechnology/argo/errors"
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+)
+
+// DependsResult is a result of a task which can be referenced in a depends expression
+type DependsResult string
+
+// DependsResult values
+const (
+	DependsResultSucceeded DependsResult = "Succeeded"
+	DependsResultFailed    DependsResult = "Failed"
+	DependsResultErrored   DependsResult = "Errored"
+	DependsResultSkipped   DependsResult = "Skipped"
+	DependsResultDaemoned  DependsResult = "Daemoned"
+	// DependsResultAnySucceeded and DependsResultAllFailed apply to the tasks expanded from
+	// withItems, withParam, withSequence, withMatrix or withArtifact
+	DependsResultAnySucceeded DependsResult = "AnySucceeded"
+	DependsResultAllFailed    DependsResult = "AllFailed"
+)
+
+var dependsResults = map[DependsResult]bool{
+	DependsResultSucceeded:    true,
+	DependsResultFailed:       true,
+	DependsResultErrored:      true,
+	DependsResultSkipped:      true,
+	DependsResultDaemoned:     true,
+	DependsResultAnySucceeded: true,
+	DependsResultAllFailed:    true,
+}
+
+// DependsReference is a reference to a task in a depends expression. The result of a bare task
+// name is empty, and holds when the task would satisfy a plain dependency.
+type DependsReference struct {
+	TaskName string
+	Result   DependsResult
+}
+
+func (r DependsReference) String() string {
+	if r.Result == "" {
+		return r.TaskName
+	}
+	return fmt.Sprintf("%s.%s", r.TaskName, r.Result)
+}
+
+var (
+	// dependsReferenceRegex matches a task name, optionally followed by a result
+	dependsReferenceRegex = regexp.MustCompile(`[a-zA-Z0-9][-a-zA-Z0-9]*(\.[a-zA-Z]+)?`)
+	// dependsOperatorRegex matches the text allowed between the references
+	dependsOperatorRegex = regexp.MustCompile(`^[\s()!&|]*$`)
+)
+
+// ParseDepends parses a depends expression, e.g. `A.Succeeded || (B.Failed && !C)`, and returns
+// the distinct references it contains, in order
+func ParseDepends(depends string) ([]DependsReference, error) {
+	expression, refs, err := parseDepends(depends)
+	if err != nil {
+		return nil, err
+	}
+	// evaluate the expression once to detect the operators which do not apply to booleans
+	parameters := make(map[string]interface{})
+	for i := range refs {
+		parameters[dependsParameterName(i)] = true
+	}
+	result, err := expression.Evaluate(parameters)
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "Invalid depends expression '%s': %v", depends, err)
+	}
+	if _, ok := result.(bool); !ok {
+		return nil, errors.Errorf(errors.CodeBadRequest, "Invalid depends expression '%s': not a boolean expression", depends)
+	}
+	var distinct []DependsReference
+	seen := make(map[DependsReference]bool)
+	for _, ref := range refs {
+		if !seen[ref] {
+			seen[ref] = true
+			distinct = append(distinct, ref)
+		}
+	}
+	return distinct, nil
+}
+
+// ParsePositiveDepends parses a depends expression and returns the distinct references which
+// appear at least once without being negated, in order. In `A.Failed || !(B.Failed && C)`, only
+// A.Failed is positive: the expression expects the failure of A, but not the failure of B.
+func ParsePositiveDepends(depends string) ([]DependsReference, error) {
+	if _, err := ParseDepends(depends); err != nil {
+		return nil, err
+	}
+	var positive []DependsReference
+	seen := make(map[DependsReference]bool)
+	// negated is the polarity of the enclosing parentheses, and not whether a ! applies to what follows
+	var enclosing []bool
+	negated, not := false, false
+	last := 0
+	for _, loc := range dependsReferenceRegex.FindAllStringIndex(depends, -1) {
+		for _, c := range depends[last:loc[0]] {
+			switch c {
+			case '!':
+				not = !not
+			case '(':
+				enclosing = append(enclosing, negated)
+				negated, not = negated != not, false
+			case ')':
+				if len(enclosing) > 0 {
+					negated, enclosing = enclosing[len(enclosing)-1], enclosing[:len(enclosing)-1]
+				}
+			}
+		}
+		ref := DependsReference{TaskName: depends[loc[0]:loc[1]]}
+		if parts := strings.SplitN(ref.TaskName, ".", 2); len(parts) == 2 {
+			ref = DependsReference{TaskName: parts[0], Result: DependsResult(parts[1])}
+		}
+		if negated == not && !seen[ref] {
+			seen[ref] = true
+			positive = append(positive, ref)
+		}
+		not = false
+		last = loc[1]
+	}
+	return positive, nil
+}
+
+// EvaluateDepends evaluates a depends expression, given the value of every reference it contains
+func EvaluateDepends(depends string, resolve func(ref DependsReference) bool) (bool, error) {
+	expression, refs, err := parseDepends(depends)
+	if err != nil {
+		return false, err
+	}
+	parameters := make(map[string]interface{})
+	for i, ref := range refs {
+		parameters[dependsParameterName(i)] = resolve(ref)
+	}
+	result, err := expression.Evaluate(parameters)
+	if err != nil {
+		return false, errors.Errorf(errors.CodeBadRequest, "Invalid depends expression '%s': %v", depends, err)
+	}
+	boolRes, ok := result.(bool)
+	if !ok {
+		return false, errors.Errorf(errors.CodeBadRequest, "Expected boolean evaluation for '%s'. Got %v", depends, result)
+	}
+	return boolRes, nil
+}
+
+// ExpandDepends returns a copy of the tasks in which the dependencies of the tasks with a depends
+// expression are the tasks referenced by the expression. This lets the dependencies drive the
+// order of execution of the tasks regardless of how they are declared.
+func ExpandDepends(tasks []wfv1.DAGTask) ([]wfv1.DAGTask, error) {
+	expanded := make([]wfv1.DAGTask, len(tasks))
+	for i, task := range tasks {
+		expanded[i] = task
+		if task.Depends == "" {
+			continue
+		}
+		refs, err := ParseDepends(task.Depends)
+		if err != nil {
+			return nil, err
+		}
+		var dependencies []string
+		seen := make(map[string]bool)
+		for _, ref := range refs {
+			if !seen[ref.TaskName] {
+				seen[ref.TaskName] = true
+				dependencies = append(dependencies, ref.TaskName)
+			}
+		}
+		expanded[i].Dependencies = dependencies
+	}
+	return expanded, nil
+}
+
+// parseDepends replaces the references of a depends expression by parameters, which are named after
+// the index of the reference, and parses the resulting expression
+func parseDepends(depends string) (*govaluate.EvaluableExpression, []DependsReference, error) {
+	var refs []DependsReference
+	var sb strings.Builder
+	last := 0
+	for _, loc := range dependsReferenceRegex.FindAllStringIndex(depends, -1) {
+		if !dependsOperatorRegex.MatchString(depends[last:loc[0]]) {
+			return nil, nil, errors.Errorf(errors.CodeBadRequest, "Invalid depends expression '%s': unexpected '%s'", depends, strings.TrimSpace(depends[last:loc[0]]))
+		}
+		sb.WriteString(depends[last:loc[0]])
+		ref := DependsReference{TaskName: depends[loc[0]:loc[1]]}
+		if parts := strings.SplitN(ref.TaskName, ".", 2); len(parts) == 2 {
+			ref = DependsReference{TaskName: parts[0], Result: DependsResult(parts[1])}
+			if !dependsResults[ref.Result] {
+				return nil, nil, errors.Errorf(errors.CodeBadRequest, "Invalid depends expression '%s': unknown result '%s' of task '%s'", depends, ref.Result, ref.TaskName)
+			}
+		}
+		sb.WriteString(dependsParameterName(len(refs)))
+		refs = append(refs, ref)
+		last = loc[1]
+	}
+	if !dependsOperatorRegex.MatchString(depends[last:]) {
+		return nil, nil, errors.Errorf(errors.CodeBadRequest, "Invalid depends expression '%s': unexpected '%s'", depends, strings.TrimSpace(depends[last:]))
+	}
+	sb.WriteString(depends[last:])
+	if len(refs) == 0 {
+		return nil, nil, errors.Errorf(errors.CodeBadRequest, "Invalid depends expression '%s': no task referenced", depends)
+	}
+	expression, err := govaluate.NewEvaluableExpression(sb.String())
+	if err != nil {
+		return nil, nil, errors.Errorf(errors.CodeBadRequest, "Invalid depends expression '%s': %v", depends, err)
+	}
+	return expression, refs, nil
+}
+
+func dependsParameterName(i int) string {
+	return fmt.Sprintf("dep%d", i)
+}
@@ -0,0 +1,75 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+)
+
+func TestParseDepends(t *testing.T) {
+	refs, err := ParseDepends("A.Succeeded || (task-b.Failed && !C) || A.Succeeded")
+	assert.NoError(t, err)
+	assert.Equal(t, []DependsReference{
+		{TaskName: "A", Result: DependsResultSucceeded},
+		{TaskName: "task-b", Result: DependsResultFailed},
+		{TaskName: "C"},
+	}, refs)
+
+	_, err = ParseDepends("A.Completed")
+	assert.EqualError(t, err, "Invalid depends expression 'A.Completed': unknown result 'Completed' of task 'A'")
+	_, err = ParseDepends("A == B")
+	assert.EqualError(t, err, "Invalid depends expression 'A == B': unexpected '=='")
+	_, err = ParseDepends("A & B")
+	assert.Error(t, err)
+	_, err = ParseDepends("(A || B")
+	assert.Error(t, err)
+	_, err = ParseDepends("()")
+	assert.Error(t, err)
+}
+
+func TestParsePositiveDepends(t *testing.T) {
+	for depends, expected := range map[string][]DependsReference{
+		"A.Failed || !B.Failed": {{TaskName: "A", Result: DependsResultFailed}},
+		"!(A.Failed || !B.Errored) && C": {
+			{TaskName: "B", Result: DependsResultErrored},
+			{TaskName: "C"},
+		},
+		"!(!A.Failed)":                      {{TaskName: "A", Result: DependsResultFailed}},
+		"(!A.Failed || B) && A.Failed":      {{TaskName: "B"}, {TaskName: "A", Result: DependsResultFailed}},
+		"!(A.Failed) || (!(B.Failed))":      nil,
+		"!(A.Succeeded && (B.Failed)) || C": {{TaskName: "C"}},
+	} {
+		refs, err := ParsePositiveDepends(depends)
+		assert.NoError(t, err, depends)
+		assert.Equal(t, expected, refs, depends)
+	}
+	_, err := ParsePositiveDepends("(A || B")
+	assert.Error(t, err)
+}
+
+func TestEvaluateDepends(t *testing.T) {
+	results := map[string]bool{"A.Succeeded": false, "B.Failed": true, "C.Skipped": false}
+	resolve := func(ref DependsReference) bool {
+		return results[ref.String()]
+	}
+	proceed, err := EvaluateDepends("A.Succeeded || (B.Failed && !C.Skipped)", resolve)
+	assert.NoError(t, err)
+	assert.True(t, proceed)
+	proceed, err = EvaluateDepends("A.Succeeded || B.Failed && C.Skipped", resolve)
+	assert.NoError(t, err)
+	assert.False(t, proceed)
+}
+
+func TestExpandDepends(t *testing.T) {
+	tasks, err := ExpandDepends([]wfv1.DAGTask{
+		{Name: "A"},
+		{Name: "B", Dependencies: []string{"A"}},
+		{Name: "C", Depends: "B.Failed || (A.Succeeded && !B)"},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, tasks[0].Dependencies)
+	assert.Equal(t, []string{"A"}, tasks[1].Dependencies)
+	assert.Equal(t, []string{"B", "A"}, tasks[2].Dependencies)
+}
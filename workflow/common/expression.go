@@ -0,0 +1,639 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Knetic/govaluate"
+	"github.com/argoproj/pkg/strftime"
+	"k8s.io/client-go/util/jsonpath"
+
+	"github.com/cyrusbiotechnology/argo/errors"
+)
+
+// ExpressionFunctions are the functions available to the expressions, i.e. the typed when
+// expressions and the `{{=expr}}` substitutions
+var ExpressionFunctions = map[string]govaluate.ExpressionFunction{
+	// strings
+	"upper":     exprUpper,
+	"lower":     exprLower,
+	"trim":      exprTrim,
+	"replace":   exprReplace,
+	"split":     exprSplit,
+	"join":      exprJoin,
+	"substr":    exprSubstr,
+	"hasPrefix": exprHasPrefix,
+	"hasSuffix": exprHasSuffix,
+	"matches":   exprMatches,
+	"contains":  exprContains,
+	"len":       exprLen,
+	// conversions
+	"int":    exprInt,
+	"float":  exprFloat,
+	"string": exprString,
+	// math
+	"abs":   exprAbs,
+	"floor": exprFloor,
+	"ceil":  exprCeil,
+	"round": exprRound,
+	"min":   exprMin,
+	"max":   exprMax,
+	// dates
+	"now":      exprNow,
+	"strftime": exprStrftime,
+	"timeAdd":  exprTimeAdd,
+	// encodings
+	"jsonpath":     exprJSONPath,
+	"toJson":       exprToJSON,
+	"base64Encode": exprBase64Encode,
+	"base64Decode": exprBase64Decode,
+}
+
+// expressionKeywords are the operators of govaluate which may be followed by a parenthesis
+var expressionKeywords = map[string]bool{
+	"in": true,
+}
+
+// expressionIdentifierRegex matches the identifiers of an expression, including the dotted variable
+// names, whose step or task names may contain '-'
+var expressionIdentifierRegex = regexp.MustCompile(`[a-zA-Z_][-a-zA-Z0-9_.]*`)
+
+// exprList is a list returned by a function. It is distinct from []interface{} since govaluate
+// spreads the latter into the arguments of the function it is passed to.
+type exprList []interface{}
+
+// Expression is a parsed expression
+type Expression struct {
+	kind       string
+	expr       string
+	expression *govaluate.EvaluableExpression
+	// Variables are the distinct variables referenced by the expression, e.g. steps.A.exitCode
+	Variables []string
+}
+
+// ParseExpressionTag parses the expression of a `{{=expr}}` substitution, given the tag between
+// the braces. The tag is expected to come from a JSON document, so it is unescaped first.
+func ParseExpressionTag(tag string) (*Expression, error) {
+	expr := strings.TrimPrefix(tag, "=")
+	if unquoted, err := strconv.Unquote(`"` + expr + `"`); err == nil {
+		expr = unquoted
+	}
+	return parseExpression("expression", expr, isSubstitutionVariable)
+}
+
+// isSubstitutionVariable returns whether or not an identifier is a variable which can be substituted
+func isSubstitutionVariable(ident string) bool {
+	if ident == "item" {
+		return true
+	}
+	for _, prefix := range GlobalVarValidWorkflowVariablePrefix {
+		if strings.HasPrefix(ident, prefix) && len(ident) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExpression parses an expression whose variables are the identifiers for which isVariable
+// returns true
+func parseExpression(kind string, expr string, isVariable func(ident string) bool) (*Expression, error) {
+	rewritten, variables, functions := rewriteExpression(expr, isVariable, func(ident string) string {
+		return "[" + ident + "]"
+	})
+	for _, function := range functions {
+		if _, ok := ExpressionFunctions[function]; !ok {
+			return nil, errors.Errorf(errors.CodeBadRequest, "Invalid %s '%s': unknown function '%s'", kind, expr, function)
+		}
+	}
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(rewritten, ExpressionFunctions)
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "Invalid %s '%s': %v", kind, expr, err)
+	}
+	return &Expression{kind: kind, expr: expr, expression: expression, Variables: variables}, nil
+}
+
+// Evaluate evaluates the expression against a context holding the value of its variables
+func (e *Expression) Evaluate(context map[string]interface{}) (interface{}, error) {
+	parameters := make(map[string]interface{}, len(e.Variables))
+	for _, v := range e.Variables {
+		val, ok := context[v]
+		if !ok {
+			return nil, errors.Errorf(errors.CodeBadRequest, "Invalid %s '%s': unresolved variable '%s'", e.kind, e.expr, v)
+		}
+		parameters[v] = val
+	}
+	result, err := e.expression.Evaluate(parameters)
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "Failed to evaluate %s '%s': %v", e.kind, e.expr, err)
+	}
+	return result, nil
+}
+
+// EvaluateExpressionTag evaluates the expression of a `{{=expr}}` substitution against the
+// replacement map, and returns the result as a string. If a variable of the expression is not in
+// the map, the returned bool is false and the returned string is the tag in which the other
+// variables are substituted.
+func EvaluateExpressionTag(tag string, replaceMap map[string]string) (string, bool, error) {
+	expression, err := ParseExpressionTag(tag)
+	if err != nil {
+		return "", false, err
+	}
+	context := make(map[string]interface{}, len(expression.Variables))
+	for _, v := range expression.Variables {
+		val, ok := replaceMap[v]
+		if !ok {
+			// substitute the variables which are resolved, so the expression can be evaluated once
+			// the others are, even though these are no longer available
+			partial, _, _ := rewriteExpression(expression.expr, isSubstitutionVariable, func(ident string) string {
+				if val, ok := replaceMap[ident]; ok {
+					return quoteExpressionString(val)
+				}
+				return ident
+			})
+			return "=" + partial, false, nil
+		}
+		context[v] = val
+	}
+	result, err := expression.Evaluate(context)
+	if err != nil {
+		return "", false, err
+	}
+	return toString(result), true, nil
+}
+
+// rewriteExpression rewrites the variables of an expression, e.g. encloses them in brackets so they
+// are not parsed as accessors or subtractions by govaluate. It returns the rewritten expression, the
+// distinct variables and the distinct functions called. Quoted strings are left untouched.
+func rewriteExpression(expr string, isVariable func(ident string) bool, rewriteVariable func(ident string) string) (string, []string, []string) {
+	var sb strings.Builder
+	var variables, functions []string
+	seen := make(map[string]bool)
+	rewrite := func(s string, next string) {
+		last := 0
+		for _, loc := range expressionIdentifierRegex.FindAllStringIndex(s, -1) {
+			ident := s[loc[0]:loc[1]]
+			rest := strings.TrimLeft(s[loc[1]:]+next, " \t")
+			if strings.HasPrefix(rest, "(") && !expressionKeywords[ident] {
+				if !seen[ident+"()"] {
+					seen[ident+"()"] = true
+					functions = append(functions, ident)
+				}
+				continue
+			}
+			if !isVariable(ident) {
+				continue
+			}
+			sb.WriteString(s[last:loc[0]])
+			sb.WriteString(rewriteVariable(ident))
+			last = loc[1]
+			if !seen[ident] {
+				seen[ident] = true
+				variables = append(variables, ident)
+			}
+		}
+		sb.WriteString(s[last:])
+	}
+	for len(expr) > 0 {
+		quote := strings.IndexAny(expr, `'"[`)
+		if quote < 0 {
+			rewrite(expr, "")
+			break
+		}
+		rewrite(expr[:quote], expr[quote:])
+		closing := expr[quote]
+		if closing == '[' {
+			closing = ']'
+		}
+		end := -1
+		for i := quote + 1; i < len(expr); i++ {
+			if expr[i] == '\\' && closing != ']' {
+				i++
+			} else if expr[i] == closing {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			sb.WriteString(expr[quote:])
+			break
+		}
+		sb.WriteString(expr[quote : end+1])
+		expr = expr[end+1:]
+	}
+	return sb.String(), variables, functions
+}
+
+// quoteExpressionString returns the string literal of a value
+func quoteExpressionString(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return "'" + strings.Replace(s, "'", `\'`, -1) + "'"
+}
+
+func exprArgs(name string, args []interface{}, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("%s() expects %d argument(s), got %d", name, n, len(args))
+	}
+	return nil
+}
+
+func toFloat(name string, arg interface{}) (float64, error) {
+	switch v := arg.(type) {
+	case float64:
+		return v, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s(): '%s' is not a number", name, v)
+		}
+		return f, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%s(): %v is not a number", name, arg)
+}
+
+func toString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case exprList, []interface{}, map[string]interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return fmt.Sprint(arg)
+}
+
+// toList converts a list, or a string holding a JSON list such as the aggregated outputs of a loop,
+// to a list
+func toList(arg interface{}) (exprList, bool) {
+	switch v := arg.(type) {
+	case exprList:
+		return v, true
+	case string:
+		if strings.HasPrefix(strings.TrimSpace(v), "[") {
+			var list []interface{}
+			if err := json.Unmarshal([]byte(v), &list); err == nil {
+				return exprList(list), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// expressionTimeLayouts are the layouts of the times accepted by the date functions. The second one
+// is the one of workflow.creationTimestamp.
+var expressionTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05 -0700 MST"}
+
+func toTime(name string, arg interface{}) (time.Time, error) {
+	s, ok := arg.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%s() expects a RFC3339 time, got %v", name, arg)
+	}
+	for _, layout := range expressionTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s(): '%s' is not a RFC3339 time", name, s)
+}
+
+func stringFunction(name string, f func(s string) string) govaluate.ExpressionFunction {
+	return func(args ...interface{}) (interface{}, error) {
+		if err := exprArgs(name, args, 1); err != nil {
+			return nil, err
+		}
+		return f(toString(args[0])), nil
+	}
+}
+
+var (
+	exprUpper = stringFunction("upper", strings.ToUpper)
+	exprLower = stringFunction("lower", strings.ToLower)
+	exprTrim  = stringFunction("trim", strings.TrimSpace)
+)
+
+// exprReplace replaces all the occurrences of a substring
+func exprReplace(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("replace", args, 3); err != nil {
+		return nil, err
+	}
+	return strings.Replace(toString(args[0]), toString(args[1]), toString(args[2]), -1), nil
+}
+
+// exprSplit splits a string into a list around a separator
+func exprSplit(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("split", args, 2); err != nil {
+		return nil, err
+	}
+	var list exprList
+	for _, part := range strings.Split(toString(args[0]), toString(args[1])) {
+		list = append(list, part)
+	}
+	return list, nil
+}
+
+// exprJoin joins the items of a list with a separator
+func exprJoin(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("join", args, 2); err != nil {
+		return nil, err
+	}
+	list, ok := toList(args[0])
+	if !ok {
+		return nil, fmt.Errorf("join() expects a list, got %v", args[0])
+	}
+	items := make([]string, len(list))
+	for i, item := range list {
+		items[i] = toString(item)
+	}
+	return strings.Join(items, toString(args[1])), nil
+}
+
+// exprSubstr returns the characters of a string between a start index, included, and an end
+// index, excluded. The indexes are clamped to the bounds of the string.
+func exprSubstr(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("substr", args, 3); err != nil {
+		return nil, err
+	}
+	runes := []rune(toString(args[0]))
+	bounds := make([]int, 2)
+	for i, arg := range args[1:] {
+		f, err := toFloat("substr", arg)
+		if err != nil {
+			return nil, err
+		}
+		bounds[i] = int(math.Max(0, math.Min(f, float64(len(runes)))))
+	}
+	if bounds[1] < bounds[0] {
+		return "", nil
+	}
+	return string(runes[bounds[0]:bounds[1]]), nil
+}
+
+func exprHasPrefix(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("hasPrefix", args, 2); err != nil {
+		return nil, err
+	}
+	return strings.HasPrefix(toString(args[0]), toString(args[1])), nil
+}
+
+func exprHasSuffix(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("hasSuffix", args, 2); err != nil {
+		return nil, err
+	}
+	return strings.HasSuffix(toString(args[0]), toString(args[1])), nil
+}
+
+// exprMatches returns whether or not a string matches a regular expression
+func exprMatches(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("matches", args, 2); err != nil {
+		return nil, err
+	}
+	pattern, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("matches() expects a string pattern, got %v", args[1])
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("matches(): %v", err)
+	}
+	return re.MatchString(toString(args[0])), nil
+}
+
+// exprContains returns whether or not a list contains an item, an object contains a key, or a
+// string contains a substring. A string holding a JSON list is treated as a list.
+func exprContains(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("contains", args, 2); err != nil {
+		return nil, err
+	}
+	item := toString(args[1])
+	if list, ok := toList(args[0]); ok {
+		for _, elem := range list {
+			if toString(elem) == item {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	switch v := args[0].(type) {
+	case string:
+		return strings.Contains(v, item), nil
+	case map[string]interface{}:
+		_, ok := v[item]
+		return ok, nil
+	}
+	return nil, fmt.Errorf("contains() expects a string, list or object, got %v", args[0])
+}
+
+// exprLen returns the length of a list, object or string. A string holding a JSON list is treated
+// as a list, as by contains() and join().
+func exprLen(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("len", args, 1); err != nil {
+		return nil, err
+	}
+	if list, ok := toList(args[0]); ok {
+		return float64(len(list)), nil
+	}
+	switch v := reflect.ValueOf(args[0]); v.Kind() {
+	case reflect.String:
+		return float64(len([]rune(v.String()))), nil
+	case reflect.Slice, reflect.Map:
+		return float64(v.Len()), nil
+	}
+	return nil, fmt.Errorf("len() expects a string, list or object, got %v", args[0])
+}
+
+// exprInt converts a value to a number, truncated to an integer
+func exprInt(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("int", args, 1); err != nil {
+		return nil, err
+	}
+	f, err := toFloat("int", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return math.Trunc(f), nil
+}
+
+// exprFloat converts a value to a number
+func exprFloat(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("float", args, 1); err != nil {
+		return nil, err
+	}
+	return toFloat("float", args[0])
+}
+
+// exprString converts a value to a string. Lists and objects are converted to JSON.
+func exprString(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("string", args, 1); err != nil {
+		return nil, err
+	}
+	return toString(args[0]), nil
+}
+
+func mathFunction(name string, f func(float64) float64) govaluate.ExpressionFunction {
+	return func(args ...interface{}) (interface{}, error) {
+		if err := exprArgs(name, args, 1); err != nil {
+			return nil, err
+		}
+		x, err := toFloat(name, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return f(x), nil
+	}
+}
+
+var (
+	exprAbs   = mathFunction("abs", math.Abs)
+	exprFloor = mathFunction("floor", math.Floor)
+	exprCeil  = mathFunction("ceil", math.Ceil)
+	exprRound = mathFunction("round", math.Round)
+)
+
+func extremumFunction(name string, better func(x, y float64) bool) govaluate.ExpressionFunction {
+	return func(args ...interface{}) (interface{}, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s() expects at least 1 argument", name)
+		}
+		var result float64
+		for i, arg := range args {
+			x, err := toFloat(name, arg)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 || better(x, result) {
+				result = x
+			}
+		}
+		return result, nil
+	}
+}
+
+var (
+	exprMin = extremumFunction("min", func(x, y float64) bool { return x < y })
+	exprMax = extremumFunction("max", func(x, y float64) bool { return x > y })
+)
+
+// exprNow returns the current time, in RFC3339 format
+func exprNow(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("now", args, 0); err != nil {
+		return nil, err
+	}
+	return time.Now().UTC().Format(time.RFC3339), nil
+}
+
+// exprStrftime formats a RFC3339 time, e.g. `strftime('%Y-%m-%d', workflow.creationTimestamp.RFC3339)`
+func exprStrftime(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("strftime", args, 2); err != nil {
+		return nil, err
+	}
+	t, err := toTime("strftime", args[1])
+	if err != nil {
+		return nil, err
+	}
+	return strftime.Format(toString(args[0]), t), nil
+}
+
+// exprTimeAdd adds a duration, e.g. `-1h30m`, to a RFC3339 time
+func exprTimeAdd(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("timeAdd", args, 2); err != nil {
+		return nil, err
+	}
+	t, err := toTime("timeAdd", args[0])
+	if err != nil {
+		return nil, err
+	}
+	d, err := time.ParseDuration(toString(args[1]))
+	if err != nil {
+		return nil, fmt.Errorf("timeAdd(): %v", err)
+	}
+	return t.Add(d).Format(time.RFC3339), nil
+}
+
+// exprJSONPath evaluates a JSONPath, e.g. `$.items[0].name` or `{.items[0].name}`, against a JSON
+// document. It returns the value found, or the list of values found if there are several.
+func exprJSONPath(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("jsonpath", args, 2); err != nil {
+		return nil, err
+	}
+	// lists and objects, e.g. the typed outputs of a when expression, are converted back to JSON
+	doc := toString(args[0])
+	path, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("jsonpath() expects a string path, got %v", args[1])
+	}
+	var data interface{}
+	if err := json.Unmarshal([]byte(doc), &data); err != nil {
+		return nil, fmt.Errorf("jsonpath(): invalid JSON '%s': %v", doc, err)
+	}
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	jp := jsonpath.New("expression")
+	if err := jp.Parse(path); err != nil {
+		return nil, fmt.Errorf("jsonpath(): %v", err)
+	}
+	results, err := jp.FindResults(data)
+	if err != nil {
+		return nil, fmt.Errorf("jsonpath(): %v", err)
+	}
+	var values []interface{}
+	for _, result := range results {
+		for _, val := range result {
+			value := val.Interface()
+			if list, ok := value.([]interface{}); ok {
+				value = exprList(list)
+			}
+			values = append(values, value)
+		}
+	}
+	switch len(values) {
+	case 0:
+		return nil, fmt.Errorf("jsonpath(): '%s' not found", args[1])
+	case 1:
+		return values[0], nil
+	}
+	return exprList(values), nil
+}
+
+// exprToJSON converts a value to JSON
+func exprToJSON(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("toJson", args, 1); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("toJson(): %v", err)
+	}
+	return string(b), nil
+}
+
+func exprBase64Encode(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("base64Encode", args, 1); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(toString(args[0]))), nil
+}
+
+func exprBase64Decode(args ...interface{}) (interface{}, error) {
+	if err := exprArgs("base64Decode", args, 1); err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(toString(args[0]))
+	if err != nil {
+		return nil, fmt.Errorf("base64Decode(): %v", err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,88 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasttemplate"
+	apiv1 "k8s.io/api/core/v1"
+
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+)
+
+func TestEvaluateExpressionTag(t *testing.T) {
+	replaceMap := map[string]string{
+		"inputs.parameters.sample-id": "  SAMPLE-42 ",
+		"inputs.parameters.index":     "3",
+		"inputs.parameters.config":    `{"reads": {"length": 150}, "lanes": [1, 2]}`,
+		"inputs.parameters.quote":     `it's \ here`,
+		"item":                        "a,b,c",
+		"workflow.creationTimestamp":  "2019-07-04 10:30:00 +0000 UTC",
+	}
+	expressions := map[string]string{
+		"=lower(trim(inputs.parameters.sample-id))":                             "sample-42",
+		"=int(inputs.parameters.index) + 1":                                     "4",
+		"=float(inputs.parameters.index) * 2.5":                                 "7.5",
+		"=jsonpath(inputs.parameters.config, '$.reads.length')":                 "150",
+		"=jsonpath(inputs.parameters.config, '$.lanes')":                        "[1,2]",
+		"=join(split(item, ','), '-')":                                          "a-b-c",
+		"=len(split(item, ','))":                                                "3",
+		"=replace(upper(item), ',', '')":                                        "ABC",
+		"=substr(inputs.parameters.index + 'abc', 1, 10)":                       "abc",
+		"=max(1, int(inputs.parameters.index), 2)":                              "3",
+		"=round(10 / 3)":                                                        "3",
+		"=strftime('%Y/%m/%d', workflow.creationTimestamp)":                     "2019/07/04",
+		"=timeAdd(workflow.creationTimestamp, '-1h')":                           "2019-07-04T09:30:00Z",
+		"=base64Decode(base64Encode(inputs.parameters.quote))":                  `it's \ here`,
+		"=hasPrefix(item, 'a,') && contains(inputs.parameters.sample-id, '42')": "true",
+		`=toJson(split(item, ','))`:                                             `["a","b","c"]`,
+	}
+	for tag, expected := range expressions {
+		res, ok, err := EvaluateExpressionTag(tag, replaceMap)
+		if assert.NoError(t, err, tag) {
+			assert.True(t, ok, tag)
+			assert.Equal(t, expected, res, tag)
+		}
+	}
+
+	// the resolved variables of an expression are substituted until the others are resolved
+	res, ok, err := EvaluateExpressionTag("=inputs.parameters.quote + steps.a.outputs.result", replaceMap)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, `='it\'s \\ here' + steps.a.outputs.result`, res)
+	res, ok, err = EvaluateExpressionTag(res, map[string]string{"steps.a.outputs.result": "!"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, `it's \ here!`, res)
+
+	_, _, err = EvaluateExpressionTag("=uppercase(item)", replaceMap)
+	assert.EqualError(t, err, "Invalid expression 'uppercase(item)': unknown function 'uppercase'")
+	_, _, err = EvaluateExpressionTag("=int(item)", replaceMap)
+	assert.EqualError(t, err, "Failed to evaluate expression 'int(item)': int(): 'a,b,c' is not a number")
+}
+
+func TestReplaceExpression(t *testing.T) {
+	fstTmpl := fasttemplate.New(`{"name": "{{=upper(item)}}", "index": "{{=int(inputs.parameters.index) - 1}}", "when": "{{=item == \"a\"}}"}`, "{{", "}}")
+	s, err := Replace(fstTmpl, map[string]string{"item": "a"}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name": "A", "index": "{{=int(inputs.parameters.index) - 1}}", "when": "true"}`, s)
+	_, err = Replace(fstTmpl, map[string]string{"item": "a"}, false)
+	assert.EqualError(t, err, "failed to resolve {{=int(inputs.parameters.index) - 1}}")
+}
+
+func TestSubstituteParamsExpression(t *testing.T) {
+	value := "Sample"
+	tmpl := &wfv1.Template{
+		Name: "main",
+		Inputs: wfv1.Inputs{
+			Parameters: []wfv1.Parameter{{Name: "sample", Value: &value}},
+		},
+		Container: &apiv1.Container{
+			Image: "alpine:latest",
+			Args:  []string{"{{=workflow.name + '/' + lower(inputs.parameters.sample)}}"},
+		},
+	}
+	newTmpl, err := SubstituteParams(tmpl, map[string]string{"workflow.name": "my-wf"}, map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"my-wf/sample"}, newTmpl.Container.Args)
+}
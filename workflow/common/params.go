@@ -0,0 +1,118 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cyrusbiotechnology/argo/errors"
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+)
+
+// parameterType returns the type of the values of an input parameter
+func parameterType(param wfv1.Parameter) wfv1.ParameterType {
+	if param.Type == "" {
+		if param.Schema != nil {
+			return wfv1.ParameterTypeJSON
+		}
+		return wfv1.ParameterTypeString
+	}
+	return param.Type
+}
+
+// ValidateParameterConstraints validates the enum, pattern, type and schema of an input parameter.
+// The values of the enum must satisfy the other constraints.
+func ValidateParameterConstraints(param wfv1.Parameter) error {
+	switch param.Type {
+	case "", wfv1.ParameterTypeString, wfv1.ParameterTypeInt, wfv1.ParameterTypeNumber, wfv1.ParameterTypeBool, wfv1.ParameterTypeJSON:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "inputs.parameters.%s.type '%s' is invalid: must be string, int, number, bool or json", param.Name, param.Type)
+	}
+	if param.Pattern != "" {
+		if _, err := regexp.Compile(param.Pattern); err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "inputs.parameters.%s.pattern '%s' is invalid: %v", param.Name, param.Pattern, err)
+		}
+	}
+	if param.Schema != nil {
+		if err := checkSchema("$", param.Schema.Value); err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "inputs.parameters.%s.schema is invalid: %v", param.Name, err)
+		}
+	}
+	for _, value := range param.Enum {
+		if err := validateParameterType(param, value); err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "inputs.parameters.%s.enum %v", param.Name, err)
+		}
+	}
+	return nil
+}
+
+// ValidateParameterValue validates a value of an input parameter against its enum, pattern, type
+// and schema
+func ValidateParameterValue(param wfv1.Parameter, value string) error {
+	if len(param.Enum) > 0 {
+		allowed := false
+		for _, v := range param.Enum {
+			if v == value {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return errors.Errorf(errors.CodeBadRequest, "inputs.parameters.%s value '%s' is not one of the allowed values: %s", param.Name, value, strings.Join(param.Enum, ", "))
+		}
+	}
+	if err := validateParameterType(param, value); err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "inputs.parameters.%s %v", param.Name, err)
+	}
+	return nil
+}
+
+// validateParameterType validates a value against the pattern, type and schema of a parameter
+func validateParameterType(param wfv1.Parameter, value string) error {
+	if param.Pattern != "" {
+		re, err := regexp.Compile(param.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern '%s' is invalid: %v", param.Pattern, err)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("value '%s' does not match the pattern '%s'", value, param.Pattern)
+		}
+	}
+	var decoded interface{}
+	switch parameterType(param) {
+	case wfv1.ParameterTypeString:
+		decoded = value
+	case wfv1.ParameterTypeInt:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("value '%s' is not an int", value)
+		}
+		decoded = float64(i)
+	case wfv1.ParameterTypeNumber:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("value '%s' is not a number", value)
+		}
+		decoded = f
+	case wfv1.ParameterTypeBool:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("value '%s' is not a bool: must be true or false", value)
+		}
+		decoded = value == "true"
+	case wfv1.ParameterTypeJSON:
+		err := json.Unmarshal([]byte(value), &decoded)
+		if err != nil {
+			return fmt.Errorf("value '%s' is not JSON: %v", value, err)
+		}
+	}
+	if param.Schema != nil {
+		if err := checkSchema("$", param.Schema.Value); err != nil {
+			return fmt.Errorf("schema is invalid: %v", err)
+		}
+		if err := validateSchema("$", param.Schema.Value, decoded); err != nil {
+			return fmt.Errorf("value does not conform to its schema: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+)
+
+func unmarshalParam(t *testing.T, yamlStr string) wfv1.Parameter {
+	var param wfv1.Parameter
+	err := yaml.Unmarshal([]byte(yamlStr), &param)
+	assert.NoError(t, err)
+	return param
+}
+
+func TestValidateParameterValue(t *testing.T) {
+	reference := unmarshalParam(t, `{name: reference, enum: [hg19, hg38]}`)
+	assert.NoError(t, ValidateParameterValue(reference, "hg38"))
+	assert.EqualError(t, ValidateParameterValue(reference, "hg39"), "inputs.parameters.reference value 'hg39' is not one of the allowed values: hg19, hg38")
+
+	sample := unmarshalParam(t, `{name: sample, pattern: "^S[0-9]+$"}`)
+	assert.NoError(t, ValidateParameterValue(sample, "S42"))
+	assert.EqualError(t, ValidateParameterValue(sample, "42"), "inputs.parameters.sample value '42' does not match the pattern '^S[0-9]+$'")
+
+	for paramType, values := range map[wfv1.ParameterType][2]string{
+		wfv1.ParameterTypeInt:    {"-3", "1.5"},
+		wfv1.ParameterTypeNumber: {"1.5e3", "one"},
+		wfv1.ParameterTypeBool:   {"false", "yes"},
+		wfv1.ParameterTypeJSON:   {`{"a": [1]}`, "{a}"},
+	} {
+		param := wfv1.Parameter{Name: "p", Type: paramType}
+		assert.NoError(t, ValidateParameterValue(param, values[0]), string(paramType))
+		assert.Error(t, ValidateParameterValue(param, values[1]), string(paramType))
+	}
+	assert.EqualError(t, ValidateParameterValue(wfv1.Parameter{Name: "threads", Type: wfv1.ParameterTypeInt}, "four"), "inputs.parameters.threads value 'four' is not an int")
+}
+
+func TestValidateParameterSchema(t *testing.T) {
+	config := unmarshalParam(t, `
+name: config
+schema:
+  type: object
+  required: [reads]
+  properties:
+    reads:
+      type: array
+      minItems: 1
+      items:
+        type: object
+        properties:
+          length: {type: integer, minimum: 1}
+          strand: {enum: [forward, reverse]}
+        additionalProperties: false
+`)
+	assert.NoError(t, ValidateParameterConstraints(config))
+	assert.NoError(t, ValidateParameterValue(config, `{"reads": [{"length": 150, "strand": "forward"}]}`))
+	for value, message := range map[string]string{
+		`{}`:                                 "$: property 'reads' is required",
+		`{"reads": []}`:                      "$.reads: has fewer than 1 items",
+		`{"reads": [{"length": 0}]}`:         "$.reads[0].length: 0 is less than the minimum of 1",
+		`{"reads": [{"length": 1.5}]}`:       "$.reads[0].length: 1.5 is not of type integer",
+		`{"reads": [{"strand": "both"}]}`:    `$.reads[0].strand: "both" is not one of: "forward", "reverse"`,
+		`{"reads": [{"length": 1, "x": 1}]}`: "$.reads[0]: property 'x' is not allowed",
+	} {
+		assert.EqualError(t, ValidateParameterValue(config, value), "inputs.parameters.config value does not conform to its schema: "+message, value)
+	}
+
+	// the schema applies to the value decoded according to the type
+	threads := unmarshalParam(t, `{name: threads, type: int, schema: {maximum: 16}}`)
+	assert.NoError(t, ValidateParameterValue(threads, "16"))
+	assert.EqualError(t, ValidateParameterValue(threads, "32"), "inputs.parameters.threads value does not conform to its schema: $: 32 is greater than the maximum of 16")
+	name := unmarshalParam(t, `{name: name, type: string, schema: {anyOf: [{maxLength: 3}, {pattern: "^x"}]}}`)
+	assert.NoError(t, ValidateParameterValue(name, "abc"))
+	assert.NoError(t, ValidateParameterValue(name, "xabc"))
+	assert.Error(t, ValidateParameterValue(name, "abcd"))
+}
+
+func TestValidateParameterConstraints(t *testing.T) {
+	for paramStr, message := range map[string]string{
+		`{name: p, type: integer}`:                           "inputs.parameters.p.type 'integer' is invalid: must be string, int, number, bool or json",
+		`{name: p, pattern: "("}`:                            "inputs.parameters.p.pattern '(' is invalid: error parsing regexp: missing closing ): `(`",
+		`{name: p, schema: {type: text}}`:                    "inputs.parameters.p.schema is invalid: $.type: 'text' is not a JSON type",
+		`{name: p, schema: {items: {minimum: a}}}`:           "inputs.parameters.p.schema is invalid: $.items.minimum: must be a number",
+		`{name: p, schema: {format: date}}`:                  "inputs.parameters.p.schema is invalid: $.format: unsupported keyword",
+		`{name: p, schema: {properties: {a: {minimun: 1}}}}`: "inputs.parameters.p.schema is invalid: $.properties.a.minimun: unsupported keyword",
+		`{name: p, schema: {uniqueItems: "yes"}}`:            "inputs.parameters.p.schema is invalid: $.uniqueItems: must be a boolean",
+		`{name: p, type: int, enum: ["1", "a"]}`:             "inputs.parameters.p.enum value 'a' is not an int",
+	} {
+		assert.EqualError(t, ValidateParameterConstraints(unmarshalParam(t, paramStr)), message, paramStr)
+	}
+	// the annotation keywords are accepted
+	assert.NoError(t, ValidateParameterConstraints(unmarshalParam(t, `{name: p, schema: {title: Threads, description: number of threads, maximum: 16}}`)))
+}
+
+func TestProcessArgsParameterValue(t *testing.T) {
+	tmpl := &wfv1.Template{
+		Name: "align",
+		Inputs: wfv1.Inputs{
+			Parameters: []wfv1.Parameter{{Name: "reference", Enum: []string{"hg19", "hg38"}}},
+		},
+		Container: &apiv1.Container{Image: "alpine:latest"},
+	}
+	value := "{{workflow.parameters.reference}}"
+	args := &wfv1.Arguments{Parameters: []wfv1.Parameter{{Name: "reference", Value: &value}}}
+	_, err := ProcessArgs(tmpl, args, map[string]string{"workflow.parameters.reference": "hg38"}, map[string]string{}, false)
+	assert.NoError(t, err)
+	_, err = ProcessArgs(tmpl, args, map[string]string{"workflow.parameters.reference": "hg39"}, map[string]string{}, false)
+	assert.EqualError(t, err, "inputs.parameters.reference value 'hg39' is not one of the allowed values: hg19, hg38")
+}
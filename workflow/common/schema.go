@@ -0,0 +1,370 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// schemaTypes are the types of the type keyword
+var schemaTypes = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"object":  true,
+	"array":   true,
+	"number":  true,
+	"string":  true,
+	"integer": true,
+}
+
+// schemaKeywords are the keywords supported by validateSchema, along with the annotation keywords
+// which do not constrain the values
+var schemaKeywords = map[string]bool{
+	"type":                 true,
+	"enum":                 true,
+	"const":                true,
+	"minimum":              true,
+	"maximum":              true,
+	"exclusiveMinimum":     true,
+	"exclusiveMaximum":     true,
+	"minLength":            true,
+	"maxLength":            true,
+	"pattern":              true,
+	"items":                true,
+	"minItems":             true,
+	"maxItems":             true,
+	"uniqueItems":          true,
+	"properties":           true,
+	"required":             true,
+	"additionalProperties": true,
+	"allOf":                true,
+	"anyOf":                true,
+	"oneOf":                true,
+	"not":                  true,
+	"$schema":              true,
+	"$id":                  true,
+	"$comment":             true,
+	"title":                true,
+	"description":          true,
+	"default":              true,
+	"examples":             true,
+}
+
+// checkSchema checks that a JSON schema is well-formed. The keywords which validateSchema does not
+// support are rejected rather than ignored, so that no constraint is silently left unchecked.
+func checkSchema(path string, schema interface{}) error {
+	if _, ok := schema.(bool); ok {
+		return nil
+	}
+	obj, ok := schema.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s: a schema must be an object or a boolean", path)
+	}
+	keywords := make([]string, 0, len(obj))
+	for keyword := range obj {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		if !schemaKeywords[keyword] {
+			return fmt.Errorf("%s.%s: unsupported keyword", path, keyword)
+		}
+	}
+	if t, ok := obj["type"]; ok {
+		var types []interface{}
+		switch t := t.(type) {
+		case string:
+			types = []interface{}{t}
+		case []interface{}:
+			types = t
+		}
+		if len(types) == 0 {
+			return fmt.Errorf("%s.type: must be a type or a list of types", path)
+		}
+		for _, t := range types {
+			if name, ok := t.(string); !ok || !schemaTypes[name] {
+				return fmt.Errorf("%s.type: '%v' is not a JSON type", path, t)
+			}
+		}
+	}
+	if enum, ok := obj["enum"]; ok {
+		if _, ok := enum.([]interface{}); !ok {
+			return fmt.Errorf("%s.enum: must be a list", path)
+		}
+	}
+	for _, keyword := range []string{"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "minLength", "maxLength", "minItems", "maxItems"} {
+		if v, ok := obj[keyword]; ok {
+			if _, ok := v.(float64); !ok {
+				return fmt.Errorf("%s.%s: must be a number", path, keyword)
+			}
+		}
+	}
+	if unique, ok := obj["uniqueItems"]; ok {
+		if _, ok := unique.(bool); !ok {
+			return fmt.Errorf("%s.uniqueItems: must be a boolean", path)
+		}
+	}
+	if pattern, ok := obj["pattern"]; ok {
+		s, ok := pattern.(string)
+		if !ok {
+			return fmt.Errorf("%s.pattern: must be a string", path)
+		}
+		if _, err := regexp.Compile(s); err != nil {
+			return fmt.Errorf("%s.pattern: %v", path, err)
+		}
+	}
+	if required, ok := obj["required"]; ok {
+		names, ok := required.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s.required: must be a list of property names", path)
+		}
+		for _, name := range names {
+			if _, ok := name.(string); !ok {
+				return fmt.Errorf("%s.required: must be a list of property names", path)
+			}
+		}
+	}
+	if properties, ok := obj["properties"]; ok {
+		props, ok := properties.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s.properties: must be an object", path)
+		}
+		for name, prop := range props {
+			if err := checkSchema(fmt.Sprintf("%s.properties.%s", path, name), prop); err != nil {
+				return err
+			}
+		}
+	}
+	for _, keyword := range []string{"items", "additionalProperties", "not"} {
+		if sub, ok := obj[keyword]; ok {
+			if err := checkSchema(path+"."+keyword, sub); err != nil {
+				return err
+			}
+		}
+	}
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		if subs, ok := obj[keyword]; ok {
+			list, ok := subs.([]interface{})
+			if !ok || len(list) == 0 {
+				return fmt.Errorf("%s.%s: must be a non-empty list of schemas", path, keyword)
+			}
+			for i, sub := range list {
+				if err := checkSchema(fmt.Sprintf("%s.%s[%d]", path, keyword, i), sub); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// schemaTypeOf returns the JSON type of a decoded JSON value
+func schemaTypeOf(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// formatJSON formats a decoded JSON value in error messages
+func formatJSON(value interface{}) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(b)
+}
+
+// validateSchema validates a decoded JSON value against a well-formed JSON schema. The path locates
+// the value in the errors, e.g. $.samples[1].reads. The keywords which are checked are type, enum,
+// const, minimum, maximum, exclusiveMinimum, exclusiveMaximum, minLength, maxLength, pattern, items,
+// minItems, maxItems, uniqueItems, properties, required, additionalProperties, allOf, anyOf, oneOf
+// and not. The annotation keywords, such as title or description, are ignored, and the other keywords
+// are rejected by checkSchema.
+func validateSchema(path string, schema interface{}, value interface{}) error {
+	if accept, ok := schema.(bool); ok {
+		if !accept {
+			return fmt.Errorf("%s: no value is allowed", path)
+		}
+		return nil
+	}
+	obj := schema.(map[string]interface{})
+
+	if t, ok := obj["type"]; ok {
+		var types []string
+		switch t := t.(type) {
+		case string:
+			types = []string{t}
+		case []interface{}:
+			for _, name := range t {
+				types = append(types, name.(string))
+			}
+		}
+		valueType := schemaTypeOf(value)
+		matched := false
+		for _, name := range types {
+			if name == valueType || (name == "number" && valueType == "integer") {
+				matched = true
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: %s is not of type %s", path, formatJSON(value), strings.Join(types, " or "))
+		}
+	}
+	if enum, ok := obj["enum"]; ok {
+		allowed := enum.([]interface{})
+		matched := false
+		for _, v := range allowed {
+			if reflect.DeepEqual(v, value) {
+				matched = true
+			}
+		}
+		if !matched {
+			values := make([]string, len(allowed))
+			for i, v := range allowed {
+				values[i] = formatJSON(v)
+			}
+			return fmt.Errorf("%s: %s is not one of: %s", path, formatJSON(value), strings.Join(values, ", "))
+		}
+	}
+	if c, ok := obj["const"]; ok && !reflect.DeepEqual(c, value) {
+		return fmt.Errorf("%s: %s is not %s", path, formatJSON(value), formatJSON(c))
+	}
+
+	switch value := value.(type) {
+	case float64:
+		if min, ok := obj["minimum"].(float64); ok && value < min {
+			return fmt.Errorf("%s: %v is less than the minimum of %v", path, value, min)
+		}
+		if max, ok := obj["maximum"].(float64); ok && value > max {
+			return fmt.Errorf("%s: %v is greater than the maximum of %v", path, value, max)
+		}
+		if min, ok := obj["exclusiveMinimum"].(float64); ok && value <= min {
+			return fmt.Errorf("%s: %v must be greater than %v", path, value, min)
+		}
+		if max, ok := obj["exclusiveMaximum"].(float64); ok && value >= max {
+			return fmt.Errorf("%s: %v must be less than %v", path, value, max)
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(value))
+		if min, ok := obj["minLength"].(float64); ok && length < min {
+			return fmt.Errorf("%s: '%s' is shorter than %v characters", path, value, min)
+		}
+		if max, ok := obj["maxLength"].(float64); ok && length > max {
+			return fmt.Errorf("%s: '%s' is longer than %v characters", path, value, max)
+		}
+		if pattern, ok := obj["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(value) {
+			return fmt.Errorf("%s: '%s' does not match the pattern '%s'", path, value, pattern)
+		}
+	case []interface{}:
+		length := float64(len(value))
+		if min, ok := obj["minItems"].(float64); ok && length < min {
+			return fmt.Errorf("%s: has fewer than %v items", path, min)
+		}
+		if max, ok := obj["maxItems"].(float64); ok && length > max {
+			return fmt.Errorf("%s: has more than %v items", path, max)
+		}
+		if unique, ok := obj["uniqueItems"].(bool); ok && unique {
+			for i := range value {
+				for j := 0; j < i; j++ {
+					if reflect.DeepEqual(value[i], value[j]) {
+						return fmt.Errorf("%s: items %d and %d are equal", path, j, i)
+					}
+				}
+			}
+		}
+		if items, ok := obj["items"]; ok {
+			for i, item := range value {
+				if err := validateSchema(fmt.Sprintf("%s[%d]", path, i), items, item); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		if required, ok := obj["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := value[name.(string)]; !ok {
+					return fmt.Errorf("%s: property '%s' is required", path, name)
+				}
+			}
+		}
+		properties, _ := obj["properties"].(map[string]interface{})
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			propPath := path + "." + name
+			if prop, ok := properties[name]; ok {
+				if err := validateSchema(propPath, prop, value[name]); err != nil {
+					return err
+				}
+			} else if additional, ok := obj["additionalProperties"]; ok {
+				if accept, ok := additional.(bool); ok && !accept {
+					return fmt.Errorf("%s: property '%s' is not allowed", path, name)
+				}
+				if err := validateSchema(propPath, additional, value[name]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if subs, ok := obj["allOf"].([]interface{}); ok {
+		for _, sub := range subs {
+			if err := validateSchema(path, sub, value); err != nil {
+				return err
+			}
+		}
+	}
+	if subs, ok := obj["anyOf"].([]interface{}); ok {
+		var firstErr error
+		for _, sub := range subs {
+			err := validateSchema(path, sub, value)
+			if err == nil {
+				firstErr = nil
+				break
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if firstErr != nil {
+			return fmt.Errorf("%s: does not match any of the schemas of anyOf: %v", path, firstErr)
+		}
+	}
+	if subs, ok := obj["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, sub := range subs {
+			if validateSchema(path, sub, value) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s: matches %d of the schemas of oneOf instead of exactly one", path, matches)
+		}
+	}
+	if not, ok := obj["not"]; ok && validateSchema(path, not, value) == nil {
+		return fmt.Errorf("%s: must not match the schema of not", path)
+	}
+	return nil
+}
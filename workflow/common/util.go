@@ -296,7 +296,20 @@ func ProcessArgs(tmpl *wfv1.Template, args wfv1.ArgumentsProvider, globalParams,
 	}
 	newTmpl.Inputs.Artifacts = newInputArtifacts
 
-	return SubstituteParams(newTmpl, globalParams, localParams)
+	newTmpl, err := SubstituteParams(newTmpl, globalParams, localParams)
+	if err != nil {
+		return nil, err
+	}
+	if !validateOnly {
+		// the values are only known once the variables they reference are substituted
+		for _, inParam := range newTmpl.Inputs.Parameters {
+			err = ValidateParameterValue(inParam, *inParam.Value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return newTmpl, nil
 }
 
 // SubstituteParams returns a new copy of the template with global, pod, and input parameters substituted
@@ -353,8 +366,20 @@ func SubstituteParams(tmpl *wfv1.Template, globalParams, localParams map[string]
 		}
 	}
 
+	// the expressions may reference both the globals and the inputs
+	exprMap := make(map[string]string)
+	for k, v := range globalParams {
+		exprMap[k] = v
+	}
+	for k, v := range localParams {
+		exprMap[k] = v
+	}
+	for k, v := range replaceMap {
+		exprMap[k] = v
+	}
+
 	fstTmpl = fasttemplate.New(globalReplacedTmplStr, "{{", "}}")
-	s, err := Replace(fstTmpl, replaceMap, true)
+	s, err := replace(fstTmpl, replaceMap, exprMap, true)
 	if err != nil {
 		return nil, err
 	}
@@ -367,12 +392,33 @@ func SubstituteParams(tmpl *wfv1.Template, globalParams, localParams map[string]
 }
 
 // Replace executes basic string substitution of a template with replacement values.
-// allowUnresolved indicates whether or not it is acceptable to have unresolved variables
-// remaining in the substituted template.
+// The `{{=expr}}` tags are replaced by the result of their expression, evaluated against the
+// replacement values. allowUnresolved indicates whether or not it is acceptable to have unresolved
+// variables remaining in the substituted template.
 func Replace(fstTmpl *fasttemplate.Template, replaceMap map[string]string, allowUnresolved bool) (string, error) {
+	return replace(fstTmpl, replaceMap, replaceMap, allowUnresolved)
+}
+
+// replace is Replace, with the expressions evaluated against exprMap
+func replace(fstTmpl *fasttemplate.Template, replaceMap, exprMap map[string]string, allowUnresolved bool) (string, error) {
 	var unresolvedErr error
 	replacedTmpl := fstTmpl.ExecuteFuncString(func(w io.Writer, tag string) (int, error) {
 		replacement, ok := replaceMap[tag]
+		if strings.HasPrefix(tag, "=") {
+			var err error
+			replacement, ok, err = EvaluateExpressionTag(tag, exprMap)
+			if err != nil {
+				if unresolvedErr == nil {
+					unresolvedErr = err
+				}
+				return 0, nil
+			}
+			if !ok {
+				// the tag is written back with the variables which are resolved substituted
+				tag = strconv.Quote(replacement)
+				tag = tag[1 : len(tag)-1]
+			}
+		}
 		if !ok {
 			if allowUnresolved {
 				// just write the same string back
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/cyrusbiotechnology/argo/errors"
+)
+
+// whenRoots are the roots of the variables which can be referenced in a typed when expression
+var whenRoots = map[string]bool{
+	"inputs":   true,
+	"steps":    true,
+	"tasks":    true,
+	"workflow": true,
+}
+
+// isWhenVariable returns whether or not an identifier is a variable of a typed when expression
+func isWhenVariable(ident string) bool {
+	parts := strings.SplitN(ident, ".", 2)
+	return len(parts) == 2 && whenRoots[parts[0]]
+}
+
+// IsTypedWhen returns whether or not a when expression uses the typed syntax, i.e. references
+// variables such as `steps.A.outputs.result` without braces, or calls a function. The expressions
+// which do not are evaluated by the legacy string comparison.
+func IsTypedWhen(when string) bool {
+	if when == "" || strings.Contains(when, "{{") {
+		return false
+	}
+	_, variables, functions := rewriteExpression(when, isWhenVariable, func(ident string) string {
+		return ident
+	})
+	if len(variables) > 0 {
+		return true
+	}
+	for _, function := range functions {
+		if _, ok := ExpressionFunctions[function]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseWhen parses a typed when expression
+func ParseWhen(when string) (*Expression, error) {
+	return parseExpression("'when' expression", when, isWhenVariable)
+}
+
+// EvaluateWhen parses and evaluates a typed when expression
+func EvaluateWhen(when string, context map[string]interface{}) (bool, error) {
+	expression, err := ParseWhen(when)
+	if err != nil {
+		return false, err
+	}
+	result, err := expression.Evaluate(context)
+	if err != nil {
+		return false, err
+	}
+	boolRes, ok := result.(bool)
+	if !ok {
+		return false, errors.Errorf(errors.CodeBadRequest, "Expected boolean evaluation for '%s'. Got %v", when, result)
+	}
+	return boolRes, nil
+}
+
+// WhenValue returns the typed value of a variable of a when expression, given its string value: a
+// number if the string is the canonical form of one (e.g. "5" but not "1.10"), a boolean, or a list
+// or object if it holds JSON, such as the aggregated outputs of a loop. Other strings are returned
+// as is.
+func WhenValue(value string) interface{} {
+	trimmed := strings.TrimSpace(value)
+	switch {
+	case trimmed == "true":
+		return true
+	case trimmed == "false":
+		return false
+	case strings.HasPrefix(trimmed, "["):
+		if list, ok := toList(trimmed); ok {
+			return list
+		}
+	case strings.HasPrefix(trimmed, "{"):
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(trimmed), &object); err == nil {
+			return object
+		}
+	default:
+		if f, err := strconv.ParseFloat(trimmed, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == trimmed {
+			return f
+		}
+	}
+	return value
+}
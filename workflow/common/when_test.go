@@ -0,0 +1,113 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTypedWhen(t *testing.T) {
+	assert.True(t, IsTypedWhen("steps.flip-coin.outputs.result == 'heads'"))
+	assert.True(t, IsTypedWhen("inputs.parameters.count > 3"))
+	assert.True(t, IsTypedWhen("matches('v1.2', 'v1\\\\..*')"))
+	assert.False(t, IsTypedWhen("heads == heads"))
+	assert.False(t, IsTypedWhen("'steps.a.outputs.result' == foo"))
+	assert.False(t, IsTypedWhen("{{steps.a.outputs.result}} == foo"))
+	assert.False(t, IsTypedWhen("Error in (Failed, Error)"))
+	assert.False(t, IsTypedWhen(""))
+}
+
+func TestParseWhen(t *testing.T) {
+	expression, err := ParseWhen("steps.a-b.exitCode == 0 && (steps.a-b.status == 'Succeeded' || [tasks.c.ip] != '')")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"steps.a-b.exitCode", "steps.a-b.status"}, expression.Variables)
+
+	_, err = ParseWhen("steps.a.exitCode ==")
+	assert.Error(t, err)
+}
+
+func TestEvaluateWhen(t *testing.T) {
+	context := map[string]interface{}{
+		"steps.gen-list.outputs.result":    `{"items": [{"name": "a", "size": 3}, {"name": "b", "size": 12}]}`,
+		"steps.gen-list.exitCode":          float64(0),
+		"steps.loop.outputs.parameters.id": `["x","y"]`,
+		"inputs.parameters.count":          "5",
+		"inputs.parameters.branch":         "release/v1.2",
+		"workflow.status":                  "Succeeded",
+	}
+	trueExpressions := []string{
+		"steps.gen-list.exitCode == 0",
+		"int(inputs.parameters.count) > 3",
+		"float(inputs.parameters.count) / 2 == 2.5",
+		"matches(inputs.parameters.branch, '^release/v[0-9]+')",
+		"jsonpath(steps.gen-list.outputs.result, '$.items[1].size') > 10",
+		"jsonpath(steps.gen-list.outputs.result, '$.items[0].name') == 'a'",
+		"len(jsonpath(steps.gen-list.outputs.result, '$.items')) == 2",
+		"len(jsonpath(steps.gen-list.outputs.result, '$.items[*].name')) == 2",
+		"contains(steps.loop.outputs.parameters.id, 'y')",
+		"contains(inputs.parameters.branch, 'v1')",
+		"contains(jsonpath(steps.gen-list.outputs.result, '$.items[0]'), 'size')",
+		"workflow.status in ('Succeeded', 'Failed')",
+		"string(steps.gen-list.exitCode) == '0'",
+	}
+	for _, exp := range trueExpressions {
+		res, err := EvaluateWhen(exp, context)
+		if assert.NoError(t, err, exp) {
+			assert.True(t, res, exp)
+		}
+	}
+	falseExpressions := []string{
+		"steps.gen-list.exitCode != 0",
+		"inputs.parameters.count == 5",
+		"contains(steps.loop.outputs.parameters.id, 'z')",
+		"matches(inputs.parameters.branch, '^master$')",
+	}
+	for _, exp := range falseExpressions {
+		res, err := EvaluateWhen(exp, context)
+		if assert.NoError(t, err, exp) {
+			assert.False(t, res, exp)
+		}
+	}
+
+	_, err := EvaluateWhen("steps.missing.exitCode == 0", context)
+	assert.EqualError(t, err, "Invalid 'when' expression 'steps.missing.exitCode == 0': unresolved variable 'steps.missing.exitCode'")
+	_, err = EvaluateWhen("int(inputs.parameters.branch) > 0", context)
+	assert.Error(t, err)
+	_, err = EvaluateWhen("len(steps.gen-list.exitCode)", context)
+	assert.Error(t, err)
+	_, err = EvaluateWhen("int(inputs.parameters.count)", context)
+	assert.EqualError(t, err, "Expected boolean evaluation for 'int(inputs.parameters.count)'. Got 5")
+}
+
+func TestWhenValue(t *testing.T) {
+	assert.Equal(t, float64(5), WhenValue("5"))
+	assert.Equal(t, float64(-2.5), WhenValue("-2.5"))
+	assert.Equal(t, "1.10", WhenValue("1.10"))
+	assert.Equal(t, "007", WhenValue("007"))
+	assert.Equal(t, true, WhenValue("true"))
+	assert.Equal(t, exprList{"x", float64(2)}, WhenValue(`["x", 2]`))
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, WhenValue(`{"a": 1}`))
+	assert.Equal(t, "[not json", WhenValue("[not json"))
+	assert.Equal(t, "release/v1", WhenValue("release/v1"))
+
+	context := map[string]interface{}{
+		"inputs.parameters.n":             WhenValue("5"),
+		"steps.a.outputs.result":          WhenValue("5"),
+		"steps.b.outputs.result":          WhenValue(`{"items": [{"name": "a"}]}`),
+		"steps.loop.outputs.parameters":   WhenValue(`["x","y","z"]`),
+		"steps.loop.outputs.parameters.n": `["x","y","z"]`,
+	}
+	for _, exp := range []string{
+		"inputs.parameters.n > 3",
+		"steps.a.outputs.result == 5",
+		"jsonpath(steps.b.outputs.result, '$.items[0].name') == 'a'",
+		"len(steps.loop.outputs.parameters) == 3",
+		"len(steps.loop.outputs.parameters.n) == 3",
+		"contains(steps.loop.outputs.parameters, 'y')",
+	} {
+		res, err := EvaluateWhen(exp, context)
+		if assert.NoError(t, err, exp) {
+			assert.True(t, res, exp)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
 	"github.com/cyrusbiotechnology/argo/workflow/metrics"
+	"github.com/cyrusbiotechnology/argo/workflow/tracing"
 	apiv1 "k8s.io/api/core/v1"
 )
 
@@ -54,9 +55,32 @@ type WorkflowControllerConfig struct {
 
 	TelemetryConfig metrics.PrometheusConfig `json:"telemetryConfig,omitempty"`
 
+	// TracingConfig configures the export of workflow execution traces
+	TracingConfig tracing.Config `json:"tracing,omitempty"`
+
 	// Parallelism limits the max total parallel workflows that can execute at the same time
 	Parallelism int `json:"parallelism,omitempty"`
 
+	// FairShare shares the workflow parallelism between groups of workflows
+	FairShare *FairShareConfig `json:"fairShare,omitempty"`
+
+	// Namespaces contains the settings specific to the workflows of a namespace, keyed by namespace
+	Namespaces map[string]NamespaceConfig `json:"namespaces,omitempty"`
+
+	// InfrastructureRetryLimit is the default maximum number of attempts when retrying a pod which
+	// failed because of the infrastructure (e.g. evicted, preempted or lost with its node). It applies
+	// to the retry strategies without infrastructureLimit, and to the leaf templates without retry
+	// strategy, whose pod is then recreated in place
+	InfrastructureRetryLimit *int32 `json:"infrastructureRetryLimit,omitempty"`
+
+	// PendingPolicy fails the nodes whose pod is pending for too long, or for a fatal reason. It can
+	// be overridden by the pendingPolicy of the templates
+	PendingPolicy *wfv1.PendingPolicy `json:"pendingPolicy,omitempty"`
+
+	// MaxLoopArtifactItems limits the number of items a loop over the items of an artifact
+	// (withArtifact) can expand to (default: 10000)
+	MaxLoopArtifactItems int `json:"maxLoopArtifactItems,omitempty"`
+
 	// Persistence contains the workflow persistence DB configuration
 	Persistence *PersistConfig `json:"persistence,omitempty"`
 
@@ -64,6 +88,68 @@ type WorkflowControllerConfig struct {
 	DockerSockPath string `json:"dockerSockPath,omitempty"`
 }
 
+// FairShareConfig configures the fair sharing of the workflow parallelism between groups of workflows.
+// Pending workflows are picked from the groups in a weighted round-robin fashion, and by priority
+// and creation time within a group.
+type FairShareConfig struct {
+	// GroupBy is the list of keys identifying the group of a workflow. A key is either the name of a
+	// workflow label (e.g. user, project-id) or "namespace" for the namespace of the workflow.
+	// Workflows are not grouped if empty
+	GroupBy []string `json:"groupBy,omitempty"`
+
+	// MaxConcurrency limits the number of workflows of a single group running at the same time.
+	// Zero means no limit other than the global parallelism
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+
+	// Groups overrides the settings of specific groups, keyed by group name. The name of a group is the
+	// value of its GroupBy keys joined by '/'
+	Groups map[string]FairShareGroup `json:"groups,omitempty"`
+}
+
+// FairShareGroup contains the fair share settings of a group of workflows
+type FairShareGroup struct {
+	// MaxConcurrency overrides the default max concurrency for the group
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+
+	// Weight is the share of the group in the round-robin between groups. Defaults to 1
+	Weight int `json:"weight,omitempty"`
+}
+
+// NamespaceConfig contains the settings applied to the workflows of a namespace
+type NamespaceConfig struct {
+	// Parallelism limits the max parallel workflows of the namespace that can execute at the same time
+	Parallelism int `json:"parallelism,omitempty"`
+
+	// MaxActivePods limits the max active (Pending/Running) pods across the workflows of the namespace
+	MaxActivePods int64 `json:"maxActivePods,omitempty"`
+
+	// WorkflowDefaults is merged into the spec of every workflow of the namespace before validation
+	WorkflowDefaults *WorkflowDefaults `json:"workflowDefaults,omitempty"`
+}
+
+// WorkflowDefaults is the fragment of a workflow spec used as defaults. Fields set in the workflow
+// take precedence over the defaults.
+type WorkflowDefaults struct {
+	// ServiceAccountName is the name of the ServiceAccount to run all pods of the workflow as
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// NodeSelector is merged with the node selector of the workflow
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations are used if the workflow has no tolerations
+	Tolerations []apiv1.Toleration `json:"tolerations,omitempty"`
+
+	// PodGC describes the strategy to use when to deleting completed pods
+	PodGC *wfv1.PodGC `json:"podGC,omitempty"`
+
+	// TTLSecondsAfterFinished limits the lifetime of a workflow that has finished execution
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+
+	// ActiveDeadlineSeconds is the duration in seconds relative to the workflow start time which
+	// the workflow is allowed to run before the controller terminates it
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+}
+
 // KubeConfig is used for wait & init sidecar containers to communicate with a k8s apiserver by a outofcluster method,
 // it is used when the workflow controller is in a different cluster with the workflow workloads
 type KubeConfig struct {
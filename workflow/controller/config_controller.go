@@ -81,7 +81,15 @@ func (wfc *WorkflowController) updateConfig(configString string) error {
 		log.Info("Persistence configuration disabled")
 		wfc.wfDBctx = nil
 	}
-	wfc.throttler.SetParallelism(config.Parallelism)
+	wfc.updateTracerProvider()
+	wfc.throttler.SetParallelism(config.Parallelism, config.FairShare)
+	namespaceParallelism := make(map[string]int)
+	for namespace, namespaceConfig := range config.Namespaces {
+		if namespaceConfig.Parallelism > 0 {
+			namespaceParallelism[namespace] = namespaceConfig.Parallelism
+		}
+	}
+	wfc.throttler.SetNamespaceParallelism(namespaceParallelism)
 	return nil
 }
 
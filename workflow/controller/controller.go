@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
@@ -27,10 +28,12 @@ import (
 
 	"github.com/cyrusbiotechnology/argo"
 	wfclientset "github.com/cyrusbiotechnology/argo/pkg/client/clientset/versioned"
+	artifact "github.com/cyrusbiotechnology/argo/workflow/artifacts"
 	"github.com/cyrusbiotechnology/argo/workflow/common"
 	"github.com/cyrusbiotechnology/argo/workflow/config"
 	"github.com/cyrusbiotechnology/argo/workflow/metrics"
 	"github.com/cyrusbiotechnology/argo/workflow/persist/sqldb"
+	"github.com/cyrusbiotechnology/argo/workflow/tracing"
 	"github.com/cyrusbiotechnology/argo/workflow/ttlcontroller"
 	"github.com/cyrusbiotechnology/argo/workflow/util"
 )
@@ -67,6 +70,16 @@ type WorkflowController struct {
 	gcPods         chan string // pods to be deleted depend on GC strategy
 	throttler      Throttler
 	wfDBctx        sqldb.DBRepository
+	// tracerProvider emits the workflow execution spans. nil when tracing is disabled
+	tracerProvider *tracing.TracerProvider
+	// tracingConfig is the tracing configuration the tracer provider was built from
+	tracingConfig *tracing.Config
+	// newArtifactDriver instantiates the drivers loading the artifacts of the withArtifact loops
+	newArtifactDriver func(art *wfv1.Artifact, ri common.ResourceInterface) (artifact.ArtifactDriver, error)
+	// podQuotaWaiters are the keys of the workflows waiting for the active pods of their namespace to
+	// fall below its maxActivePods, indexed by namespace
+	podQuotaWaiters map[string]map[string]bool
+	podQuotaLock    sync.Mutex
 }
 
 const (
@@ -97,12 +110,13 @@ func NewWorkflowController(
 		namespace:                  namespace,
 		cliExecutorImage:           executorImage,
 		cliExecutorImagePullPolicy: executorImagePullPolicy,
-		wfQueue:                    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
-		podQueue:                   workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		wfQueue:                    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "workflow_queue"),
+		podQueue:                   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pod_queue"),
 		completedPods:              make(chan string, 512),
 		gcPods:                     make(chan string, 512),
+		newArtifactDriver:          artifact.NewDriver,
 	}
-	wfc.throttler = NewThrottler(0, wfc.wfQueue)
+	wfc.throttler = NewThrottler(0, wfc.wfQueue, wfc.getWfGroup)
 	return &wfc
 }
 
@@ -365,6 +379,10 @@ func (wfc *WorkflowController) processNextPodItem() bool {
 		// we can get here if pod was queued into the pod workqueue,
 		// but it was either deleted or labeled completed by the time
 		// we dequeued it.
+		namespace, _, err := cache.SplitMetaNamespaceKey(key.(string))
+		if err == nil {
+			wfc.releasePodQuota(namespace)
+		}
 		return true
 	}
 	pod, ok := obj.(*apiv1.Pod)
@@ -372,6 +390,10 @@ func (wfc *WorkflowController) processNextPodItem() bool {
 		log.Warnf("Key '%s' in index is not a pod", key)
 		return true
 	}
+	switch pod.Status.Phase {
+	case apiv1.PodSucceeded, apiv1.PodFailed:
+		wfc.releasePodQuota(pod.ObjectMeta.Namespace)
+	}
 	if pod.Labels == nil {
 		log.Warnf("Pod '%s' did not have labels", key)
 		return true
@@ -424,6 +446,83 @@ func getWfPriority(obj interface{}) (int32, time.Time) {
 	return int32(priority), un.GetCreationTimestamp().Time
 }
 
+// countNamespaceActivePods counts the active (Pending/Running) workflow pods of a namespace
+func (wfc *WorkflowController) countNamespaceActivePods(namespace string) (int64, error) {
+	objs, err := wfc.podInformer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return 0, err
+	}
+	var activePods int64
+	for _, obj := range objs {
+		pod, ok := obj.(*apiv1.Pod)
+		if !ok {
+			continue
+		}
+		switch pod.Status.Phase {
+		case apiv1.PodSucceeded, apiv1.PodFailed:
+		default:
+			activePods++
+		}
+	}
+	return activePods, nil
+}
+
+// waitForPodQuota records that a workflow could not create a pod because its namespace reached its
+// maxActivePods, so that it is requeued once a pod of the namespace completes
+func (wfc *WorkflowController) waitForPodQuota(namespace, key string) {
+	wfc.podQuotaLock.Lock()
+	defer wfc.podQuotaLock.Unlock()
+	if wfc.podQuotaWaiters == nil {
+		wfc.podQuotaWaiters = make(map[string]map[string]bool)
+	}
+	if wfc.podQuotaWaiters[namespace] == nil {
+		wfc.podQuotaWaiters[namespace] = make(map[string]bool)
+	}
+	wfc.podQuotaWaiters[namespace][key] = true
+}
+
+// releasePodQuota requeues the workflows waiting for the active pods of a namespace to fall below
+// its maxActivePods
+func (wfc *WorkflowController) releasePodQuota(namespace string) {
+	wfc.podQuotaLock.Lock()
+	defer wfc.podQuotaLock.Unlock()
+	for key := range wfc.podQuotaWaiters[namespace] {
+		wfc.wfQueue.Add(key)
+	}
+	delete(wfc.podQuotaWaiters, namespace)
+}
+
+// getWfGroup returns the fair share group of the workflow with the given key
+func (wfc *WorkflowController) getWfGroup(key interface{}, groupBy []string) string {
+	keyStr, ok := key.(string)
+	if !ok {
+		return ""
+	}
+	obj, exists, err := wfc.wfInformer.GetIndexer().GetByKey(keyStr)
+	if err != nil || !exists {
+		return ""
+	}
+	un, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return ""
+	}
+	return fairShareGroup(un.GetNamespace(), un.GetLabels(), groupBy)
+}
+
+// fairShareGroup joins the values of the group keys with '/'. The "namespace" key stands for the
+// namespace of the workflow, other keys are label names.
+func fairShareGroup(namespace string, labels map[string]string, groupBy []string) string {
+	values := make([]string, len(groupBy))
+	for i, key := range groupBy {
+		if key == "namespace" {
+			values[i] = namespace
+		} else {
+			values[i] = labels[key]
+		}
+	}
+	return strings.Join(values, "/")
+}
+
 func (wfc *WorkflowController) addWorkflowInformerHandler() {
 	wfc.wfInformer.AddEventHandler(
 		cache.ResourceEventHandlerFuncs{
@@ -488,7 +587,9 @@ func (wfc *WorkflowController) newWorkflowPodWatch() *cache.ListWatch {
 
 func (wfc *WorkflowController) newPodInformer() cache.SharedIndexInformer {
 	source := wfc.newWorkflowPodWatch()
-	informer := cache.NewSharedIndexInformer(source, &apiv1.Pod{}, podResyncPeriod, cache.Indexers{})
+	informer := cache.NewSharedIndexInformer(source, &apiv1.Pod{}, podResyncPeriod, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
 	informer.AddEventHandler(
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
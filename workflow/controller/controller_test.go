@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"k8s.io/client-go/util/workqueue"
@@ -21,6 +22,7 @@ import (
 	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
 	fakewfclientset "github.com/cyrusbiotechnology/argo/pkg/client/clientset/versioned/fake"
 	wfextv "github.com/cyrusbiotechnology/argo/pkg/client/informers/externalversions"
+	"github.com/cyrusbiotechnology/argo/workflow/common"
 	"github.com/cyrusbiotechnology/argo/workflow/config"
 )
 
@@ -94,3 +96,30 @@ func makePodsRunning(t *testing.T, kubeclientset kubernetes.Interface, namespace
 		_, _ = podcs.Update(&pod)
 	}
 }
+
+// makePodFailed simulates the failure of the main container of a pod with an exit code, and the error
+// conditions reported by its executor
+func makePodFailed(t *testing.T, kubeclientset kubernetes.Interface, podName string, exitCode int32, errorConditions ...string) {
+	podcs := kubeclientset.CoreV1().Pods("")
+	pod, err := podcs.Get(podName, metav1.GetOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	if len(errorConditions) > 0 {
+		var results []wfv1.ExceptionResult
+		for _, name := range errorConditions {
+			results = append(results, wfv1.ExceptionResult{Name: name, PodName: podName})
+		}
+		resultBytes, err := json.Marshal(results)
+		assert.NoError(t, err)
+		pod.Annotations[common.AnnotationKeyErrors] = string(resultBytes)
+	}
+	pod.Status.Phase = apiv1.PodFailed
+	pod.Status.Message = fmt.Sprintf("failed with exit code %d", exitCode)
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+		Name:  common.MainContainerName,
+		State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: exitCode}},
+	}}
+	_, err = podcs.Update(pod)
+	assert.NoError(t, err)
+}
@@ -35,6 +35,9 @@ type dagContext struct {
 
 	// tmplCtx is the context of template search.
 	tmplCtx *templateresolution.Context
+
+	// infrastructureRetryLimit is the default budget of retries of the infrastructure failures
+	infrastructureRetryLimit *int32
 }
 
 func (d *dagContext) getTask(taskName string) *wfv1.DAGTask {
@@ -67,6 +70,21 @@ func (d *dagContext) GetTaskNode(taskName string) *wfv1.NodeStatus {
 	return &node
 }
 
+// getItemNodes returns the nodes of the items of a task group. The dependants of an empty task group
+// are its children too.
+func (d *dagContext) getItemNodes(node *wfv1.NodeStatus) []wfv1.NodeStatus {
+	if node.Type != wfv1.NodeTypeTaskGroup {
+		return nil
+	}
+	var itemNodes []wfv1.NodeStatus
+	for _, childID := range node.Children {
+		if child, ok := d.wf.Status.Nodes[childID]; ok && strings.HasPrefix(child.Name, node.Name+"(") {
+			itemNodes = append(itemNodes, child)
+		}
+	}
+	return itemNodes
+}
+
 // Assert all branch finished for failFast:disable function
 func (d *dagContext) assertBranchFinished(targetTaskNames []string) bool {
 	// We should ensure that from the bottom to the top,
@@ -99,6 +117,40 @@ func (d *dagContext) assessDAGPhase(targetTasks []string, nodes map[string]wfv1.
 	// nodes have been exhausted.
 	var unsuccessfulPhase wfv1.NodePhase
 	retriesExhausted := true
+	if !d.onExitHooksStarted(nodes) {
+		return wfv1.NodeRunning
+	}
+	// the failed items of the task groups which succeeded were within their failure tolerance
+	handledNodes := make(map[string]bool)
+	for _, node := range nodes {
+		if node.BoundaryID == d.boundaryID && node.Type == wfv1.NodeTypeTaskGroup && node.Successful() {
+			for _, itemNode := range d.getItemNodes(&node) {
+				handledNodes[itemNode.ID] = true
+			}
+		}
+	}
+	// the failures of the tasks which are expected by the depends expression of a dependant are
+	// handled by the dependant. A negated reference, e.g. !B.Failed, does not expect the failure.
+	for _, task := range d.tasks {
+		if task.Depends == "" {
+			continue
+		}
+		refs, err := common.ParsePositiveDepends(task.Depends)
+		if err != nil {
+			continue
+		}
+		for _, ref := range refs {
+			switch ref.Result {
+			case common.DependsResultFailed, common.DependsResultErrored, common.DependsResultAllFailed, common.DependsResultAnySucceeded:
+				if depNode := d.GetTaskNode(ref.TaskName); depNode != nil {
+					handledNodes[depNode.ID] = true
+					for _, itemNode := range d.getItemNodes(depNode) {
+						handledNodes[itemNode.ID] = true
+					}
+				}
+			}
+		}
+	}
 	for _, node := range nodes {
 		if node.BoundaryID != d.boundaryID {
 			continue
@@ -106,7 +158,7 @@ func (d *dagContext) assessDAGPhase(targetTasks []string, nodes map[string]wfv1.
 		if !node.Completed() {
 			return wfv1.NodeRunning
 		}
-		if node.Successful() {
+		if node.Successful() || handledNodes[node.ID] {
 			continue
 		}
 		// failed retry attempts should not factor into the overall unsuccessful phase of the dag
@@ -164,6 +216,24 @@ func (d *dagContext) assessDAGPhase(targetTasks []string, nodes map[string]wfv1.
 	return wfv1.NodeSucceeded
 }
 
+// onExitHooksStarted returns whether the exit hooks of all completed tasks were started. The hook of
+// a task may not be started right away, e.g. when the parallelism of the workflow was reached.
+func (d *dagContext) onExitHooksStarted(nodes map[string]wfv1.NodeStatus) bool {
+	for _, task := range d.tasks {
+		if task.OnExit == "" {
+			continue
+		}
+		node, ok := nodes[d.taskNodeID(task.Name)]
+		if !ok || !node.Completed() || node.Type == wfv1.NodeTypeSkipped || node.Type == wfv1.NodeTypeTaskGroup {
+			continue
+		}
+		if _, ok := nodes[d.wf.NodeID(onExitHookNodeName(node.Name))]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
 // isRetryAttempt detects if a node is part of a retry
 func isRetryAttempt(node wfv1.NodeStatus, nodes map[string]wfv1.NodeStatus) bool {
 	for _, potentialParent := range nodes {
@@ -195,8 +265,15 @@ func (d *dagContext) hasMoreRetries(node *wfv1.NodeStatus) bool {
 	if err != nil {
 		return false
 	}
-	if tmpl.RetryStrategy != nil && tmpl.RetryStrategy.Limit != nil && int32(len(node.Children)) > *tmpl.RetryStrategy.Limit {
-		return false
+	if tmpl.RetryStrategy != nil && tmpl.RetryStrategy.Limit != nil {
+		infraLimit := tmpl.RetryStrategy.InfrastructureLimit
+		if infraLimit == nil {
+			infraLimit = d.infrastructureRetryLimit
+		}
+		_, attempts := countRetryAttempts(d.wf, node, infraLimit)
+		if attempts > *tmpl.RetryStrategy.Limit {
+			return false
+		}
 	}
 	return true
 }
@@ -210,21 +287,36 @@ func (woc *wfOperationCtx) executeDAG(nodeName string, tmplCtx *templateresoluti
 		}
 	}()
 
+	if tmpl.DAG.TasksFrom != "" {
+		var err error
+		tmpl, err = woc.expandDynamicDAG(nodeName, tmplCtx, tmpl)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the dependencies of the tasks with a depends expression are the tasks it references
+	tasks, err := common.ExpandDepends(tmpl.DAG.Tasks)
+	if err != nil {
+		return err
+	}
+
 	dagCtx := &dagContext{
-		boundaryName: nodeName,
-		boundaryID:   node.ID,
-		tasks:        tmpl.DAG.Tasks,
-		visited:      make(map[string]bool),
-		tmpl:         tmpl,
-		wf:           woc.wf,
-		tmplCtx:      tmplCtx,
+		boundaryName:             nodeName,
+		boundaryID:               node.ID,
+		tasks:                    tasks,
+		visited:                  make(map[string]bool),
+		tmpl:                     tmpl,
+		wf:                       woc.wf,
+		tmplCtx:                  tmplCtx,
+		infrastructureRetryLimit: woc.controller.Config.InfrastructureRetryLimit,
 	}
 
 	// Identify our target tasks. If user did not specify any, then we choose all tasks which have
 	// no dependants.
 	var targetTasks []string
 	if tmpl.DAG.Target == "" {
-		targetTasks = findLeafTaskNames(tmpl.DAG.Tasks)
+		targetTasks = findLeafTaskNames(dagCtx.tasks)
 	} else {
 		targetTasks = strings.Split(tmpl.DAG.Target, " ")
 	}
@@ -292,20 +384,30 @@ func (woc *wfOperationCtx) executeDAGTask(dagCtx *dagContext, taskName string) {
 	}
 	dagCtx.visited[taskName] = true
 
+	task := dagCtx.getTask(taskName)
 	node := dagCtx.GetTaskNode(taskName)
 	if node != nil && node.Completed() {
+		// the exit hook of a task runs after the task completed, possibly in a later operation
+		if node.Type != wfv1.NodeTypeTaskGroup {
+			_, _ = woc.executeOnExitHook(task.OnExit, node, dagCtx.tmplCtx, dagCtx.boundaryID)
+		}
 		return
 	}
 	// Check if our dependencies completed. If not, recurse our parents executing them if necessary
-	task := dagCtx.getTask(taskName)
 	dependenciesCompleted := true
 	dependenciesSuccessful := true
 	nodeName := dagCtx.taskNodeName(taskName)
 	for _, depName := range task.Dependencies {
 		depNode := dagCtx.GetTaskNode(depName)
+		depTask := dagCtx.getTask(depName)
+		if depNode != nil && depNode.Type == wfv1.NodeTypeTaskGroup && !depNode.Completed() {
+			// the phase of a task group is assessed when its task is executed, which may complete it
+			woc.executeDAGTask(dagCtx, depName)
+			depNode = dagCtx.GetTaskNode(depName)
+		}
 		if depNode != nil {
-			if depNode.Completed() {
-				if !depNode.Successful() && !dagCtx.getTask(depName).ContinuesOn(depNode.Phase) {
+			if depNode.Completed() && woc.onExitHookCompleted(depTask.OnExit, depNode) {
+				if !woc.dependencySatisfied(depTask, depNode) {
 					dependenciesSuccessful = false
 				}
 				continue
@@ -320,14 +422,12 @@ func (woc *wfOperationCtx) executeDAGTask(dagCtx *dagContext, taskName string) {
 		return
 	}
 
-	if !dependenciesSuccessful {
-		// TODO: in the future we may support some more sophisticated syntax for deciding on how
-		// to proceed if at least one dependency succeeded, analogous to airflow's trigger rules,
-		// (e.g. one_success, all_done, one_failed, etc...). This decision would be made here.
+	if !dependenciesSuccessful && task.Depends == "" {
 		return
 	}
 
-	// All our dependencies were satisfied and successful. It's our turn to run
+	// All our dependencies completed, and were successful unless a depends expression decides
+	// whether to proceed. It's our turn to run
 
 	taskGroupNode := woc.getNodeByName(nodeName)
 	if taskGroupNode != nil && taskGroupNode.Type != wfv1.NodeTypeTaskGroup {
@@ -357,8 +457,26 @@ func (woc *wfOperationCtx) executeDAGTask(dagCtx *dagContext, taskName string) {
 		}
 	}
 
+	if task.Depends != "" && woc.getNodeByName(nodeName) == nil {
+		proceed, err := common.EvaluateDepends(task.Depends, func(ref common.DependsReference) bool {
+			return woc.evaluateDependsReference(dagCtx, ref)
+		})
+		if err != nil {
+			woc.initializeNode(nodeName, wfv1.NodeTypeSkipped, task, dagCtx.boundaryID, wfv1.NodeError, err.Error())
+			connectDependencies(nodeName)
+			return
+		}
+		if !proceed {
+			skipReason := fmt.Sprintf("depends '%s' evaluated false", task.Depends)
+			woc.log.Infof("Skipping %s: %s", nodeName, skipReason)
+			woc.initializeNode(nodeName, wfv1.NodeTypeSkipped, task, dagCtx.boundaryID, wfv1.NodeSkipped, skipReason)
+			connectDependencies(nodeName)
+			return
+		}
+	}
+
 	// First resolve/substitute params/artifacts from our dependencies
-	newTask, err := woc.resolveDependencyReferences(dagCtx, task)
+	newTask, scope, err := woc.resolveDependencyReferences(dagCtx, task)
 	if err != nil {
 		woc.initializeNode(nodeName, wfv1.NodeTypeSkipped, task, dagCtx.boundaryID, wfv1.NodeError, err.Error())
 		connectDependencies(nodeName)
@@ -367,7 +485,7 @@ func (woc *wfOperationCtx) executeDAGTask(dagCtx *dagContext, taskName string) {
 
 	// Next, expand the DAG's withItems/withParams/withSequence (if any). If there was none, then
 	// expandedTasks will be a single element list of the same task
-	expandedTasks, err := woc.expandTask(*newTask)
+	expandedTasks, err := woc.expandTask(nodeName, *newTask, scope)
 	if err != nil {
 		woc.initializeNode(nodeName, wfv1.NodeTypeSkipped, task, dagCtx.boundaryID, wfv1.NodeError, err.Error())
 		connectDependencies(nodeName)
@@ -377,13 +495,22 @@ func (woc *wfOperationCtx) executeDAGTask(dagCtx *dagContext, taskName string) {
 	// If DAG task has withParam of with withSequence then we need to create virtual node of type TaskGroup.
 	// For example, if we had task A with withItems of ['foo', 'bar'] which expanded to ['A(0:foo)', 'A(1:bar)'], we still
 	// need to create a node for A.
-	if len(task.WithItems) > 0 || task.WithParam != "" || task.WithSequence != nil {
+	if task.IsLoop() {
 		if taskGroupNode == nil {
 			connectDependencies(nodeName)
 			taskGroupNode = woc.initializeNode(nodeName, wfv1.NodeTypeTaskGroup, task, dagCtx.boundaryID, wfv1.NodeRunning, "")
 		}
 	}
 
+	// The first task of a race to succeed wins, and the others are terminated
+	var winner *wfv1.NodeStatus
+	if taskGroupNode != nil && newTask.Race {
+		winner = woc.raceWinner(taskGroupNode)
+		if winner != nil {
+			woc.endRace(taskGroupNode, winner)
+		}
+	}
+
 	for _, t := range expandedTasks {
 		node = dagCtx.GetTaskNode(t.Name)
 		taskNodeName := dagCtx.taskNodeName(t.Name)
@@ -392,8 +519,13 @@ func (woc *wfOperationCtx) executeDAGTask(dagCtx *dagContext, taskName string) {
 			// Add the child relationship from our dependency's outbound nodes to this node.
 			connectDependencies(taskNodeName)
 
+			if winner != nil {
+				woc.initializeNode(taskNodeName, wfv1.NodeTypeSkipped, task, dagCtx.boundaryID, wfv1.NodeSkipped, raceLostMessage(winner))
+				continue
+			}
+
 			// Check the task's when clause to decide if it should execute
-			proceed, err := shouldExecute(t.When)
+			proceed, err := woc.evaluateWhen(t.When, scope)
 			if err != nil {
 				woc.initializeNode(taskNodeName, wfv1.NodeTypeSkipped, task, dagCtx.boundaryID, wfv1.NodeError, err.Error())
 				continue
@@ -406,28 +538,136 @@ func (woc *wfOperationCtx) executeDAGTask(dagCtx *dagContext, taskName string) {
 		}
 
 		// Finally execute the template
-		_, _ = woc.executeTemplate(taskNodeName, &t, dagCtx.tmplCtx, t.Arguments, dagCtx.boundaryID)
+		node, _ = woc.executeTemplate(taskNodeName, &t, dagCtx.tmplCtx, t.Arguments, dagCtx.boundaryID)
+		if node != nil && node.Completed() {
+			_, _ = woc.executeOnExitHook(t.OnExit, node, dagCtx.tmplCtx, dagCtx.boundaryID)
+		}
 	}
 
 	if taskGroupNode != nil {
+		if newTask.Race && winner == nil && woc.raceWinner(woc.getNodeByName(nodeName)) != nil {
+			// a task won the race during this operation, the others are terminated in the next one
+			woc.requeue()
+			return
+		}
 		groupPhase := wfv1.NodeSucceeded
+		var itemNodes []wfv1.NodeStatus
 		for _, t := range expandedTasks {
 			// Add the child relationship from our dependency's outbound nodes to this node.
 			node := dagCtx.GetTaskNode(t.Name)
-			if node == nil || !node.Completed() {
+			if node == nil || !node.Completed() || !woc.onExitHookCompleted(t.OnExit, node) {
 				return
 			}
-			if !node.Successful() {
+			itemNodes = append(itemNodes, *node)
+			if winner != nil && node.ID != winner.ID {
+				// the tasks which lost the race do not fail the task group
+				continue
+			}
+			if !node.Successful() && newTask.FailureTolerance == nil {
 				groupPhase = node.Phase
 			}
+			if hookNode := woc.getOnExitHookNode(t.OnExit, node); hookNode != nil && !hookNode.Successful() {
+				groupPhase = hookNode.Phase
+			}
+		}
+		if winner != nil && groupPhase == wfv1.NodeSucceeded {
+			woc.completeRace(taskGroupNode.Name, winner)
+			return
+		}
+		if newTask.FailureTolerance != nil {
+			// the failures of the items within the tolerance do not fail the task group
+			withinTolerance, toleranceMessage := assessFailureTolerance(newTask.FailureTolerance, itemNodes, newTask.ContinuesOn)
+			if !withinTolerance && groupPhase == wfv1.NodeSucceeded {
+				groupPhase = wfv1.NodeFailed
+			}
+			woc.markNodePhase(taskGroupNode.Name, groupPhase, toleranceMessage)
+			return
 		}
 		woc.markNodePhase(taskGroupNode.Name, groupPhase)
 	}
 }
 
-// resolveDependencyReferences replaces any references to outputs of task dependencies, or artifacts in the inputs
+// dependencySatisfied returns whether a completed task satisfies the dependency of another task,
+// which is when the task and its exit hook were successful or continued
+func (woc *wfOperationCtx) dependencySatisfied(depTask *wfv1.DAGTask, depNode *wfv1.NodeStatus) bool {
+	if !depNode.Successful() && !woc.taskContinuesOn(depTask, depNode) {
+		return false
+	}
+	hookNode := woc.getOnExitHookNode(depTask.OnExit, depNode)
+	if hookNode != nil && !hookNode.Successful() && !depTask.ContinuesOn(hookNode) {
+		return false
+	}
+	return true
+}
+
+// evaluateDependsReference returns whether the result of a completed task matches a reference of a
+// depends expression
+func (woc *wfOperationCtx) evaluateDependsReference(dagCtx *dagContext, ref common.DependsReference) bool {
+	node := dagCtx.GetTaskNode(ref.TaskName)
+	if node == nil {
+		return false
+	}
+	switch ref.Result {
+	case "":
+		return woc.dependencySatisfied(dagCtx.getTask(ref.TaskName), node)
+	case common.DependsResultSucceeded:
+		return node.Phase == wfv1.NodeSucceeded
+	case common.DependsResultFailed:
+		return node.Phase == wfv1.NodeFailed
+	case common.DependsResultErrored:
+		return node.Phase == wfv1.NodeError
+	case common.DependsResultSkipped:
+		return node.Phase == wfv1.NodeSkipped
+	case common.DependsResultDaemoned:
+		return node.IsDaemoned() && node.Phase != wfv1.NodePending
+	case common.DependsResultAnySucceeded, common.DependsResultAllFailed:
+		itemNodes := dagCtx.getItemNodes(node)
+		succeeded, failed := 0, 0
+		for _, itemNode := range itemNodes {
+			switch itemNode.Phase {
+			case wfv1.NodeSucceeded:
+				succeeded++
+			case wfv1.NodeFailed, wfv1.NodeError:
+				failed++
+			}
+		}
+		if ref.Result == common.DependsResultAnySucceeded {
+			return succeeded > 0
+		}
+		return len(itemNodes) > 0 && failed == len(itemNodes)
+	}
+	return false
+}
+
+// taskContinuesOn returns whether the dependants of an unsuccessful task should be executed. A task
+// group continues if all of its unsuccessful children continue, since the exit codes and error
+// conditions are the ones of the children.
+func (woc *wfOperationCtx) taskContinuesOn(task *wfv1.DAGTask, node *wfv1.NodeStatus) bool {
+	if task.ContinuesOn(node) {
+		return true
+	}
+	if node.Type != wfv1.NodeTypeTaskGroup {
+		return false
+	}
+	for _, childID := range node.Children {
+		child, ok := woc.wf.Status.Nodes[childID]
+		if !ok {
+			continue
+		}
+		if !child.Successful() && !task.ContinuesOn(&child) {
+			return false
+		}
+		if hookNode := woc.getOnExitHookNode(task.OnExit, &child); hookNode != nil && !hookNode.Successful() && !task.ContinuesOn(hookNode) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveDependencyReferences replaces any references to outputs of task dependencies, or artifacts in the inputs.
+// It also returns the scope of the task, against which its when expression is evaluated.
 // NOTE: by now, input parameters should have been substituted throughout the template
-func (woc *wfOperationCtx) resolveDependencyReferences(dagCtx *dagContext, task *wfv1.DAGTask) (*wfv1.DAGTask, error) {
+func (woc *wfOperationCtx) resolveDependencyReferences(dagCtx *dagContext, task *wfv1.DAGTask) (*wfv1.DAGTask, *wfScope, error) {
 	// build up the scope
 	scope := wfScope{
 		tmpl:  dagCtx.tmpl,
@@ -439,23 +679,23 @@ func (woc *wfOperationCtx) resolveDependencyReferences(dagCtx *dagContext, task
 	for _, ancestor := range ancestors {
 		ancestorNode := dagCtx.GetTaskNode(ancestor)
 		if ancestorNode == nil {
-			return nil, errors.InternalErrorf("Ancestor task node %s not found", ancestor)
+			return nil, nil, errors.InternalErrorf("Ancestor task node %s not found", ancestor)
 		}
 		prefix := fmt.Sprintf("tasks.%s", ancestor)
-		if ancestorNode.Type == wfv1.NodeTypeTaskGroup {
+		if ancestorNode.Type == wfv1.NodeTypeTaskGroup && !dagCtx.getTask(ancestor).Race {
 			var ancestorNodes []wfv1.NodeStatus
 			for _, node := range woc.wf.Status.Nodes {
-				if node.BoundaryID == dagCtx.boundaryID && strings.HasPrefix(node.Name, ancestorNode.Name+"(") {
+				if node.BoundaryID == dagCtx.boundaryID && strings.HasPrefix(node.Name, ancestorNode.Name+"(") && !isOnExitHookNode(node.Name) {
 					ancestorNodes = append(ancestorNodes, node)
 				}
 			}
 			_, tmpl, err := dagCtx.tmplCtx.ResolveTemplate(ancestorNode)
 			if err != nil {
-				return nil, errors.InternalWrapError(err)
+				return nil, nil, errors.InternalWrapError(err)
 			}
-			err = woc.processAggregateNodeOutputs(tmpl, &scope, prefix, ancestorNodes)
+			err = woc.processAggregateNodeOutputs(tmpl, &scope, prefix, ancestorNodes, dagCtx.getTask(ancestor).BatchSize > 0)
 			if err != nil {
-				return nil, errors.InternalWrapError(err)
+				return nil, nil, errors.InternalWrapError(err)
 			}
 		} else {
 			woc.processNodeOutputs(&scope, prefix, ancestorNode)
@@ -466,23 +706,23 @@ func (woc *wfOperationCtx) resolveDependencyReferences(dagCtx *dagContext, task
 	// Replace woc.volumes
 	err := woc.substituteParamsInVolumes(scope.replaceMap())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Replace task's parameters
 	taskBytes, err := json.Marshal(task)
 	if err != nil {
-		return nil, errors.InternalWrapError(err)
+		return nil, nil, errors.InternalWrapError(err)
 	}
 	fstTmpl := fasttemplate.New(string(taskBytes), "{{", "}}")
 	newTaskStr, err := common.Replace(fstTmpl, scope.replaceMap(), true)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	var newTask wfv1.DAGTask
 	err = json.Unmarshal([]byte(newTaskStr), &newTask)
 	if err != nil {
-		return nil, errors.InternalWrapError(err)
+		return nil, nil, errors.InternalWrapError(err)
 	}
 
 	// replace all artifact references
@@ -492,12 +732,12 @@ func (woc *wfOperationCtx) resolveDependencyReferences(dagCtx *dagContext, task
 		}
 		resolvedArt, err := scope.resolveArtifact(art.From)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		resolvedArt.Name = art.Name
 		newTask.Arguments.Artifacts[j] = *resolvedArt
 	}
-	return &newTask, nil
+	return &newTask, &scope, nil
 }
 
 // findLeafTaskNames finds the names of all tasks whom no other nodes depend on.
@@ -521,13 +761,10 @@ func findLeafTaskNames(tasks []wfv1.DAGTask) []string {
 	return leafTaskNames
 }
 
-// expandTask expands a single DAG task containing withItems, withParams, withSequence into multiple parallel tasks
-func (woc *wfOperationCtx) expandTask(task wfv1.DAGTask) ([]wfv1.DAGTask, error) {
-	taskBytes, err := json.Marshal(task)
-	if err != nil {
-		return nil, errors.InternalWrapError(err)
-	}
+// expandTask expands a single DAG task containing withItems, withParams, withSequence, withMatrix, withArtifact into multiple parallel tasks
+func (woc *wfOperationCtx) expandTask(nodeName string, task wfv1.DAGTask, scope *wfScope) ([]wfv1.DAGTask, error) {
 	var items []wfv1.Item
+	var err error
 	if len(task.WithItems) > 0 {
 		items = task.WithItems
 	} else if task.WithParam != "" {
@@ -540,15 +777,40 @@ func (woc *wfOperationCtx) expandTask(task wfv1.DAGTask) ([]wfv1.DAGTask, error)
 		if err != nil {
 			return nil, err
 		}
+	} else if len(task.WithMatrix) > 0 {
+		items, err = expandMatrix(task.WithMatrix)
+		if err != nil {
+			return nil, err
+		}
+	} else if task.WithArtifact != nil {
+		items, err = woc.getLoopArtifactItems(nodeName, task.WithArtifact, scope)
+		if err != nil {
+			return nil, err
+		}
+		// the artifact reference is not substituted in the expanded tasks
+		task.WithArtifact = nil
 	} else {
 		return []wfv1.DAGTask{task}, nil
 	}
 
+	taskBytes, err := json.Marshal(task)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
 	fstTmpl := fasttemplate.New(string(taskBytes), "{{", "}}")
 	expandedTasks := make([]wfv1.DAGTask, 0)
+	batchSize := int(task.BatchSize)
+	if batchSize > 0 {
+		items = batchItems(items, batchSize)
+	}
 	for i, item := range items {
 		var newTask wfv1.DAGTask
-		newTaskName, err := processItem(fstTmpl, task.Name, i, item, &newTask)
+		var newTaskName string
+		if batchSize > 0 {
+			newTaskName, err = processBatch(fstTmpl, task.Name, i, batchSize, item, &newTask)
+		} else {
+			newTaskName, err = processItem(fstTmpl, task.Name, i, item, &newTask)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -1,9 +1,12 @@
 package controller
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
 	"github.com/cyrusbiotechnology/argo/test"
@@ -40,3 +43,342 @@ func TestDagDisableFailFast(t *testing.T) {
 	woc.operate()
 	assert.Equal(t, string(wfv1.NodeFailed), string(woc.wf.Status.Phase))
 }
+
+var dagTaskExitHook = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: dag-exit-hook
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    dag:
+      tasks:
+      - name: A
+        template: noop
+        onExit: release
+      - name: B
+        template: noop
+        dependencies: [A]
+  - name: noop
+    steps:
+    - - name: skipped
+        template: release
+        when: "1 == 2"
+  - name: release
+    container:
+      image: docker/whalesay:latest
+`
+
+// TestDagTaskExitHook verifies dependent tasks wait for the exit hook of their dependencies and a
+// failed hook fails the DAG
+func TestDagTaskExitHook(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(dagTaskExitHook))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodeSucceeded, woc.getNodeByName("dag-exit-hook.A").Phase)
+	hook := woc.getNodeByName("dag-exit-hook.A.onExit")
+	if assert.NotNil(t, hook) {
+		assert.Equal(t, wfv1.NodePending, hook.Phase)
+	}
+	assert.Nil(t, woc.getNodeByName("dag-exit-hook.B"))
+
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	pods, err := podcs.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, pods.Items, 1) {
+		pod := pods.Items[0]
+		pod.Status.Phase = apiv1.PodFailed
+		pod.Status.Message = "lease not found"
+		_, err = podcs.Update(&pod)
+		assert.NoError(t, err)
+	}
+
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodeFailed, woc.getNodeByName("dag-exit-hook.A.onExit").Phase)
+	assert.Nil(t, woc.getNodeByName("dag-exit-hook.B"))
+	assert.Equal(t, wfv1.NodeFailed, woc.wf.Status.Phase)
+}
+
+var dagContinueOnCondition = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: dag-continue-on
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    dag:
+      tasks:
+      - name: A
+        template: search
+        withItems: [foo, bar]
+        continueOn:
+          conditions: [no-results]
+      - name: B
+        template: search
+        dependencies: [A]
+  - name: search
+    container:
+      image: alpine:latest
+      command: [sh, -c, "echo no results; exit 1"]
+    errors:
+    - name: no-results
+      patternMatched: no results
+`
+
+// TestDagContinueOnCondition verifies the dependants of a task group are executed when its children
+// failed with one of the continueOn error conditions
+func TestDagContinueOnCondition(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(dagContinueOnCondition))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("dag-continue-on.A(0:foo)"), 1, "no-results")
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("dag-continue-on.A(1:bar)"), 1)
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodeFailed, woc.getNodeByName("dag-continue-on.A").Phase)
+	assert.Equal(t, []string{"no-results"}, woc.getNodeByName("dag-continue-on.A(0:foo)").ErrorConditions)
+	// the failure of A(1:bar) did not raise the condition
+	assert.Nil(t, woc.getNodeByName("dag-continue-on.B"))
+	assert.Equal(t, wfv1.NodeFailed, woc.wf.Status.Phase)
+
+	// the same failures with the condition raised by both children
+	wf, err = wfcset.Create(unmarshalWF(strings.Replace(dagContinueOnCondition, "name: dag-continue-on", "name: dag-continue-on-2", 1)))
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("dag-continue-on-2.A(0:foo)"), 1, "no-results")
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("dag-continue-on-2.A(1:bar)"), 1, "no-results")
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodeFailed, woc.getNodeByName("dag-continue-on-2.A").Phase)
+	assert.NotNil(t, woc.getNodeByName("dag-continue-on-2.B"))
+}
+
+var dagFailureTolerance = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: dag-failure-tolerance
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    dag:
+      tasks:
+      - name: A
+        template: process
+        withItems: [a, b, c]
+        failureTolerance: 1
+  - name: process
+    container:
+      image: alpine:latest
+`
+
+// TestDagFailureTolerance verifies a task group is judged against its failure tolerance, and the
+// failures within the tolerance do not fail the DAG
+func TestDagFailureTolerance(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	operate := func(wfName string, tolerance string) *wfOperationCtx {
+		manifest := strings.Replace(dagFailureTolerance, "dag-failure-tolerance", wfName, 1)
+		manifest = strings.Replace(manifest, "failureTolerance: 1", "failureTolerance: "+tolerance, 1)
+		wf, err := wfcset.Create(unmarshalWF(manifest))
+		assert.NoError(t, err)
+		woc := newWorkflowOperationCtx(wf, controller)
+		woc.operate()
+		makePodFailed(t, controller.kubeclientset, woc.wf.NodeID(wfName+".A(0:a)"), 1)
+		for _, nodeName := range []string{wfName + ".A(1:b)", wfName + ".A(2:c)"} {
+			pod, err := podcs.Get(woc.wf.NodeID(nodeName), metav1.GetOptions{})
+			assert.NoError(t, err)
+			pod.Status.Phase = apiv1.PodSucceeded
+			_, err = podcs.Update(pod)
+			assert.NoError(t, err)
+		}
+		woc = newWorkflowOperationCtx(woc.wf, controller)
+		woc.operate()
+		return woc
+	}
+
+	woc := operate("within-tolerance", "1")
+	a := woc.getNodeByName("within-tolerance.A")
+	assert.Equal(t, wfv1.NodeSucceeded, a.Phase)
+	assert.Equal(t, "1/3 items failed (failure tolerance: 1)", a.Message)
+	assert.Equal(t, wfv1.NodeSucceeded, woc.wf.Status.Phase)
+
+	woc = operate("over-tolerance", "0")
+	a = woc.getNodeByName("over-tolerance.A")
+	assert.Equal(t, wfv1.NodeFailed, a.Phase)
+	assert.Equal(t, "1/3 items failed (failure tolerance: 0)", a.Message)
+	assert.Equal(t, wfv1.NodeFailed, woc.wf.Status.Phase)
+}
+
+var dagDepends = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: dag-depends
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    dag:
+      tasks:
+      - name: A
+        template: work
+      - name: on-failure
+        template: work
+        depends: A.Failed
+      - name: on-success
+        template: work
+        depends: A.Succeeded
+      - name: report
+        template: work
+        depends: on-failure && on-success.Skipped
+  - name: work
+    container:
+      image: alpine:latest
+`
+
+// TestDagDepends verifies the tasks run according to their depends expression, and a failure expected
+// by a dependant does not fail the DAG
+func TestDagDepends(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	wf, err := wfcset.Create(unmarshalWF(dagDepends))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	assert.NotNil(t, woc.getNodeByName("dag-depends.A"))
+	assert.Nil(t, woc.getNodeByName("dag-depends.on-failure"))
+
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("dag-depends.A"), 1)
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodePending, woc.getNodeByName("dag-depends.on-failure").Phase)
+	onSuccess := woc.getNodeByName("dag-depends.on-success")
+	assert.Equal(t, wfv1.NodeSkipped, onSuccess.Phase)
+	assert.Equal(t, "depends 'A.Succeeded' evaluated false", onSuccess.Message)
+	assert.Nil(t, woc.getNodeByName("dag-depends.report"))
+
+	pod, err := podcs.Get(woc.wf.NodeID("dag-depends.on-failure"), metav1.GetOptions{})
+	assert.NoError(t, err)
+	pod.Status.Phase = apiv1.PodSucceeded
+	_, err = podcs.Update(pod)
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	report := woc.getNodeByName("dag-depends.report")
+	if assert.NotNil(t, report) {
+		assert.Equal(t, wfv1.NodePending, report.Phase)
+		pod, err = podcs.Get(report.ID, metav1.GetOptions{})
+		assert.NoError(t, err)
+		pod.Status.Phase = apiv1.PodSucceeded
+		_, err = podcs.Update(pod)
+		assert.NoError(t, err)
+	}
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodeSucceeded, woc.wf.Status.Phase)
+}
+
+var dagNegatedDepends = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: dag-negated-depends
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    dag:
+      tasks:
+      - name: A
+        template: work
+      - name: unless-failed
+        template: work
+        depends: "!A.Failed"
+  - name: work
+    container:
+      image: alpine:latest
+`
+
+// TestDagNegatedDepends verifies a failure referenced under a negation is not expected by the
+// dependant, and fails the DAG
+func TestDagNegatedDepends(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(dagNegatedDepends))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("dag-negated-depends.A"), 1)
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodeSkipped, woc.getNodeByName("dag-negated-depends.unless-failed").Phase)
+	assert.Equal(t, wfv1.NodeFailed, woc.wf.Status.Phase)
+}
+
+var dagMatrix = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: dag-matrix
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    dag:
+      tasks:
+      - name: align
+        template: align
+        arguments:
+          parameters:
+          - name: sample
+            value: "{{item.sample}}-{{item.lane}}"
+        withMatrix:
+        - name: sample
+          items: [a, b]
+        - name: lane
+          items: [1, 2]
+  - name: align
+    inputs:
+      parameters:
+      - name: sample
+    container:
+      image: alpine:latest
+`
+
+// TestDagMatrix verifies a task is expanded to every combination of the values of its matrix,
+// under a task group
+func TestDagMatrix(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(dagMatrix))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	taskGroup := woc.getNodeByName("dag-matrix.align")
+	if assert.NotNil(t, taskGroup) {
+		assert.Equal(t, wfv1.NodeTypeTaskGroup, taskGroup.Type)
+		assert.Len(t, taskGroup.Children, 4)
+	}
+	for _, nodeName := range []string{"dag-matrix.align(0:lane:1,sample:a)", "dag-matrix.align(1:lane:2,sample:a)", "dag-matrix.align(2:lane:1,sample:b)", "dag-matrix.align(3:lane:2,sample:b)"} {
+		assert.NotNil(t, woc.getNodeByName(nodeName), nodeName)
+	}
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/valyala/fasttemplate"
+
+	"github.com/cyrusbiotechnology/argo/errors"
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+	"github.com/cyrusbiotechnology/argo/workflow/common"
+	"github.com/cyrusbiotechnology/argo/workflow/templateresolution"
+	"github.com/cyrusbiotechnology/argo/workflow/validate"
+)
+
+// expandDynamicDAG returns the DAG template with the tasks generated from its tasksFrom input. The
+// tasks are generated the first time the DAG runs and recorded in the workflow status under the name
+// of the node of the DAG, so that they do not change while it runs.
+func (woc *wfOperationCtx) expandDynamicDAG(nodeName string, tmplCtx *templateresolution.Context, tmpl *wfv1.Template) (*wfv1.Template, error) {
+	newTmpl := tmpl.DeepCopy()
+	newTmpl.DAG.TasksFrom = ""
+	if tasks, ok := woc.wf.Status.DynamicDAGTasks[nodeName]; ok {
+		newTmpl.DAG.Tasks = tasks
+		return newTmpl, nil
+	}
+	tasksJSON, err := woc.getDynamicDAGTasksJSON(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	// the tasks may reference the inputs of the template, and the global variables
+	replaceMap := make(map[string]string)
+	for k, v := range woc.globalParams {
+		replaceMap[k] = v
+	}
+	for _, inParam := range tmpl.Inputs.Parameters {
+		if inParam.Value != nil {
+			replaceMap["inputs.parameters."+inParam.Name] = *inParam.Value
+		}
+	}
+	fstTmpl := fasttemplate.New(tasksJSON, "{{", "}}")
+	tasksJSON, err = common.Replace(fstTmpl, replaceMap, true)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []wfv1.DAGTask
+	err = json.Unmarshal([]byte(tasksJSON), &tasks)
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "dag.tasksFrom is not a JSON list of tasks: %v", err)
+	}
+	if len(tasks) == 0 {
+		return nil, errors.New(errors.CodeBadRequest, "dag.tasksFrom holds no tasks")
+	}
+	newTmpl.DAG.Tasks = tasks
+	validateOpts := validate.ValidateOpts{ContainerRuntimeExecutor: woc.controller.Config.ContainerRuntimeExecutor}
+	err = validate.ValidateDAG(woc.wf, tmplCtx, newTmpl, validateOpts)
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "dag.tasksFrom generated invalid tasks: %v", err)
+	}
+	if woc.wf.Status.DynamicDAGTasks == nil {
+		woc.wf.Status.DynamicDAGTasks = make(map[string][]wfv1.DAGTask)
+	}
+	woc.wf.Status.DynamicDAGTasks[nodeName] = tasks
+	woc.updated = true
+	woc.log.Infof("Generated %d tasks of DAG %s", len(tasks), nodeName)
+	return newTmpl, nil
+}
+
+// getDynamicDAGTasksJSON returns the JSON of the tasks of a dynamic DAG. The input parameters are
+// already substituted in tasksFrom, whereas the input artifacts are loaded from the artifact repository.
+func (woc *wfOperationCtx) getDynamicDAGTasksJSON(tmpl *wfv1.Template) (string, error) {
+	ref := strings.TrimSpace(tmpl.DAG.TasksFrom)
+	if !strings.HasPrefix(ref, "{{inputs.artifacts.") {
+		return tmpl.DAG.TasksFrom, nil
+	}
+	artName := strings.TrimSuffix(strings.TrimPrefix(ref, "{{inputs.artifacts."), "}}")
+	art := tmpl.Inputs.GetArtifactByName(artName)
+	if art == nil || !art.HasLocation() {
+		return "", errors.Errorf(errors.CodeBadRequest, "dag.tasksFrom %s was not supplied", ref)
+	}
+	data, err := woc.loadArtifact(art)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+	"github.com/cyrusbiotechnology/argo/workflow/config"
+)
+
+var dynamicDag = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: dynamic-dag
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: plan
+        template: plan
+    - - name: run
+        template: run
+        arguments:
+          parameters:
+          - name: tasks
+            value: "{{steps.plan.outputs.parameters.tasks}}"
+  - name: plan
+    container:
+      image: alpine:latest
+    outputs:
+      parameters:
+      - name: tasks
+        valueFrom:
+          path: /tmp/tasks.json
+  - name: run
+    inputs:
+      parameters:
+      - name: tasks
+      - name: greeting
+        value: hello
+    dag:
+      tasksFrom: "{{inputs.parameters.tasks}}"
+  - name: echo
+    inputs:
+      parameters:
+      - name: message
+    container:
+      image: alpine:latest
+      args: ["{{inputs.parameters.message}}"]
+`
+
+// planDynamicDag operates the dynamic-dag workflow until the tasks of its DAG are generated
+func planDynamicDag(t *testing.T, tasks string) *wfOperationCtx {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(dynamicDag))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	makePodSucceeded(t, controller.kubeclientset, woc.wf.NodeID("dynamic-dag[0].plan"), &wfv1.Outputs{
+		Parameters: []wfv1.Parameter{{Name: "tasks", Value: &tasks}},
+	})
+
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	return woc
+}
+
+func TestDynamicDag(t *testing.T) {
+	woc := planDynamicDag(t, `[
+  {"name": "A", "template": "echo", "arguments": {"parameters": [{"name": "message", "value": "{{inputs.parameters.greeting}} A"}]}},
+  {"name": "B", "template": "echo", "dependencies": ["A"], "arguments": {"parameters": [{"name": "message", "value": "B"}]}}
+]`)
+
+	podcs := woc.controller.kubeclientset.CoreV1().Pods("")
+	pod, err := podcs.Get(woc.wf.NodeID("dynamic-dag[1].run.A"), metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"hello A"}, pod.Spec.Containers[1].Args)
+	}
+	_, err = podcs.Get(woc.wf.NodeID("dynamic-dag[1].run.B"), metav1.GetOptions{})
+	assert.Error(t, err)
+	tasks := woc.wf.Status.DynamicDAGTasks["dynamic-dag[1].run"]
+	if assert.Len(t, tasks, 2) {
+		assert.Equal(t, "hello A", *tasks[0].Arguments.Parameters[0].Value)
+	}
+	node := woc.getNodeByName("dynamic-dag[1].run")
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeRunning, node.Phase)
+	}
+}
+
+func TestDynamicDagInvalidTasks(t *testing.T) {
+	for tasks, message := range map[string]string{
+		`{"name": "A"}`:                          "dag.tasksFrom is not a JSON list of tasks",
+		`[]`:                                     "dag.tasksFrom holds no tasks",
+		`[{"name": "A", "template": "missing"}]`: "dag.tasksFrom generated invalid tasks: templates.run.tasks.A template name 'missing' undefined",
+		`[{"name": "A", "template": "echo", "dependencies": ["B"]}]`: "dag.tasksFrom generated invalid tasks",
+	} {
+		woc := planDynamicDag(t, tasks)
+		node := woc.getNodeByName("dynamic-dag[1].run")
+		if assert.NotNil(t, node, tasks) {
+			assert.Equal(t, wfv1.NodeError, node.Phase, tasks)
+			assert.True(t, strings.HasPrefix(node.Message, message), node.Message)
+		}
+		assert.Empty(t, woc.wf.Status.DynamicDAGTasks, tasks)
+	}
+}
+
+var dynamicDagArtifact = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: dynamic-dag-artifact
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: plan
+        template: plan
+    - - name: run
+        template: run
+        arguments:
+          artifacts:
+          - name: tasks
+            from: "{{steps.plan.outputs.artifacts.tasks}}"
+  - name: plan
+    container:
+      image: alpine:latest
+    outputs:
+      artifacts:
+      - name: tasks
+        path: /tmp/tasks.json
+  - name: run
+    inputs:
+      artifacts:
+      - name: tasks
+    dag:
+      tasksFrom: "{{inputs.artifacts.tasks}}"
+  - name: echo
+    inputs:
+      parameters:
+      - name: message
+    container:
+      image: alpine:latest
+      args: ["{{inputs.parameters.message}}"]
+`
+
+// TestDynamicDagArtifact verifies the tasks of a DAG are generated from an artifact archived as a
+// tarball, which is how the executor saves output artifacts by default
+func TestDynamicDagArtifact(t *testing.T) {
+	controller := newController()
+	driver := &fakeLoopArtifactDriver{contents: map[string]string{
+		"tasks.tgz": tarGz(t, `[{"name": "A", "template": "echo", "arguments": {"parameters": [{"name": "message", "value": "A"}]}}]`),
+	}}
+	controller.newArtifactDriver = driver.newDriver
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(dynamicDagArtifact))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.artifactRepository.S3 = new(config.S3ArtifactRepository)
+	woc.operate()
+	makePodSucceeded(t, controller.kubeclientset, woc.wf.NodeID("dynamic-dag-artifact[0].plan"), &wfv1.Outputs{
+		Artifacts: []wfv1.Artifact{{
+			Name:             "tasks",
+			ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "artifacts"}, Key: "tasks.tgz"}},
+		}},
+	})
+
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	node := woc.getNodeByName("dynamic-dag-artifact[1].run")
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeRunning, node.Phase, node.Message)
+	}
+	pod, err := controller.kubeclientset.CoreV1().Pods("").Get(woc.wf.NodeID("dynamic-dag-artifact[1].run.A"), metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"A"}, pod.Spec.Containers[1].Args)
+	}
+	assert.Equal(t, 1, driver.loads)
+}
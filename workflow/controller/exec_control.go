@@ -35,7 +35,9 @@ func (woc *wfOperationCtx) applyExecutionControl(pod *apiv1.Pod, wfNodesLock *sy
 				defer wfNodesLock.Unlock()
 				node := woc.wf.Status.Nodes[pod.Name]
 				var message string
-				if woc.workflowDeadline.IsZero() {
+				if woc.wf.Spec.Shutdown != "" {
+					message = woc.shutdownMessage()
+				} else if woc.workflowDeadline.IsZero() {
 					message = "terminated"
 				} else {
 					message = fmt.Sprintf("step exceeded workflow deadline %s", *woc.workflowDeadline)
@@ -46,6 +48,9 @@ func (woc *wfOperationCtx) applyExecutionControl(pod *apiv1.Pod, wfNodesLock *sy
 			// If we fail to delete the pod, fall back to setting the annotation
 			woc.log.Warnf("Failed to delete %s/%s: %v", pod.Namespace, pod.Name, err)
 		}
+		if woc.applyPendingPolicy(pod, wfNodesLock) {
+			return nil
+		}
 	}
 
 	var podExecCtl common.ExecutionControl
@@ -55,10 +60,16 @@ func (woc *wfOperationCtx) applyExecutionControl(pod *apiv1.Pod, wfNodesLock *sy
 			woc.log.Warnf("Failed to unmarshal execution control from pod %s", pod.Name)
 		}
 	}
-	if podExecCtl.Deadline == nil && woc.workflowDeadline == nil {
+	deadline := woc.workflowDeadline
+	if stopDeadline := woc.getStopDeadline(pod.Name, podExecCtl, wfNodesLock); stopDeadline != nil {
+		if deadline == nil || stopDeadline.Before(*deadline) {
+			deadline = stopDeadline
+		}
+	}
+	if podExecCtl.Deadline == nil && deadline == nil {
 		return nil
-	} else if podExecCtl.Deadline != nil && woc.workflowDeadline != nil {
-		if podExecCtl.Deadline.Equal(*woc.workflowDeadline) {
+	} else if podExecCtl.Deadline != nil && deadline != nil {
+		if podExecCtl.Deadline.Equal(*deadline) {
 			return nil
 		}
 	}
@@ -69,13 +80,33 @@ func (woc *wfOperationCtx) applyExecutionControl(pod *apiv1.Pod, wfNodesLock *sy
 		return nil
 	}
 
-	// Assign new deadline value to PodExeCtl
-	podExecCtl.Deadline = woc.workflowDeadline
+	woc.log.Infof("Execution control for pod %s out-of-sync desired: %v, actual: %v", pod.Name, deadline, podExecCtl.Deadline)
 
-	woc.log.Infof("Execution control for pod %s out-of-sync desired: %v, actual: %v", pod.Name, woc.workflowDeadline, podExecCtl.Deadline)
+	// Assign new deadline value to PodExeCtl
+	podExecCtl.Deadline = deadline
 	return woc.updateExecutionControl(pod.Name, podExecCtl)
 }
 
+// getStopDeadline returns the deadline of a pod of a stopped workflow with a shutdown grace period,
+// or nil if the pod is left to complete. The deadline is computed when the stop is first observed
+// and kept in the execution control of the pod afterwards.
+func (woc *wfOperationCtx) getStopDeadline(podName string, podExecCtl common.ExecutionControl, wfNodesLock *sync.RWMutex) *time.Time {
+	if woc.wf.Spec.ShutdownGracePeriodSeconds == nil {
+		return nil
+	}
+	wfNodesLock.RLock()
+	node, ok := woc.wf.Status.Nodes[podName]
+	wfNodesLock.RUnlock()
+	if !ok || !woc.isStopped(node.Name) {
+		return nil
+	}
+	if podExecCtl.Deadline != nil && (woc.workflowDeadline == nil || podExecCtl.Deadline.Before(*woc.workflowDeadline)) {
+		return podExecCtl.Deadline
+	}
+	deadline := time.Now().UTC().Add(time.Duration(*woc.wf.Spec.ShutdownGracePeriodSeconds) * time.Second)
+	return &deadline
+}
+
 // killDaemonedChildren kill any daemoned pods of a steps or DAG template node.
 func (woc *wfOperationCtx) killDaemonedChildren(nodeID string) error {
 	woc.log.Infof("Checking daemoned children of %s", nodeID)
@@ -101,6 +132,50 @@ func (woc *wfOperationCtx) killDaemonedChildren(nodeID string) error {
 	return firstErr
 }
 
+// killNodeTree marks a node and the nodes it started which did not complete with the given phase,
+// typically failed or skipped, and signals their pods to terminate. Returns the IDs of the nodes
+// it marked.
+func (woc *wfOperationCtx) killNodeTree(nodeID string, phase wfv1.NodePhase, message string) []string {
+	tree := map[string]bool{nodeID: true}
+	for added := true; added; {
+		added = false
+		for id, node := range woc.wf.Status.Nodes {
+			if !tree[id] && tree[node.BoundaryID] {
+				tree[id] = true
+				added = true
+			}
+			// the attempts of a retry node share its boundary
+			if tree[id] && node.Type == wfv1.NodeTypeRetry {
+				for _, childID := range node.Children {
+					if !tree[childID] {
+						tree[childID] = true
+						added = true
+					}
+				}
+			}
+		}
+	}
+	execCtl := common.ExecutionControl{
+		Deadline: &time.Time{},
+	}
+	var killed []string
+	for id := range tree {
+		node, ok := woc.wf.Status.Nodes[id]
+		if !ok || node.Completed() {
+			continue
+		}
+		if node.Type == wfv1.NodeTypePod {
+			err := woc.updateExecutionControl(node.ID, execCtl)
+			if err != nil {
+				woc.log.Errorf("Failed to update execution control of node %s: %+v", node.ID, err)
+			}
+		}
+		woc.markNodePhase(node.Name, phase, message)
+		killed = append(killed, id)
+	}
+	return killed
+}
+
 // updateExecutionControl updates the execution control parameters
 func (woc *wfOperationCtx) updateExecutionControl(podName string, execCtl common.ExecutionControl) error {
 	execCtlBytes, err := json.Marshal(execCtl)
@@ -146,3 +221,61 @@ func (woc *wfOperationCtx) updateExecutionControl(podName string, execCtl common
 
 	return nil
 }
+
+// applyPendingPolicy deletes a pending pod and fails its node when the pod is pending for one of the
+// failure reasons of the pending policy, or longer than its max duration. Returns whether the pod
+// was deleted
+func (woc *wfOperationCtx) applyPendingPolicy(pod *apiv1.Pod, wfNodesLock *sync.RWMutex) bool {
+	policy := woc.getPendingPolicy(pod)
+	if policy == nil {
+		return false
+	}
+	var message string
+	for _, reason := range getPendingReasons(pod) {
+		for _, failureReason := range policy.FailureReasons {
+			if reason == failureReason {
+				message = fmt.Sprintf("pod failed while pending: %s", getPendingReason(pod))
+			}
+		}
+	}
+	if message == "" && policy.MaxDuration != nil {
+		maxDuration := time.Duration(*policy.MaxDuration) * time.Second
+		pendingDuration := time.Now().Sub(pod.ObjectMeta.CreationTimestamp.Time)
+		if pendingDuration < maxDuration {
+			// We need to requeue the workflow to ensure that the pod gets looked at again when it expires
+			woc.requeueAfter(maxDuration - pendingDuration)
+			return false
+		}
+		message = fmt.Sprintf("pod pending for more than %s", maxDuration)
+		if reason := getPendingReason(pod); reason != "" {
+			message = fmt.Sprintf("%s: %s", message, reason)
+		}
+	}
+	if message == "" {
+		return false
+	}
+	woc.log.Infof("Deleting Pending pod %s/%s: %s", pod.Namespace, pod.Name, message)
+	err := woc.controller.kubeclientset.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{})
+	if err != nil {
+		woc.log.Warnf("Failed to delete %s/%s: %v", pod.Namespace, pod.Name, err)
+		return false
+	}
+	wfNodesLock.Lock()
+	defer wfNodesLock.Unlock()
+	node := woc.wf.Status.Nodes[pod.Name]
+	woc.markNodePhase(node.Name, wfv1.NodeFailed, message)
+	return true
+}
+
+// getPendingPolicy returns the pending policy of the controller, overridden by the one of the template
+// of the pod
+func (woc *wfOperationCtx) getPendingPolicy(pod *apiv1.Pod) *wfv1.PendingPolicy {
+	var tmpl wfv1.Template
+	if tmplStr, ok := pod.Annotations[common.AnnotationKeyTemplate]; ok {
+		err := json.Unmarshal([]byte(tmplStr), &tmpl)
+		if err != nil {
+			woc.log.Warnf("%s template annotation unreadable: %v", pod.Name, err)
+		}
+	}
+	return woc.controller.Config.PendingPolicy.Merge(tmpl.PendingPolicy)
+}
@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cyrusbiotechnology/argo/errors"
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+	"github.com/cyrusbiotechnology/argo/util/file"
+)
+
+// defaultMaxLoopArtifactItems is the default maximum number of items of a withArtifact loop
+const defaultMaxLoopArtifactItems = 10000
+
+// artifactResources gives the artifact drivers of the controller access to the secrets and config
+// maps of the namespace of a workflow. Unlike the executor, the controller does not mount the
+// secrets of the artifacts, so they are read from the API server.
+type artifactResources struct {
+	kubeclientset kubernetes.Interface
+	namespace     string
+}
+
+func (r *artifactResources) GetNamespace() string {
+	return r.namespace
+}
+
+func (r *artifactResources) GetSecrets(namespace, name, key string) ([]byte, error) {
+	secret, err := r.kubeclientset.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	val, ok := secret.Data[key]
+	if !ok {
+		return nil, errors.Errorf(errors.CodeBadRequest, "secret '%s' does not have the key '%s'", name, key)
+	}
+	return val, nil
+}
+
+func (r *artifactResources) GetSecretFromVolMount(name, key string) ([]byte, error) {
+	return r.GetSecrets(r.namespace, name, key)
+}
+
+func (r *artifactResources) GetConfigMapKey(namespace, name, key string) (string, error) {
+	cm, err := r.kubeclientset.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.InternalWrapError(err)
+	}
+	val, ok := cm.Data[key]
+	if !ok {
+		return "", errors.Errorf(errors.CodeBadRequest, "configmap '%s' does not have the key '%s'", name, key)
+	}
+	return val, nil
+}
+
+// getLoopArtifactItems returns the items of a withArtifact loop. The items are loaded from the
+// artifact the first time, and cached in the workflow status under the name of the node of the loop.
+func (woc *wfOperationCtx) getLoopArtifactItems(nodeName string, loopArt *wfv1.LoopArtifact, scope *wfScope) ([]wfv1.Item, error) {
+	var items []wfv1.Item
+	if cached, ok := woc.wf.Status.LoopItems[nodeName]; ok {
+		itemsJSON, err := file.DecodeDecompressString(cached)
+		if err != nil {
+			return nil, errors.InternalWrapError(err)
+		}
+		err = json.Unmarshal([]byte(itemsJSON), &items)
+		if err != nil {
+			return nil, errors.InternalWrapError(err)
+		}
+		return items, nil
+	}
+	art, err := scope.resolveArtifact(loopArt.From)
+	if err != nil {
+		return nil, err
+	}
+	data, err := woc.loadArtifact(art)
+	if err != nil {
+		return nil, err
+	}
+	items, err = parseLoopItems(data, loopArt.Format)
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeBadRequest, "withArtifact %s could not be parsed: %v", loopArt.From, err)
+	}
+	maxItems := woc.controller.Config.MaxLoopArtifactItems
+	if maxItems <= 0 {
+		maxItems = defaultMaxLoopArtifactItems
+	}
+	if len(items) > maxItems {
+		return nil, errors.Errorf(errors.CodeBadRequest, "withArtifact %s holds %d items, which exceeds the maximum of %d", loopArt.From, len(items), maxItems)
+	}
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	if woc.wf.Status.LoopItems == nil {
+		woc.wf.Status.LoopItems = make(map[string]string)
+	}
+	woc.wf.Status.LoopItems[nodeName] = file.CompressEncodeString(string(itemsJSON))
+	woc.updated = true
+	woc.log.Infof("Loaded %d items of node %s from %s", len(items), nodeName, loopArt.From)
+	return items, nil
+}
+
+// loadArtifact loads an artifact from the artifact repository and returns its content
+func (woc *wfOperationCtx) loadArtifact(art *wfv1.Artifact) ([]byte, error) {
+	driver, err := woc.controller.newArtifactDriver(art, &artifactResources{
+		kubeclientset: woc.controller.kubeclientset,
+		namespace:     woc.wf.ObjectMeta.Namespace,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tmpDir, err := ioutil.TempDir("", "loop-artifact")
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	path := filepath.Join(tmpDir, "items")
+	err = driver.Load(art, path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.InternalWrapError(err)
+	}
+	return untarArtifact(data)
+}
+
+// untarArtifact returns the content of an artifact archived with the default tar strategy of the
+// executor, i.e. a gzipped tarball of a single file. The artifacts which are not archived, such as
+// the ones with the none strategy, are returned as is.
+func untarArtifact(data []byte) ([]byte, error) {
+	content := data
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gzr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "artifact is not a valid gzip file: %v", err)
+		}
+		content, err = ioutil.ReadAll(gzr)
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "artifact is not a valid gzip file: %v", err)
+		}
+	}
+	if len(content) == 0 {
+		return content, nil
+	}
+	tr := tar.NewReader(bytes.NewReader(content))
+	var file []byte
+	found := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if found {
+				return nil, errors.Errorf(errors.CodeBadRequest, "artifact is not a valid tarball: %v", err)
+			}
+			// not a tarball
+			return content, nil
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if found {
+			return nil, errors.New(errors.CodeBadRequest, "artifact is a directory rather than a file")
+		}
+		file, err = ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "artifact is not a valid tarball: %v", err)
+		}
+		found = true
+	}
+	if !found {
+		return nil, errors.New(errors.CodeBadRequest, "artifact is an empty tarball")
+	}
+	return file, nil
+}
+
+// parseLoopItems parses the items of a withArtifact loop
+func parseLoopItems(data []byte, format wfv1.LoopArtifactFormat) ([]wfv1.Item, error) {
+	var items []wfv1.Item
+	switch format {
+	case "", wfv1.LoopArtifactFormatJSON:
+		err := json.Unmarshal(data, &items)
+		if err != nil {
+			return nil, fmt.Errorf("not a JSON list: %v", err)
+		}
+	case wfv1.LoopArtifactFormatLines:
+		for i, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			item := wfv1.Item{Value: line}
+			if strings.HasPrefix(line, "{") {
+				var obj map[string]interface{}
+				err := json.Unmarshal([]byte(line), &obj)
+				if err != nil {
+					return nil, fmt.Errorf("line %d is not a JSON object: %v", i+1, err)
+				}
+				item.Value = obj
+			}
+			items = append(items, item)
+		}
+	default:
+		return nil, fmt.Errorf("unknown format '%s'", format)
+	}
+	return items, nil
+}
@@ -0,0 +1,335 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cyrusbiotechnology/argo/errors"
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+	"github.com/cyrusbiotechnology/argo/util/archive"
+	"github.com/cyrusbiotechnology/argo/util/file"
+	artifact "github.com/cyrusbiotechnology/argo/workflow/artifacts"
+	"github.com/cyrusbiotechnology/argo/workflow/common"
+	"github.com/cyrusbiotechnology/argo/workflow/config"
+)
+
+// fakeLoopArtifactDriver serves the content of the artifacts keyed by their S3 key
+type fakeLoopArtifactDriver struct {
+	contents map[string]string
+	loads    int
+}
+
+func (d *fakeLoopArtifactDriver) Load(art *wfv1.Artifact, path string) error {
+	d.loads++
+	content, ok := d.contents[art.S3.Key]
+	if !ok {
+		return errors.Errorf(errors.CodeNotFound, "%s not found", art.S3.Key)
+	}
+	return ioutil.WriteFile(path, []byte(content), 0644)
+}
+
+func (d *fakeLoopArtifactDriver) Save(path string, art *wfv1.Artifact) error {
+	return errors.New(errors.CodeInternal, "not supported")
+}
+
+func (d *fakeLoopArtifactDriver) newDriver(art *wfv1.Artifact, ri common.ResourceInterface) (artifact.ArtifactDriver, error) {
+	return d, nil
+}
+
+// tarGz archives a file of the given content like the default tar strategy of the executor
+func tarGz(t *testing.T, content string) string {
+	tmpDir, err := ioutil.TempDir("", "artifact")
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	path := filepath.Join(tmpDir, "items.txt")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	var buf bytes.Buffer
+	assert.NoError(t, archive.TarGzToWriter(path, &buf))
+	return buf.String()
+}
+
+// makePodSucceeded simulates the success of a pod with the outputs reported by its executor
+func makePodSucceeded(t *testing.T, kubeclientset kubernetes.Interface, podName string, outputs *wfv1.Outputs) {
+	podcs := kubeclientset.CoreV1().Pods("")
+	pod, err := podcs.Get(podName, metav1.GetOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	outputBytes, err := json.Marshal(outputs)
+	assert.NoError(t, err)
+	pod.Annotations[common.AnnotationKeyOutputs] = string(outputBytes)
+	pod.Status.Phase = apiv1.PodSucceeded
+	_, err = podcs.Update(pod)
+	assert.NoError(t, err)
+}
+
+var stepArtifactLoop = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: step-artifact-loop
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: generate
+        template: generate
+    - - name: process
+        template: process
+        arguments:
+          parameters:
+          - name: sample
+            value: "{{item}}"
+        withArtifact:
+          from: "{{steps.generate.outputs.artifacts.samples}}"
+          format: lines
+  - name: generate
+    container:
+      image: alpine:latest
+      command: [sh, -c]
+      args: ["printf 'a\nb\n\nc\n' > /tmp/samples.txt"]
+    outputs:
+      artifacts:
+      - name: samples
+        path: /tmp/samples.txt
+  - name: process
+    inputs:
+      parameters:
+      - name: sample
+    container:
+      image: alpine:latest
+      args: ["{{inputs.parameters.sample}}"]
+`
+
+var generateOutputs = &wfv1.Outputs{
+	Artifacts: []wfv1.Artifact{{
+		Name:             "samples",
+		ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "samples.txt"}},
+	}},
+}
+
+func TestStepArtifactLoop(t *testing.T) {
+	controller := newController()
+	driver := &fakeLoopArtifactDriver{contents: map[string]string{"samples.txt": "a\nb\n\nc\n"}}
+	controller.newArtifactDriver = driver.newDriver
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(stepArtifactLoop))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.artifactRepository.S3 = new(config.S3ArtifactRepository)
+	woc.operate()
+	makePodSucceeded(t, controller.kubeclientset, woc.wf.NodeID("step-artifact-loop[0].generate"), generateOutputs)
+
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	expected := map[string][]string{
+		"step-artifact-loop[1].process(0:a)": {"a"},
+		"step-artifact-loop[1].process(1:b)": {"b"},
+		"step-artifact-loop[1].process(2:c)": {"c"},
+	}
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	for nodeName, args := range expected {
+		pod, err := podcs.Get(woc.wf.NodeID(nodeName), metav1.GetOptions{})
+		if assert.NoError(t, err, nodeName) {
+			assert.Equal(t, args, pod.Spec.Containers[1].Args, nodeName)
+		}
+	}
+	assert.Equal(t, 1, driver.loads)
+	assert.Contains(t, woc.wf.Status.LoopItems, "step-artifact-loop[1].process")
+
+	// the items are expanded from the status afterwards
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	assert.Equal(t, 1, driver.loads)
+	pods, err := podcs.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods.Items, 1+len(expected))
+}
+
+func TestStepArtifactLoopTarball(t *testing.T) {
+	controller := newController()
+	driver := &fakeLoopArtifactDriver{contents: map[string]string{"samples.txt": tarGz(t, "a\nb\n\nc\n")}}
+	controller.newArtifactDriver = driver.newDriver
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(stepArtifactLoop))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.artifactRepository.S3 = new(config.S3ArtifactRepository)
+	woc.operate()
+	makePodSucceeded(t, controller.kubeclientset, woc.wf.NodeID("step-artifact-loop[0].generate"), generateOutputs)
+
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	for nodeName, args := range map[string][]string{
+		"step-artifact-loop[1].process(0:a)": {"a"},
+		"step-artifact-loop[1].process(1:b)": {"b"},
+		"step-artifact-loop[1].process(2:c)": {"c"},
+	} {
+		pod, err := podcs.Get(woc.wf.NodeID(nodeName), metav1.GetOptions{})
+		if assert.NoError(t, err, nodeName) {
+			assert.Equal(t, args, pod.Spec.Containers[1].Args, nodeName)
+		}
+	}
+}
+
+func TestUntarArtifact(t *testing.T) {
+	data, err := untarArtifact([]byte(tarGz(t, `["a"]`)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, `["a"]`, string(data))
+	}
+	// the artifacts which are not archived are returned as is
+	data, err = untarArtifact([]byte(`["a"]`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, `["a"]`, string(data))
+	}
+	data, err = untarArtifact(file.CompressContent([]byte("a\nb\n")))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a\nb\n", string(data))
+	}
+	tmpDir, err := ioutil.TempDir("", "artifact")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("a"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "b.txt"), []byte("b"), 0644))
+	var buf bytes.Buffer
+	assert.NoError(t, archive.TarGzToWriter(tmpDir, &buf))
+	_, err = untarArtifact(buf.Bytes())
+	if assert.Error(t, err) {
+		assert.Equal(t, "artifact is a directory rather than a file", err.Error())
+	}
+}
+
+func TestStepArtifactLoopMaxItems(t *testing.T) {
+	controller := newController()
+	controller.Config.MaxLoopArtifactItems = 2
+	driver := &fakeLoopArtifactDriver{contents: map[string]string{"samples.txt": "a\nb\n\nc\n"}}
+	controller.newArtifactDriver = driver.newDriver
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(stepArtifactLoop))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.artifactRepository.S3 = new(config.S3ArtifactRepository)
+	woc.operate()
+	makePodSucceeded(t, controller.kubeclientset, woc.wf.NodeID("step-artifact-loop[0].generate"), generateOutputs)
+
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	node := woc.getNodeByName("step-artifact-loop[1]")
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeError, node.Phase)
+		assert.Equal(t, "withArtifact {{steps.generate.outputs.artifacts.samples}} holds 3 items, which exceeds the maximum of 2", node.Message)
+	}
+	assert.Empty(t, woc.wf.Status.LoopItems)
+}
+
+var dagArtifactLoop = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: dag-artifact-loop
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    dag:
+      tasks:
+      - name: generate
+        template: generate
+      - name: process
+        dependencies: [generate]
+        template: process
+        arguments:
+          parameters:
+          - name: sample
+            value: "{{item.sample}}-{{item.reads}}"
+        withArtifact:
+          from: "{{tasks.generate.outputs.artifacts.samples}}"
+  - name: generate
+    container:
+      image: alpine:latest
+    outputs:
+      artifacts:
+      - name: samples
+        path: /tmp/samples.json
+  - name: process
+    inputs:
+      parameters:
+      - name: sample
+    container:
+      image: alpine:latest
+      args: ["{{inputs.parameters.sample}}"]
+`
+
+func TestDagArtifactLoop(t *testing.T) {
+	controller := newController()
+	driver := &fakeLoopArtifactDriver{contents: map[string]string{
+		"samples.txt": `[{"sample": "a", "reads": 1}, {"sample": "b", "reads": 2}]`,
+	}}
+	controller.newArtifactDriver = driver.newDriver
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(dagArtifactLoop))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.artifactRepository.S3 = new(config.S3ArtifactRepository)
+	woc.operate()
+	makePodSucceeded(t, controller.kubeclientset, woc.wf.NodeID("dag-artifact-loop.generate"), generateOutputs)
+
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	for nodeName, args := range map[string][]string{
+		"dag-artifact-loop.process(0:reads:1,sample:a)": {"a-1"},
+		"dag-artifact-loop.process(1:reads:2,sample:b)": {"b-2"},
+	} {
+		pod, err := podcs.Get(woc.wf.NodeID(nodeName), metav1.GetOptions{})
+		if assert.NoError(t, err, nodeName) {
+			assert.Equal(t, args, pod.Spec.Containers[1].Args, nodeName)
+		}
+	}
+	assert.Contains(t, woc.wf.Status.LoopItems, "dag-artifact-loop.process")
+}
+
+func TestParseLoopItems(t *testing.T) {
+	items, err := parseLoopItems([]byte("a\n  {\"sample\": \"b\"}\n\n"), wfv1.LoopArtifactFormatLines)
+	if assert.NoError(t, err) && assert.Len(t, items, 2) {
+		assert.Equal(t, "a", items[0].Value)
+		assert.Equal(t, map[string]interface{}{"sample": "b"}, items[1].Value)
+	}
+	items, err = parseLoopItems([]byte(`["a", 1]`), "")
+	if assert.NoError(t, err) && assert.Len(t, items, 2) {
+		assert.Equal(t, "a", items[0].Value)
+	}
+	_, err = parseLoopItems([]byte(`{"sample": "a"}`), wfv1.LoopArtifactFormatJSON)
+	assert.Error(t, err)
+	_, err = parseLoopItems([]byte("a\n{b}"), wfv1.LoopArtifactFormatLines)
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), "line 2 is not a JSON object"))
+	}
+}
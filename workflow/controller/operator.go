@@ -23,6 +23,7 @@ import (
 	apierr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/utils/pointer"
 	"sigs.k8s.io/yaml"
@@ -34,7 +35,9 @@ import (
 	"github.com/cyrusbiotechnology/argo/util/retry"
 	"github.com/cyrusbiotechnology/argo/workflow/common"
 	"github.com/cyrusbiotechnology/argo/workflow/config"
+	"github.com/cyrusbiotechnology/argo/workflow/metrics"
 	"github.com/cyrusbiotechnology/argo/workflow/templateresolution"
+	"github.com/cyrusbiotechnology/argo/workflow/tracing"
 	"github.com/cyrusbiotechnology/argo/workflow/util"
 	"github.com/cyrusbiotechnology/argo/workflow/validate"
 )
@@ -72,12 +75,19 @@ type wfOperationCtx struct {
 	// activePods tracks the number of active (Running/Pending) pods for controlling
 	// parallelism
 	activePods int64
+	// namespaceActivePods tracks the number of active (Running/Pending) pods of the namespace for
+	// controlling the namespace max active pods
+	namespaceActivePods int64
 	// workflowDeadline is the deadline which the workflow is expected to complete before we
 	// terminate the workflow.
 	workflowDeadline *time.Time
 
 	// tmplCtx is the context of template search.
 	tmplCtx *templateresolution.Context
+	// tracedNodes is the set of completed nodes whose span has been emitted. nil when tracing is disabled
+	tracedNodes map[string]bool
+	// tracedWorkflow indicates whether the span of the workflow itself has been emitted
+	tracedWorkflow bool
 }
 
 var _ wfv1.TemplateStorage = &wfOperationCtx{}
@@ -135,6 +145,8 @@ func newWorkflowOperationCtx(wf *wfv1.Workflow, wfc *WorkflowController) *wfOper
 // TODO: an error returned by this method should result in requeuing the workflow to be retried at a
 // later time
 func (woc *wfOperationCtx) operate() {
+	defer metrics.ObserveOperationDuration(time.Now())
+	woc.snapshotCompletedNodes()
 	defer func() {
 		if woc.wf.Status.Completed() {
 			_ = woc.killDaemonedChildren("")
@@ -157,6 +169,7 @@ func (woc *wfOperationCtx) operate() {
 	// Perform one-time workflow validation
 	if woc.wf.Status.Phase == "" {
 		woc.markWorkflowRunning()
+		woc.setWorkflowDefaults()
 		validateOpts := validate.ValidateOpts{ContainerRuntimeExecutor: woc.controller.Config.ContainerRuntimeExecutor}
 		wftmplGetter := templateresolution.WrapWorkflowTemplateInterface(woc.controller.wfclientset.ArgoprojV1alpha1().WorkflowTemplates(woc.wf.Namespace))
 		err := validate.ValidateWorkflow(wftmplGetter, woc.wf, validateOpts)
@@ -182,6 +195,14 @@ func (woc *wfOperationCtx) operate() {
 	if woc.wf.Spec.Parallelism != nil {
 		woc.activePods = woc.countActivePods()
 	}
+	if woc.controller.Config.Namespaces[woc.wf.ObjectMeta.Namespace].MaxActivePods > 0 {
+		namespaceActivePods, err := woc.controller.countNamespaceActivePods(woc.wf.ObjectMeta.Namespace)
+		if err != nil {
+			woc.log.Errorf("%s namespace active pods count error: %+v", woc.wf.ObjectMeta.Name, err)
+			return
+		}
+		woc.namespaceActivePods = namespaceActivePods
+	}
 
 	woc.setGlobalParameters()
 
@@ -225,7 +246,9 @@ func (woc *wfOperationCtx) operate() {
 	}
 
 	workflowStatus = node.Phase
-	if !node.Successful() && util.IsWorkflowTerminated(woc.wf) {
+	if !node.Successful() && woc.wf.Spec.Shutdown != "" {
+		workflowMessage = woc.shutdownMessage()
+	} else if !node.Successful() && util.IsWorkflowTerminated(woc.wf) {
 		workflowMessage = "terminated"
 	} else {
 		workflowMessage = node.Message
@@ -240,7 +263,7 @@ func (woc *wfOperationCtx) operate() {
 			woc.globalParams[common.GlobalVarWorkflowStatus] = string(workflowStatus)
 		}
 		woc.log.Infof("Running OnExit handler: %s", woc.wf.Spec.OnExit)
-		onExitNodeName := woc.wf.ObjectMeta.Name + ".onExit"
+		onExitNodeName := woc.wf.ObjectMeta.Name + onExitSuffix
 		onExitNode, err = woc.executeTemplate(onExitNodeName, &wfv1.Template{Template: woc.wf.Spec.OnExit}, woc.tmplCtx, woc.wf.Spec.Arguments, "")
 		if err != nil {
 			// the error are handled in the callee so just log it.
@@ -287,15 +310,15 @@ func (woc *wfOperationCtx) operate() {
 }
 
 func (woc *wfOperationCtx) getWorkflowDeadline() *time.Time {
-	if woc.wf.Spec.ActiveDeadlineSeconds == nil {
+	if woc.wf.Spec.ActiveDeadlineSeconds == nil && woc.wf.Spec.Shutdown != wfv1.ShutdownStrategyTerminate {
 		return nil
 	}
 	if woc.wf.Status.StartedAt.IsZero() {
 		return nil
 	}
-	if *woc.wf.Spec.ActiveDeadlineSeconds == 0 {
-		// A zero value for ActiveDeadlineSeconds has special meaning (killed).
-		// Return a zero value time object
+	if util.IsWorkflowTerminated(woc.wf) {
+		// A zero value for ActiveDeadlineSeconds or the Terminate shutdown strategy has special
+		// meaning (killed). Return a zero value time object
 		return &time.Time{}
 	}
 	startedAt := woc.wf.Status.StartedAt.Truncate(time.Second)
@@ -303,6 +326,102 @@ func (woc *wfOperationCtx) getWorkflowDeadline() *time.Time {
 	return &deadline
 }
 
+// shutdownMessage returns the message of the nodes and workflow affected by the shutdown strategy.
+// Terminated workflows keep the message they had before the shutdown strategies were introduced.
+func (woc *wfOperationCtx) shutdownMessage() string {
+	if woc.wf.Spec.Shutdown == wfv1.ShutdownStrategyTerminate {
+		return "terminated"
+	}
+	return fmt.Sprintf("Stopped with strategy '%s'", woc.wf.Spec.Shutdown)
+}
+
+// isStopped returns whether the workflow is stopped and the node, if it is not part of an exit handler
+// or step exit hook, should not be scheduled
+func (woc *wfOperationCtx) isStopped(nodeName string) bool {
+	return util.IsWorkflowStopped(woc.wf) && !onExitNodeOrDescendant.MatchString(nodeName)
+}
+
+// onExitSuffix is the suffix of the name of exit handler nodes, appended to the name of the
+// workflow or of the step or task the exit hook belongs to
+const onExitSuffix = ".onExit"
+
+// onExitHookNode matches the name of an exit handler node or of one of its retries, but not the
+// name of a step or task which merely starts with onExit, e.g. onExitCleanup
+var onExitHookNode = regexp.MustCompile(`\.onExit(\(\d+\))?$`)
+
+// onExitNodeOrDescendant matches the name of an exit handler node, of one of its retries or of a
+// step or task run by it
+var onExitNodeOrDescendant = regexp.MustCompile(`\.onExit(\(\d+\))?($|[.\[])`)
+
+// timeoutMessageFormat is the message of the nodes failed by applyTimeout
+const timeoutMessageFormat = "timeout of %ds exceeded"
+
+// onExitHookNodeName returns the name of the node of the exit hook of a step or task
+func onExitHookNodeName(nodeName string) string {
+	return nodeName + onExitSuffix
+}
+
+// isOnExitHookNode returns whether the node is the exit hook of a step or task, or one of its retries
+func isOnExitHookNode(nodeName string) bool {
+	return onExitHookNode.MatchString(nodeName)
+}
+
+// executeOnExitHook executes the exit hook of a completed step or task. The phase and outputs of
+// the step or task are available to the hook as {{status}} and {{outputs.*}}. Skipped steps and
+// tasks do not run their hook, in which case nil is returned.
+func (woc *wfOperationCtx) executeOnExitHook(onExit string, node *wfv1.NodeStatus, tmplCtx *templateresolution.Context, boundaryID string) (*wfv1.NodeStatus, error) {
+	if onExit == "" || node.Type == wfv1.NodeTypeSkipped || node.Phase == wfv1.NodeSkipped || !node.Completed() {
+		return nil, nil
+	}
+	hookNodeName := onExitHookNodeName(node.Name)
+	localParams := map[string]string{
+		common.LocalVarStatus: string(node.Phase),
+	}
+	if node.Outputs != nil {
+		if node.Outputs.Result != nil {
+			localParams["outputs.result"] = *node.Outputs.Result
+		}
+		for _, param := range node.Outputs.Parameters {
+			if param.Value != nil {
+				localParams["outputs.parameters."+param.Name] = *param.Value
+			}
+		}
+	}
+	woc.log.Debugf("Running exit hook %s of node %s", onExit, node.Name)
+	hookNode, err := woc.executeTemplateWithLocalParams(hookNodeName, &wfv1.Template{Template: onExit}, tmplCtx, wfv1.Arguments{}, localParams, boundaryID)
+	if hookNode != nil {
+		// The hook is connected to the outbound nodes of the step or task, rather than to the node
+		// itself, so that it is not mistaken for an attempt of a retry node.
+		outboundNodeIDs := woc.getOutboundNodes(node.ID)
+		if len(outboundNodeIDs) == 0 {
+			outboundNodeIDs = []string{node.ID}
+		}
+		for _, outNodeID := range outboundNodeIDs {
+			woc.addChildNode(woc.wf.Status.Nodes[outNodeID].Name, hookNodeName)
+		}
+	}
+	return hookNode, err
+}
+
+// getOnExitHookNode returns the node of the exit hook of a step or task. Returns nil if the step or
+// task has no exit hook or it was not run yet.
+func (woc *wfOperationCtx) getOnExitHookNode(onExit string, node *wfv1.NodeStatus) *wfv1.NodeStatus {
+	if onExit == "" || node.Type == wfv1.NodeTypeSkipped || node.Phase == wfv1.NodeSkipped || node.Type == wfv1.NodeTypeTaskGroup {
+		return nil
+	}
+	return woc.getNodeByName(onExitHookNodeName(node.Name))
+}
+
+// onExitHookCompleted returns whether the exit hook of a completed step or task, if any, has completed.
+// The hooks of expanded tasks are accounted for by their task group.
+func (woc *wfOperationCtx) onExitHookCompleted(onExit string, node *wfv1.NodeStatus) bool {
+	if onExit == "" || node.Type == wfv1.NodeTypeSkipped || node.Phase == wfv1.NodeSkipped || node.Type == wfv1.NodeTypeTaskGroup {
+		return true
+	}
+	hookNode := woc.getOnExitHookNode(onExit, node)
+	return hookNode != nil && hookNode.Completed()
+}
+
 // setGlobalParameters sets the globalParam map with global parameters
 func (woc *wfOperationCtx) setGlobalParameters() {
 	woc.globalParams[common.GlobalVarWorkflowName] = woc.wf.ObjectMeta.Name
@@ -350,6 +469,8 @@ func (woc *wfOperationCtx) persistUpdates() {
 		return
 	}
 	wfClient := woc.controller.wfclientset.ArgoprojV1alpha1().Workflows(woc.wf.ObjectMeta.Namespace)
+	// nodes are cleared from the workflow object below when compressed or offloaded
+	nodes := woc.wf.Status.Nodes
 	err := woc.checkAndCompress()
 	if err != nil {
 		woc.log.Warnf("Error compressing workflow: %v", err)
@@ -369,12 +490,14 @@ func (woc *wfOperationCtx) persistUpdates() {
 	if err != nil {
 		woc.log.Warnf("Error updating workflow: %v %s", err, apierr.ReasonForError(err))
 		if argokubeerr.IsRequestEntityTooLargeErr(err) {
+			metrics.IncPersistSizeLimitError()
 			woc.persistWorkflowSizeLimitErr(wfClient, err)
 			return
 		}
 		if !apierr.IsConflict(err) {
 			return
 		}
+		metrics.IncPersistConflict()
 		woc.log.Info("Re-appying updates on latest version and retrying update")
 		err = woc.reapplyUpdate(wfClient)
 		if err != nil {
@@ -382,9 +505,12 @@ func (woc *wfOperationCtx) persistUpdates() {
 			return
 		}
 	}
+	woc.traceCompletedNodes(nodes)
 
 	if woc.controller.wfDBctx != nil {
+		span := woc.startChildSpan("persist workflow", tracing.WorkflowSpanContext(woc.wf))
 		err = woc.controller.wfDBctx.Save(wfDB)
+		tracing.EndSpan(span, err)
 		if err != nil {
 			woc.log.Warnf("Error in persisting workflow : %v %s", err, apierr.ReasonForError(err))
 			if woc.controller.wfDBctx.IsNodeStatusOffload() {
@@ -500,6 +626,26 @@ func (woc *wfOperationCtx) requeue() {
 	woc.controller.wfQueue.Add(key)
 }
 
+// waitForPodQuota requeues this workflow once a pod of its namespace completes
+func (woc *wfOperationCtx) waitForPodQuota() {
+	key, err := cache.MetaNamespaceKeyFunc(woc.wf)
+	if err != nil {
+		woc.log.Errorf("Failed to requeue workflow %s: %v", woc.wf.ObjectMeta.Name, err)
+		return
+	}
+	woc.controller.waitForPodQuota(woc.wf.ObjectMeta.Namespace, key)
+}
+
+// requeueAfter requeues this workflow onto the workqueue for processing after the given duration
+func (woc *wfOperationCtx) requeueAfter(afterDuration time.Duration) {
+	key, err := cache.MetaNamespaceKeyFunc(woc.wf)
+	if err != nil {
+		woc.log.Errorf("Failed to requeue workflow %s: %v", woc.wf.ObjectMeta.Name, err)
+		return
+	}
+	woc.controller.wfQueue.AddAfter(key, afterDuration)
+}
+
 // processNodeRetries updates the retry node state based on the child node state and the retry strategy and returns the node.
 func (woc *wfOperationCtx) processNodeRetries(node *wfv1.NodeStatus, retryStrategy wfv1.RetryStrategy) (*wfv1.NodeStatus, error) {
 	if node.Completed() {
@@ -519,6 +665,11 @@ func (woc *wfOperationCtx) processNodeRetries(node *wfv1.NodeStatus, retryStrate
 		return node, nil
 	}
 
+	// the exit code and error conditions of the retry node are the ones of its last attempt
+	node.ExitCode = lastChildNode.ExitCode
+	node.ErrorConditions = lastChildNode.ErrorConditions
+	woc.wf.Status.Nodes[node.ID] = *node
+
 	if lastChildNode.Successful() {
 		node.Outputs = lastChildNode.Outputs.DeepCopy()
 		woc.wf.Status.Nodes[node.ID] = *node
@@ -530,7 +681,18 @@ func (woc *wfOperationCtx) processNodeRetries(node *wfv1.NodeStatus, retryStrate
 		return woc.markNodePhase(node.Name, wfv1.NodeFailed, lastChildNode.Message), nil
 	}
 
-	if retryStrategy.Limit != nil && int32(len(node.Children)) > *retryStrategy.Limit {
+	infraLimit := woc.getInfrastructureRetryLimit(retryStrategy)
+	infraAttempts, attempts := countRetryAttempts(woc.wf, node, infraLimit)
+	if lastChildNode.InfrastructureFailure != "" && infraLimit != nil {
+		// infrastructure failures are retried under their own budget
+		if infraAttempts > *infraLimit {
+			woc.log.Infoln("No more infrastructure retries left. Failing...")
+			return woc.markNodePhase(node.Name, wfv1.NodeFailed, fmt.Sprintf("No more infrastructure retries left: %s", lastChildNode.Message)), nil
+		}
+		woc.log.Infof("%s of %s failed because of the infrastructure (%s). Trying again...", lastChildNode.Name, node.Name, lastChildNode.InfrastructureFailure)
+		return node, nil
+	}
+	if retryStrategy.Limit != nil && attempts > *retryStrategy.Limit {
 		woc.log.Infoln("No more retries left. Failing...")
 		return woc.markNodePhase(node.Name, wfv1.NodeFailed, "No more retries left"), nil
 	}
@@ -539,6 +701,30 @@ func (woc *wfOperationCtx) processNodeRetries(node *wfv1.NodeStatus, retryStrate
 	return node, nil
 }
 
+// getInfrastructureRetryLimit returns the budget of retries of the infrastructure failures, or nil if
+// they are counted against the limit of the retry strategy
+func (woc *wfOperationCtx) getInfrastructureRetryLimit(retryStrategy wfv1.RetryStrategy) *int32 {
+	if retryStrategy.InfrastructureLimit != nil {
+		return retryStrategy.InfrastructureLimit
+	}
+	return woc.controller.Config.InfrastructureRetryLimit
+}
+
+// countRetryAttempts returns the number of attempts of a retry node which failed because of the
+// infrastructure, and the number of its other attempts. When no budget applies to the
+// infrastructure failures, they are counted with the other attempts.
+func countRetryAttempts(wf *wfv1.Workflow, node *wfv1.NodeStatus, infraLimit *int32) (int32, int32) {
+	var infraAttempts, attempts int32
+	for _, childID := range node.Children {
+		if child, ok := wf.Status.Nodes[childID]; ok && child.InfrastructureFailure != "" && infraLimit != nil {
+			infraAttempts++
+		} else {
+			attempts++
+		}
+	}
+	return infraAttempts, attempts
+}
+
 func (woc *wfOperationCtx) collectConditionResults(pod *apiv1.Pod, currentResults *[]wfv1.ExceptionResult, annotationKey string) error {
 
 	if resultString, ok := pod.Annotations[annotationKey]; ok {
@@ -587,6 +773,7 @@ func (woc *wfOperationCtx) podReconciliation() error {
 	seenPods := make(map[string]bool)
 	seenPodLock := &sync.Mutex{}
 	wfNodesLock := &sync.RWMutex{}
+	var infraFailedNodes []string
 
 	performAssessment := func(pod *apiv1.Pod) error {
 		if pod == nil {
@@ -601,10 +788,17 @@ func (woc *wfOperationCtx) podReconciliation() error {
 		wfNodesLock.Lock()
 		defer wfNodesLock.Unlock()
 		if node, ok := woc.wf.Status.Nodes[nodeID]; ok {
+			if podInfrastructureRetries(pod) != node.InfrastructureRetries {
+				// the pod failed because of the infrastructure, and is being replaced
+				return nil
+			}
 			if newState := assessNodeStatus(pod, &node); newState != nil {
 				woc.wf.Status.Nodes[nodeID] = *newState
 				woc.addOutputsToScope("workflow", node.Outputs, nil)
 				woc.updated = true
+				if newState.Completed() && newState.InfrastructureFailure != "" {
+					infraFailedNodes = append(infraFailedNodes, nodeID)
+				}
 			}
 			node := woc.wf.Status.Nodes[pod.ObjectMeta.Name]
 			if node.Completed() && !node.IsDaemoned() {
@@ -658,17 +852,74 @@ func (woc *wfOperationCtx) podReconciliation() error {
 			// node is not a pod, it is already complete, or it can be re-run.
 			continue
 		}
+		if node.Phase == wfv1.NodePending && node.InfrastructureRetries > 0 {
+			// the pod is being recreated after a failure of the infrastructure
+			continue
+		}
 		if _, ok := seenPods[nodeID]; !ok {
 			node.Message = "pod deleted"
 			node.Phase = wfv1.NodeError
+			node.InfrastructureFailure = wfv1.InfrastructureFailurePodDeleted
 			woc.wf.Status.Nodes[nodeID] = node
 			woc.log.Warnf("pod %s deleted", nodeID)
 			woc.updated = true
+			infraFailedNodes = append(infraFailedNodes, nodeID)
 		}
 	}
+	for _, nodeID := range infraFailedNodes {
+		woc.retryInfrastructureFailure(nodeID)
+	}
 	return nil
 }
 
+// podInfrastructureRetries returns the number of infrastructure retries of the node at the time
+// the pod was created
+func podInfrastructureRetries(pod *apiv1.Pod) int32 {
+	retries, err := strconv.ParseInt(pod.Annotations[common.AnnotationKeyInfrastructureRetries], 10, 32)
+	if err != nil {
+		return 0
+	}
+	return int32(retries)
+}
+
+// retryInfrastructureFailure recreates the pod of a node which failed because of the infrastructure,
+// under the default infrastructure retry budget of the controller. It only applies to the nodes whose
+// template has no retry strategy: the attempts of retry nodes are retried by processNodeRetries.
+func (woc *wfOperationCtx) retryInfrastructureFailure(nodeID string) {
+	limit := woc.controller.Config.InfrastructureRetryLimit
+	node, ok := woc.wf.Status.Nodes[nodeID]
+	if limit == nil || !ok || node.InfrastructureRetries >= *limit {
+		return
+	}
+	_, tmpl, err := woc.tmplCtx.ResolveTemplate(&node)
+	if err != nil {
+		woc.log.Warnf("Failed to resolve the template of node %s: %v", node.Name, err)
+		return
+	}
+	if tmpl.RetryStrategy != nil {
+		return
+	}
+	podcs := woc.controller.kubeclientset.CoreV1().Pods(woc.wf.ObjectMeta.Namespace)
+	err = podcs.Delete(nodeID, &metav1.DeleteOptions{GracePeriodSeconds: pointer.Int64Ptr(0)})
+	if err != nil && !apierr.IsNotFound(err) {
+		woc.log.Warnf("Failed to delete pod %s: %v", nodeID, err)
+		return
+	}
+	woc.log.Infof("%s failed because of the infrastructure (%s). Recreating its pod...", node.Name, node.InfrastructureFailure)
+	node.InfrastructureRetries++
+	node.Phase = wfv1.NodePending
+	node.Message = fmt.Sprintf("recreating the pod after an infrastructure failure (%s)", node.InfrastructureFailure)
+	node.InfrastructureFailure = ""
+	node.FinishedAt = metav1.Time{}
+	node.ExitCode = nil
+	node.Outputs = nil
+	node.ExhaustedResources = nil
+	woc.wf.Status.Nodes[nodeID] = node
+	delete(woc.completedPods, nodeID)
+	delete(woc.succeededPods, nodeID)
+	woc.updated = true
+}
+
 // countActivePods counts the number of active (Pending/Running) pods.
 // Optionally restricts it to a template invocation (boundaryID)
 func (woc *wfOperationCtx) countActivePods(boundaryIDs ...string) int64 {
@@ -693,6 +944,45 @@ func (woc *wfOperationCtx) countActivePods(boundaryIDs ...string) int64 {
 	return activePods
 }
 
+// setWorkflowDefaults merges the workflow defaults configured for the namespace into the workflow spec
+func (woc *wfOperationCtx) setWorkflowDefaults() {
+	defaults := woc.controller.Config.Namespaces[woc.wf.ObjectMeta.Namespace].WorkflowDefaults
+	if defaults == nil {
+		return
+	}
+	spec := &woc.wf.Spec
+	if spec.ServiceAccountName == "" {
+		spec.ServiceAccountName = defaults.ServiceAccountName
+	}
+	if len(defaults.NodeSelector) > 0 {
+		if spec.NodeSelector == nil {
+			spec.NodeSelector = make(map[string]string)
+		}
+		for key, value := range defaults.NodeSelector {
+			if _, ok := spec.NodeSelector[key]; !ok {
+				spec.NodeSelector[key] = value
+			}
+		}
+	}
+	if len(spec.Tolerations) == 0 && len(defaults.Tolerations) > 0 {
+		spec.Tolerations = make([]apiv1.Toleration, len(defaults.Tolerations))
+		for i := range defaults.Tolerations {
+			defaults.Tolerations[i].DeepCopyInto(&spec.Tolerations[i])
+		}
+	}
+	if spec.PodGC == nil && defaults.PodGC != nil {
+		spec.PodGC = defaults.PodGC.DeepCopy()
+	}
+	if spec.TTLSecondsAfterFinished == nil && defaults.TTLSecondsAfterFinished != nil {
+		ttl := *defaults.TTLSecondsAfterFinished
+		spec.TTLSecondsAfterFinished = &ttl
+	}
+	if spec.ActiveDeadlineSeconds == nil && defaults.ActiveDeadlineSeconds != nil {
+		deadline := *defaults.ActiveDeadlineSeconds
+		spec.ActiveDeadlineSeconds = &deadline
+	}
+}
+
 // countActiveChildren counts the number of active (Pending/Running) children nodes of parent parentName
 func (woc *wfOperationCtx) countActiveChildren(boundaryIDs ...string) int64 {
 	var boundaryID = ""
@@ -738,9 +1028,19 @@ func assessNodeStatus(pod *apiv1.Pod, node *wfv1.NodeStatus) *wfv1.NodeStatus {
 	var newPhase wfv1.NodePhase
 	var newDaemonStatus *bool
 	var message string
+	var infraFailure wfv1.InfrastructureFailureReason
+	var exhaustedResources []apiv1.ResourceName
 	updated := false
+	if node.Phase == wfv1.NodeSkipped || node.TimedOut {
+		// the node lost a race or timed out, and its pod is being terminated
+		return nil
+	}
 	switch pod.Status.Phase {
 	case apiv1.PodPending:
+		if node.Completed() {
+			// the node was failed while its pod was pending, and the pod is being deleted
+			return nil
+		}
 		newPhase = wfv1.NodePending
 		newDaemonStatus = pointer.BoolPtr(false)
 		message = getPendingReason(pod)
@@ -753,6 +1053,8 @@ func assessNodeStatus(pod *apiv1.Pod, node *wfv1.NodeStatus) *wfv1.NodeStatus {
 			newPhase = wfv1.NodeSucceeded
 		} else {
 			newPhase, message = inferFailedReason(pod)
+			infraFailure = inferInfrastructureFailure(pod)
+			exhaustedResources = inferExhaustedResources(pod)
 		}
 		newDaemonStatus = pointer.BoolPtr(false)
 	case apiv1.PodRunning:
@@ -784,6 +1086,8 @@ func assessNodeStatus(pod *apiv1.Pod, node *wfv1.NodeStatus) *wfv1.NodeStatus {
 		newPhase = wfv1.NodeError
 		message = fmt.Sprintf("Unexpected pod phase for %s: %s", pod.ObjectMeta.Name, pod.Status.Phase)
 		log.Error(message)
+		// pods of unreachable nodes are in the Unknown phase
+		infraFailure = inferInfrastructureFailure(pod)
 	}
 
 	if newDaemonStatus != nil {
@@ -830,6 +1134,46 @@ func assessNodeStatus(pod *apiv1.Pod, node *wfv1.NodeStatus) *wfv1.NodeStatus {
 		updated = true
 		node.Message = message
 	}
+	if infraFailure != "" && node.InfrastructureFailure != infraFailure {
+		log.Infof("Updating node %s infrastructure failure: %s", node, infraFailure)
+		updated = true
+		node.InfrastructureFailure = infraFailure
+	}
+	if len(exhaustedResources) > 0 && len(node.ExhaustedResources) == 0 {
+		log.Infof("Updating node %s exhausted resources: %v", node, exhaustedResources)
+		updated = true
+		node.ExhaustedResources = exhaustedResources
+	}
+	if pod.Spec.NodeName != "" && node.HostNodeName != pod.Spec.NodeName {
+		log.Infof("Updating node %s host node: %s", node, pod.Spec.NodeName)
+		updated = true
+		node.HostNodeName = pod.Spec.NodeName
+	}
+	if node.SchedulingLatency == nil {
+		if latency := getSchedulingLatency(pod); latency != nil {
+			log.Infof("Updating node %s scheduling latency: %s", node, latency.Duration)
+			updated = true
+			node.SchedulingLatency = latency
+		}
+	}
+	if node.Resources == nil {
+		for _, ctr := range pod.Spec.Containers {
+			if ctr.Name == common.MainContainerName && (len(ctr.Resources.Requests) > 0 || len(ctr.Resources.Limits) > 0) {
+				updated = true
+				node.Resources = ctr.Resources.DeepCopy()
+			}
+		}
+	}
+	if exitCode := getMainExitCode(pod); exitCode != nil && (node.ExitCode == nil || *node.ExitCode != *exitCode) {
+		log.Infof("Updating node %s exit code: %d", node, *exitCode)
+		updated = true
+		node.ExitCode = exitCode
+	}
+	if errorConditions := getErrorConditions(pod); len(errorConditions) > 0 && !reflect.DeepEqual(node.ErrorConditions, errorConditions) {
+		log.Infof("Updating node %s error conditions: %s", node, errorConditions)
+		updated = true
+		node.ErrorConditions = errorConditions
+	}
 
 	if node.Completed() && node.FinishedAt.IsZero() {
 		updated = true
@@ -848,6 +1192,51 @@ func assessNodeStatus(pod *apiv1.Pod, node *wfv1.NodeStatus) *wfv1.NodeStatus {
 	return nil
 }
 
+// getMainExitCode returns the exit code of the main container of a pod, or nil if it did not terminate
+func getMainExitCode(pod *apiv1.Pod) *int32 {
+	for _, ctr := range pod.Status.ContainerStatuses {
+		if ctr.Name == common.MainContainerName && ctr.State.Terminated != nil {
+			exitCode := ctr.State.Terminated.ExitCode
+			return &exitCode
+		}
+	}
+	return nil
+}
+
+// getErrorConditions returns the names of the error conditions raised by a pod, as reported by the executor
+func getErrorConditions(pod *apiv1.Pod) []string {
+	resultString, ok := pod.Annotations[common.AnnotationKeyErrors]
+	if !ok {
+		return nil
+	}
+	var results []wfv1.ExceptionResult
+	err := json.Unmarshal([]byte(resultString), &results)
+	if err != nil {
+		log.Warnf("Failed to unmarshal the error conditions of pod %s: %v", pod.Name, err)
+		return nil
+	}
+	var names []string
+	for _, result := range results {
+		names = append(names, result.Name)
+	}
+	return names
+}
+
+// getSchedulingLatency returns the duration between the creation of a pod and its scheduling, or nil if
+// it is not scheduled yet
+func getSchedulingLatency(pod *apiv1.Pod) *metav1.Duration {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == apiv1.PodScheduled && cond.Status == apiv1.ConditionTrue && !cond.LastTransitionTime.IsZero() {
+			latency := cond.LastTransitionTime.Sub(pod.ObjectMeta.CreationTimestamp.Time)
+			if latency < 0 {
+				latency = 0
+			}
+			return &metav1.Duration{Duration: latency}
+		}
+	}
+	return nil
+}
+
 // getLatestFinishedAt returns the latest finishAt timestamp from all the
 // containers of this pod.
 func getLatestFinishedAt(pod *apiv1.Pod) metav1.Time {
@@ -865,6 +1254,25 @@ func getLatestFinishedAt(pod *apiv1.Pod) metav1.Time {
 	return latest
 }
 
+// getPendingReasons returns the waiting reasons of the containers of a pending pod, and its
+// scheduling reason
+func getPendingReasons(pod *apiv1.Pod) []string {
+	var reasons []string
+	for _, ctrStatuses := range [][]apiv1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, ctrStatus := range ctrStatuses {
+			if ctrStatus.State.Waiting != nil && ctrStatus.State.Waiting.Reason != "" {
+				reasons = append(reasons, ctrStatus.State.Waiting.Reason)
+			}
+		}
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Reason == apiv1.PodReasonUnschedulable {
+			reasons = append(reasons, cond.Reason)
+		}
+	}
+	return reasons
+}
+
 func getPendingReason(pod *apiv1.Pod) string {
 	for _, ctrStatus := range pod.Status.ContainerStatuses {
 		if ctrStatus.State.Waiting != nil {
@@ -892,6 +1300,74 @@ func getPendingReason(pod *apiv1.Pod) string {
 	return ""
 }
 
+// Reasons and conditions set on pods which failed because of the infrastructure
+const (
+	podReasonEvicted          = "Evicted"
+	podReasonNodeLost         = "NodeLost"
+	podReasonNodeShutdown     = "Shutdown"
+	podReasonPreempting       = "Preempting"
+	podConditionDisruption    = "DisruptionTarget"
+	disruptionReasonPreempted = "PreemptionByKubeScheduler"
+	disruptionReasonTaint     = "DeletionByTaintManager"
+	disruptionReasonEvicted   = "EvictionByEvictionAPI"
+)
+
+// inferInfrastructureFailure returns the reason of the failure of a pod if it was caused by the
+// infrastructure (eviction, preemption, node loss), or an empty string otherwise
+func inferInfrastructureFailure(pod *apiv1.Pod) wfv1.InfrastructureFailureReason {
+	switch pod.Status.Reason {
+	case podReasonEvicted:
+		return wfv1.InfrastructureFailureEvicted
+	case podReasonNodeLost:
+		return wfv1.InfrastructureFailureNodeLost
+	case podReasonNodeShutdown, podReasonPreempting:
+		// the graceful shutdown of spot and preemptible instances terminates their pods
+		return wfv1.InfrastructureFailurePreempted
+	}
+	for _, cond := range pod.Status.Conditions {
+		if string(cond.Type) != podConditionDisruption || cond.Status != apiv1.ConditionTrue {
+			continue
+		}
+		switch cond.Reason {
+		case disruptionReasonPreempted:
+			return wfv1.InfrastructureFailurePreempted
+		case disruptionReasonTaint:
+			return wfv1.InfrastructureFailureNodeLost
+		case disruptionReasonEvicted:
+			return wfv1.InfrastructureFailureEvicted
+		}
+	}
+	return ""
+}
+
+// inferExhaustedResources returns the resources which the main container of a failed pod ran out of.
+// The pod status holds no evidence of CPU starvation, so a pod exceeding its active deadline is only
+// considered to lack CPU when the resource escalation of its template opts in to it.
+func inferExhaustedResources(pod *apiv1.Pod) []apiv1.ResourceName {
+	var exhausted []apiv1.ResourceName
+	for _, ctr := range pod.Status.ContainerStatuses {
+		if ctr.Name == common.MainContainerName && ctr.State.Terminated != nil && ctr.State.Terminated.Reason == "OOMKilled" {
+			exhausted = append(exhausted, apiv1.ResourceMemory)
+		}
+	}
+	if pod.Status.Reason == "DeadlineExceeded" && escalatesCPUOnDeadlineExceeded(pod) {
+		exhausted = append(exhausted, apiv1.ResourceCPU)
+	}
+	return exhausted
+}
+
+// escalatesCPUOnDeadlineExceeded returns whether the resource escalation of the template of a pod
+// escalates the CPU when the pod exceeds its active deadline
+func escalatesCPUOnDeadlineExceeded(pod *apiv1.Pod) bool {
+	var tmpl wfv1.Template
+	err := json.Unmarshal([]byte(pod.Annotations[common.AnnotationKeyTemplate]), &tmpl)
+	if err != nil {
+		log.Warnf("%s template annotation unreadable: %v", pod.ObjectMeta.Name, err)
+		return false
+	}
+	return tmpl.RetryStrategy != nil && tmpl.RetryStrategy.ResourceEscalation != nil && tmpl.RetryStrategy.ResourceEscalation.CPUOnDeadlineExceeded
+}
+
 // inferFailedReason returns metadata about a Failed pod to be used in its NodeStatus
 // Returns a tuple of the new phase and message
 func inferFailedReason(pod *apiv1.Pod) (wfv1.NodePhase, string) {
@@ -1145,6 +1621,12 @@ func (woc *wfOperationCtx) getLastChildNode(node *wfv1.NodeStatus) (*wfv1.NodeSt
 // nodeName is the name to be used as the name of the node, and boundaryID indicates which template
 // boundary this node belongs to.
 func (woc *wfOperationCtx) executeTemplate(nodeName string, orgTmpl wfv1.TemplateHolder, tmplCtx *templateresolution.Context, args wfv1.Arguments, boundaryID string) (*wfv1.NodeStatus, error) {
+	return woc.executeTemplateWithLocalParams(nodeName, orgTmpl, tmplCtx, args, nil, boundaryID)
+}
+
+// executeTemplateWithLocalParams is executeTemplate with additional local variables made available
+// to the template, such as the status and outputs of the step an exit hook is invoked for.
+func (woc *wfOperationCtx) executeTemplateWithLocalParams(nodeName string, orgTmpl wfv1.TemplateHolder, tmplCtx *templateresolution.Context, args wfv1.Arguments, extraLocalParams map[string]string, boundaryID string) (*wfv1.NodeStatus, error) {
 	woc.log.Debugf("Evaluating node %s: template: %s, boundaryID: %s", nodeName, common.GetTemplateHolderString(orgTmpl), boundaryID)
 
 	node := woc.getNodeByName(nodeName)
@@ -1153,6 +1635,10 @@ func (woc *wfOperationCtx) executeTemplate(nodeName string, orgTmpl wfv1.Templat
 			woc.log.Debugf("Node %s already completed", nodeName)
 			return node, nil
 		}
+		if node.Type == wfv1.NodeTypeSuspend && woc.isStopped(nodeName) {
+			// suspended nodes are never resumed once the workflow is stopped
+			return woc.markNodePhase(nodeName, wfv1.NodeFailed, woc.shutdownMessage()), nil
+		}
 		woc.log.Debugf("Executing node %s of %s is %s", nodeName, node.Type, node.Phase)
 		// Memoized nodes don't have StartedAt.
 		if node.StartedAt.IsZero() {
@@ -1175,6 +1661,9 @@ func (woc *wfOperationCtx) executeTemplate(nodeName string, orgTmpl wfv1.Templat
 	}
 
 	localParams := make(map[string]string)
+	for k, v := range extraLocalParams {
+		localParams[k] = v
+	}
 	// Inject the pod name. If the pod has a retry strategy, the pod name will be changed and will be injected when it
 	// is determined
 	if resolvedTmpl.IsPodType() && resolvedTmpl.RetryStrategy == nil {
@@ -1203,6 +1692,13 @@ func (woc *wfOperationCtx) executeTemplate(nodeName string, orgTmpl wfv1.Templat
 			woc.log.Debugf("Inject a retry node for node %s", retryNodeName)
 			retryParentNode = woc.initializeExecutableNode(retryNodeName, wfv1.NodeTypeRetry, newTmplCtx, processedTmpl, orgTmpl, boundaryID, wfv1.NodeRunning)
 		}
+		if processedTmpl.Timeout != nil {
+			// the timeout applies to each attempt, which is retried once it timed out
+			lastChildNode, err := woc.getLastChildNode(retryParentNode)
+			if err == nil && lastChildNode != nil {
+				woc.applyTimeout(lastChildNode, *processedTmpl.Timeout)
+			}
+		}
 		processedRetryParentNode, err := woc.processNodeRetries(retryParentNode, *processedTmpl.RetryStrategy)
 		if err != nil {
 			return woc.markNodeError(retryNodeName, err), err
@@ -1221,6 +1717,10 @@ func (woc *wfOperationCtx) executeTemplate(nodeName string, orgTmpl wfv1.Templat
 			return retryParentNode, nil
 		}
 
+		if woc.isStopped(retryNodeName) {
+			return woc.markNodePhase(retryNodeName, wfv1.NodeFailed, woc.shutdownMessage()), nil
+		}
+
 		// Create a new child node and append it to the retry node.
 		nodeName = fmt.Sprintf("%s(%d)", retryNodeName, len(retryParentNode.Children))
 		woc.addChildNode(retryNodeName, nodeName)
@@ -1237,6 +1737,10 @@ func (woc *wfOperationCtx) executeTemplate(nodeName string, orgTmpl wfv1.Templat
 
 	// Initialize node based on the template type.
 	if node == nil {
+		if woc.isStopped(nodeName) {
+			woc.log.Infof("Skipped scheduling of node %s: %s", nodeName, woc.shutdownMessage())
+			return woc.initializeNode(nodeName, wfv1.NodeTypeSkipped, orgTmpl, boundaryID, wfv1.NodeFailed, woc.shutdownMessage()), nil
+		}
 		var nodeType wfv1.NodeType
 		switch processedTmpl.GetType() {
 		case wfv1.TemplateTypeContainer, wfv1.TemplateTypeScript, wfv1.TemplateTypeResource:
@@ -1245,13 +1749,15 @@ func (woc *wfOperationCtx) executeTemplate(nodeName string, orgTmpl wfv1.Templat
 			nodeType = wfv1.NodeTypeSteps
 		case wfv1.TemplateTypeDAG:
 			nodeType = wfv1.NodeTypeDAG
-		case wfv1.TemplateTypeSuspend:
+		case wfv1.TemplateTypeSuspend, wfv1.TemplateTypeApproval:
 			nodeType = wfv1.NodeTypeSuspend
 		default:
 			err := errors.InternalErrorf("Template '%s' has unknown node type", processedTmpl.Name)
 			return woc.initializeNode(nodeName, wfv1.NodeTypeSkipped, orgTmpl, boundaryID, wfv1.NodeError, err.Error()), err
 		}
 		node = woc.initializeExecutableNode(nodeName, nodeType, newTmplCtx, processedTmpl, orgTmpl, boundaryID, wfv1.NodePending)
+	} else if processedTmpl.Timeout != nil && woc.applyTimeout(node, *processedTmpl.Timeout) {
+		return woc.getNodeByName(node.Name), nil
 	}
 
 	switch processedTmpl.GetType() {
@@ -1267,6 +1773,8 @@ func (woc *wfOperationCtx) executeTemplate(nodeName string, orgTmpl wfv1.Templat
 		err = woc.executeDAG(node.Name, newTmplCtx, processedTmpl, boundaryID)
 	case wfv1.TemplateTypeSuspend:
 		err = woc.executeSuspend(node.Name, processedTmpl, boundaryID)
+	case wfv1.TemplateTypeApproval:
+		err = woc.executeApproval(node.Name, processedTmpl, boundaryID)
 	default:
 		err = errors.Errorf(errors.CodeBadRequest, "Template '%s' missing specification", processedTmpl.Name)
 	}
@@ -1283,6 +1791,27 @@ func (woc *wfOperationCtx) executeTemplate(nodeName string, orgTmpl wfv1.Templat
 	return node, nil
 }
 
+// applyTimeout fails a node which ran for longer than the timeout of its template, along with the
+// nodes it started, or requeues the workflow for when the timeout expires. Returns whether the node
+// timed out.
+func (woc *wfOperationCtx) applyTimeout(node *wfv1.NodeStatus, timeout int64) bool {
+	if node.Completed() || node.StartedAt.IsZero() {
+		return false
+	}
+	deadline := node.StartedAt.Add(time.Duration(timeout) * time.Second)
+	if remaining := time.Until(deadline); remaining > 0 {
+		woc.requeueAfter(remaining)
+		return false
+	}
+	woc.log.Infof("Node %s exceeded its timeout of %ds", node.Name, timeout)
+	for _, id := range woc.killNodeTree(node.ID, wfv1.NodeFailed, fmt.Sprintf(timeoutMessageFormat, timeout)) {
+		killed := woc.wf.Status.Nodes[id]
+		killed.TimedOut = true
+		woc.wf.Status.Nodes[id] = killed
+	}
+	return true
+}
+
 // markWorkflowPhase is a convenience method to set the phase of the workflow with optional message
 // optionally marks the workflow completed, which sets the finishedAt timestamp and completed label
 func (woc *wfOperationCtx) markWorkflowPhase(phase wfv1.NodePhase, markCompleted bool, message ...string) {
@@ -1475,6 +2004,12 @@ func (woc *wfOperationCtx) checkParallelism(tmpl *wfv1.Template, node *wfv1.Node
 		woc.log.Infof("workflow active pod spec parallelism reached %d/%d", woc.activePods, *woc.wf.Spec.Parallelism)
 		return ErrParallelismReached
 	}
+	maxActivePods := woc.controller.Config.Namespaces[woc.wf.ObjectMeta.Namespace].MaxActivePods
+	if maxActivePods > 0 && woc.namespaceActivePods >= maxActivePods {
+		woc.log.Infof("namespace active pod parallelism reached %d/%d", woc.namespaceActivePods, maxActivePods)
+		woc.waitForPodQuota()
+		return ErrParallelismReached
+	}
 	// TODO: repeated calls to countActivePods is not optimal
 	switch tmpl.GetType() {
 	case wfv1.TemplateTypeDAG, wfv1.TemplateTypeSteps:
@@ -1622,6 +2157,11 @@ func (woc *wfOperationCtx) executeScript(nodeName string, tmpl *wfv1.Template, b
 		if err != nil {
 			return err
 		}
+		if tasks, ok := woc.wf.Status.DynamicDAGTasks[boundaryNode.Name]; ok {
+			// the tasks of a dynamic DAG are not in its template
+			parentTemplate = parentTemplate.DeepCopy()
+			parentTemplate.DAG.Tasks = tasks
+		}
 		name := getStepOrDAGTaskName(nodeName, tmpl.RetryStrategy != nil)
 		includeScriptOutput = hasOutputResultRef(name, parentTemplate)
 	}
@@ -1646,6 +2186,10 @@ func (woc *wfOperationCtx) processNodeOutputs(scope *wfScope, prefix string, nod
 		key := fmt.Sprintf("%s.status", prefix)
 		scope.addParamToScope(key, string(node.Phase))
 	}
+	if node.ExitCode != nil {
+		key := fmt.Sprintf("%s.exitCode", prefix)
+		scope.addParamToScope(key, fmt.Sprintf("%d", *node.ExitCode))
+	}
 	woc.addOutputsToScope(prefix, node.Outputs, scope)
 }
 
@@ -1701,8 +2245,10 @@ func (n loopNodes) Swap(i, j int) {
 }
 
 // processAggregateNodeOutputs adds the aggregated outputs of a withItems/withParam template as a
-// parameter in the form of a JSON list
-func (woc *wfOperationCtx) processAggregateNodeOutputs(tmpl *wfv1.Template, scope *wfScope, prefix string, childNodes []wfv1.NodeStatus) error {
+// parameter in the form of a JSON list, and its output artifacts as aggregate artifacts. The outputs
+// of the nodes of batches are JSON lists holding the outputs of their items, which are flattened so
+// that the aggregated outputs are per item.
+func (woc *wfOperationCtx) processAggregateNodeOutputs(tmpl *wfv1.Template, scope *wfScope, prefix string, childNodes []wfv1.NodeStatus, batched bool) error {
 	if len(childNodes) == 0 {
 		return nil
 	}
@@ -1714,6 +2260,15 @@ func (woc *wfOperationCtx) processAggregateNodeOutputs(tmpl *wfv1.Template, scop
 		if node.Outputs == nil {
 			continue
 		}
+		if batched {
+			batchParams, batchResults, err := unbatchNodeOutputs(&node)
+			if err != nil {
+				return err
+			}
+			paramList = append(paramList, batchParams...)
+			resultsList = append(resultsList, batchResults...)
+			continue
+		}
 		if len(node.Outputs.Parameters) > 0 {
 			param := make(map[string]string)
 			for _, p := range node.Outputs.Parameters {
@@ -1746,9 +2301,121 @@ func (woc *wfOperationCtx) processAggregateNodeOutputs(tmpl *wfv1.Template, scop
 	}
 	key := fmt.Sprintf("%s.outputs.parameters", prefix)
 	scope.addParamToScope(key, string(outputsJSON))
+	// The output artifacts are aggregated into the manifests of the artifacts of the items, which
+	// are named after the index of their item. Validation rejects the references to the output
+	// artifacts of batched loops, whose nodes are named after the index of their batch.
+	for _, outArt := range tmpl.Outputs.Artifacts {
+		aggregate := wfv1.AggregateArtifact{Artifacts: make([]wfv1.Artifact, 0)}
+		for _, node := range childNodes {
+			if node.Outputs == nil {
+				continue
+			}
+			for _, art := range node.Outputs.Artifacts {
+				if art.Name != outArt.Name || !art.HasLocation() {
+					continue
+				}
+				aggregate.Artifacts = append(aggregate.Artifacts, wfv1.Artifact{
+					Name:             strconv.Itoa(parseLoopIndex(node.DisplayName)),
+					ArtifactLocation: art.ArtifactLocation,
+				})
+			}
+		}
+		key := fmt.Sprintf("%s.outputs.artifacts.%s", prefix, outArt.Name)
+		scope.addArtifactToScope(key, wfv1.Artifact{
+			Name:             outArt.Name,
+			ArtifactLocation: wfv1.ArtifactLocation{Aggregate: &aggregate},
+		})
+	}
 	return nil
 }
 
+// unbatchNodeOutputs splits the outputs of the node of a batch, whose parameters and result are
+// JSON lists with an element per item of the batch, into the outputs of the items. As for the
+// outputs of the items of a loop, the results which are maps are kept as is, and the other values
+// are converted to strings.
+func unbatchNodeOutputs(node *wfv1.NodeStatus) ([]map[string]string, []wfv1.Item, error) {
+	var paramList []map[string]string
+	for _, p := range node.Outputs.Parameters {
+		var values []interface{}
+		err := json.Unmarshal([]byte(*p.Value), &values)
+		if err != nil {
+			return nil, nil, errors.Errorf(errors.CodeBadRequest, "outputs.parameters.%s of batch %s is not a JSON list", p.Name, node.DisplayName)
+		}
+		if paramList == nil {
+			paramList = make([]map[string]string, len(values))
+			for i := range paramList {
+				paramList[i] = make(map[string]string)
+			}
+		} else if len(values) != len(paramList) {
+			return nil, nil, errors.Errorf(errors.CodeBadRequest, "outputs.parameters of batch %s are lists of different lengths", node.DisplayName)
+		}
+		for i, value := range values {
+			paramList[i][p.Name] = batchOutputString(value)
+		}
+	}
+	var resultsList []wfv1.Item
+	if node.Outputs.Result != nil {
+		var values []interface{}
+		err := json.Unmarshal([]byte(*node.Outputs.Result), &values)
+		if err != nil {
+			return nil, nil, errors.Errorf(errors.CodeBadRequest, "outputs.result of batch %s is not a JSON list", node.DisplayName)
+		}
+		for _, value := range values {
+			if valMap, ok := value.(map[string]interface{}); ok {
+				resultsList = append(resultsList, wfv1.Item{Value: valMap})
+			} else {
+				resultsList = append(resultsList, wfv1.Item{Value: batchOutputString(value)})
+			}
+		}
+	}
+	return paramList, resultsList, nil
+}
+
+// batchOutputString converts the output of an item of a batch to a string
+func batchOutputString(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+	valueJSON, _ := json.Marshal(value)
+	return string(valueJSON)
+}
+
+// assessFailureTolerance judges the nodes of the items of a loop against its failure tolerance. The
+// failures continued by continueOn are not counted. It returns whether the failures are within the
+// tolerance, and a message with the counts.
+func assessFailureTolerance(tolerance *intstr.IntOrString, itemNodes []wfv1.NodeStatus, continuesOn func(*wfv1.NodeStatus) bool) (bool, string) {
+	failed := 0
+	for _, itemNode := range itemNodes {
+		if !itemNode.Successful() && !continuesOn(&itemNode) {
+			failed++
+		}
+	}
+	allowed, err := failureToleranceValue(tolerance, len(itemNodes))
+	if err != nil {
+		return false, err.Error()
+	}
+	msg := fmt.Sprintf("%d/%d items failed (failure tolerance: %s)", failed, len(itemNodes), tolerance.String())
+	return failed <= allowed, msg
+}
+
+// failureToleranceValue returns the number of failed items a failure tolerance allows out of total.
+// A templated tolerance is only resolved at runtime, into a string which is either a plain number
+// or a percentage, so it is not necessarily validated upfront.
+func failureToleranceValue(tolerance *intstr.IntOrString, total int) (int, error) {
+	if tolerance.Type == intstr.String {
+		number := strings.TrimSuffix(tolerance.StrVal, "%")
+		isPercent := number != tolerance.StrVal
+		value, err := strconv.Atoi(number)
+		if err != nil || value < 0 || (isPercent && value > 100) {
+			return 0, errors.Errorf(errors.CodeBadRequest, "failure tolerance '%s' is not a number or a percentage between 0%% and 100%%", tolerance.StrVal)
+		}
+		if !isPercent {
+			return value, nil
+		}
+	}
+	return intstr.GetValueFromIntOrPercent(tolerance, total, false)
+}
+
 // addParamToGlobalScope exports any desired node outputs to the global scope, and adds it to the global outputs.
 func (woc *wfOperationCtx) addParamToGlobalScope(param wfv1.Parameter) {
 	if param.GlobalName == "" {
@@ -1873,23 +2540,101 @@ func (woc *wfOperationCtx) executeResource(nodeName string, tmpl *wfv1.Template,
 }
 
 func (woc *wfOperationCtx) executeSuspend(nodeName string, tmpl *wfv1.Template, boundaryID string) error {
+	node := woc.getNodeByName(nodeName)
+	if node.Outputs == nil && len(tmpl.Outputs.Parameters) > 0 {
+		// The values of the output parameters are supplied when the node is resumed
+		outputs := tmpl.Outputs.DeepCopy()
+		node.Outputs = &wfv1.Outputs{Parameters: outputs.Parameters}
+		woc.wf.Status.Nodes[node.ID] = *node
+		woc.updated = true
+	}
 	woc.log.Infof("node %s suspended", nodeName)
 
 	if tmpl.Suspend.Duration != nil && *tmpl.Suspend.Duration > 0 {
-		// We need to requeue this node to ensure that it gets looked at again
-		woc.requeue()
-		node := woc.getNodeByName(nodeName)
-		if time.Now().UTC().After(node.StartedAt.Add(time.Duration(*tmpl.Suspend.Duration) * time.Second)) {
+		resumeAt := node.StartedAt.Add(time.Duration(*tmpl.Suspend.Duration) * time.Second)
+		if !time.Now().UTC().Before(resumeAt) {
 			// Node is expired
 			woc.log.Infof("auto resuming node %s", nodeName)
+			err := util.SetSuspendNodeOutputs(node, nil)
+			if err != nil {
+				return err
+			}
+			woc.wf.Status.Nodes[node.ID] = *node
 			_ = woc.markNodePhase(nodeName, wfv1.NodeSucceeded)
 			return nil
 		}
+		// We need to requeue the workflow to ensure that the node gets looked at again when it expires
+		woc.requeueAfter(resumeAt.Sub(time.Now().UTC()))
 	}
 	_ = woc.markNodePhase(nodeName, wfv1.NodeRunning)
 	return nil
 }
 
+// executeApproval suspends the node until enough approvers approved it, or one rejected it. The
+// decisions are recorded in the node status by `argo approve|reject`. When the timeout of the
+// approval expires, the default decision is applied.
+func (woc *wfOperationCtx) executeApproval(nodeName string, tmpl *wfv1.Template, boundaryID string) error {
+	node := woc.getNodeByName(nodeName)
+	if node.Approval == nil {
+		// The approval policy is copied to the node so that the clients can check their decisions
+		// against it
+		node.Approval = &wfv1.ApprovalStatus{ApprovalTemplate: *tmpl.Approval.DeepCopy()}
+		woc.wf.Status.Nodes[node.ID] = *node
+		woc.updated = true
+	}
+	// The decisions are recorded in the status by the clients, so they are checked against the
+	// policy of the template rather than its copy, and only the first decision of an approver counts
+	policy := tmpl.Approval
+	decided := make(map[string]bool)
+	var approvers []string
+	for _, record := range node.Approval.Decisions {
+		if record.Approver == "" || decided[record.Approver] {
+			continue
+		}
+		if !policy.IsAllowed(record.Approver) {
+			woc.log.Warnf("node %s ignoring the decision of %s, who is not allowed to decide", nodeName, record.Approver)
+			continue
+		}
+		decided[record.Approver] = true
+		switch record.Decision {
+		case wfv1.ApprovalDecisionReject:
+			message := fmt.Sprintf("rejected by %s", record.Approver)
+			if record.Comment != "" {
+				message = fmt.Sprintf("%s: %s", message, record.Comment)
+			}
+			woc.log.Infof("node %s %s", nodeName, message)
+			_ = woc.markNodePhase(nodeName, wfv1.NodeFailed, message)
+			return nil
+		case wfv1.ApprovalDecisionApprove:
+			approvers = append(approvers, record.Approver)
+		}
+	}
+	required := policy.GetRequired()
+	if int32(len(approvers)) >= required {
+		message := fmt.Sprintf("approved by %s", strings.Join(approvers, ", "))
+		woc.log.Infof("node %s %s", nodeName, message)
+		_ = woc.markNodePhase(nodeName, wfv1.NodeSucceeded, message)
+		return nil
+	}
+	woc.log.Infof("node %s awaiting approval", nodeName)
+
+	if policy.Timeout != nil {
+		deadline := node.StartedAt.Add(time.Duration(*policy.Timeout) * time.Second)
+		if !time.Now().UTC().Before(deadline) {
+			if policy.DefaultDecision == wfv1.ApprovalDecisionApprove {
+				_ = woc.markNodePhase(nodeName, wfv1.NodeSucceeded, "approved by default after timeout")
+			} else {
+				_ = woc.markNodePhase(nodeName, wfv1.NodeFailed, "rejected by default after timeout")
+			}
+			return nil
+		}
+		// We need to requeue the workflow to ensure that the node gets looked at again when it expires
+		woc.requeueAfter(deadline.Sub(time.Now().UTC()))
+	}
+	_ = woc.markNodePhase(nodeName, wfv1.NodeRunning, fmt.Sprintf("awaiting approval (%d/%d)", len(approvers), required))
+	return nil
+}
+
 func processItem(fstTmpl *fasttemplate.Template, name string, index int, item wfv1.Item, obj interface{}) (string, error) {
 	replaceMap := make(map[string]string)
 	var newName string
@@ -1908,7 +2653,7 @@ func processItem(fstTmpl *fasttemplate.Template, name string, index int, item wf
 			switch itemVal := itemValIf.(type) {
 			case string, int, int32, int64, float32, float64, bool:
 				replaceMap[fmt.Sprintf("item.%s", itemKey)] = fmt.Sprintf("%v", itemVal)
-				vals = append(vals, fmt.Sprintf("%s:%s", itemKey, itemVal))
+				vals = append(vals, fmt.Sprintf("%s:%v", itemKey, itemVal))
 			default:
 				return "", errors.Errorf(errors.CodeBadRequest, "withItems[%d][%s] expected string or number. received: %v", index, itemKey, itemVal)
 			}
@@ -1939,6 +2684,80 @@ func processItem(fstTmpl *fasttemplate.Template, name string, index int, item wf
 	return newName, nil
 }
 
+// expandMatrix expands the axes of a withMatrix into the items of their cartesian product, in which
+// the values of the first axis vary the slowest. Every item maps the names of the axes to one of
+// their values.
+func expandMatrix(matrix []wfv1.MatrixAxis) ([]wfv1.Item, error) {
+	items := []wfv1.Item{{Value: map[string]interface{}{}}}
+	for _, axis := range matrix {
+		values := axis.Items
+		if axis.Param != "" {
+			err := json.Unmarshal([]byte(axis.Param), &values)
+			if err != nil {
+				return nil, errors.Errorf(errors.CodeBadRequest, "withMatrix axis '%s' param value could not be parsed as a JSON list: %s", axis.Name, strings.TrimSpace(axis.Param))
+			}
+		}
+		product := make([]wfv1.Item, 0, len(items)*len(values))
+		for _, item := range items {
+			for _, value := range values {
+				combination := make(map[string]interface{})
+				for k, v := range item.Value.(map[string]interface{}) {
+					combination[k] = v
+				}
+				combination[axis.Name] = value.Value
+				product = append(product, wfv1.Item{Value: combination})
+			}
+		}
+		items = product
+	}
+	return items, nil
+}
+
+// batchItems groups the items of a loop into batches of up to batchSize items. Every batch is an
+// item holding the list of the values of its items.
+func batchItems(items []wfv1.Item, batchSize int) []wfv1.Item {
+	batches := make([]wfv1.Item, 0, (len(items)+batchSize-1)/batchSize)
+	for start := 0; start < len(items); start += batchSize {
+		end := start + batchSize
+		if end > len(items) {
+			end = len(items)
+		}
+		values := make([]interface{}, 0, end-start)
+		for _, item := range items[start:end] {
+			values = append(values, item.Value)
+		}
+		batches = append(batches, wfv1.Item{Value: values})
+	}
+	return batches
+}
+
+// processBatch is the counterpart of processItem for a batch of items. The batch is substituted as
+// a JSON list in {{item}}, and the indexes of its first and last items in {{batch.start}} and
+// {{batch.end}}. The name of the expanded step or task holds the range of the indexes.
+func processBatch(fstTmpl *fasttemplate.Template, name string, index int, batchSize int, batch wfv1.Item, obj interface{}) (string, error) {
+	values := batch.Value.([]interface{})
+	valuesJSON, err := json.Marshal(values)
+	if err != nil {
+		return "", errors.InternalWrapError(err)
+	}
+	start := index * batchSize
+	end := start + len(values) - 1
+	replaceMap := map[string]string{
+		"item":        string(valuesJSON),
+		"batch.start": strconv.Itoa(start),
+		"batch.end":   strconv.Itoa(end),
+	}
+	newStr, err := common.Replace(fstTmpl, replaceMap, false)
+	if err != nil {
+		return "", err
+	}
+	err = json.Unmarshal([]byte(newStr), &obj)
+	if err != nil {
+		return "", errors.InternalWrapError(err)
+	}
+	return fmt.Sprintf("%s(%d:%d-%d)", name, index, start, end), nil
+}
+
 func expandSequence(seq *wfv1.Sequence) ([]wfv1.Item, error) {
 	var start, end int
 	var err error
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -11,6 +12,13 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/yaml"
 
 	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
@@ -182,6 +190,367 @@ func TestProcessNodesWithRetries(t *testing.T) {
 	assert.Equal(t, n.Phase, wfv1.NodeFailed)
 }
 
+// TestInfrastructureRetries verifies the pods failing because of the infrastructure are recreated
+// under the default budget of the controller when their template has no retry strategy
+func TestInfrastructureRetries(t *testing.T) {
+	controller := newController()
+	controller.Config.InfrastructureRetryLimit = pointer.Int32Ptr(1)
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	wf, err := wfcset.Create(unmarshalWF(helloWorldWf))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	node := woc.getNodeByName("hello-world")
+	assert.Equal(t, wfv1.NodeTypePod, node.Type)
+	assert.Len(t, woc.wf.Status.Nodes, 1)
+
+	evict := func() {
+		pod, err := podcs.Get(node.ID, metav1.GetOptions{})
+		assert.NoError(t, err)
+		pod.Status.Phase = apiv1.PodFailed
+		pod.Status.Reason = "Evicted"
+		pod.Status.Message = "The node was low on resource: memory."
+		_, err = podcs.Update(pod)
+		assert.NoError(t, err)
+		woc = newWorkflowOperationCtx(woc.wf, controller)
+		woc.operate()
+	}
+	evict()
+	node = woc.getNodeByName("hello-world")
+	assert.Equal(t, wfv1.NodePending, node.Phase)
+	assert.Equal(t, int32(1), node.InfrastructureRetries)
+	assert.Empty(t, node.InfrastructureFailure)
+	assert.Len(t, woc.wf.Status.Nodes, 1)
+	pod, err := podcs.Get(node.ID, metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, apiv1.PodPhase(""), pod.Status.Phase)
+		assert.Equal(t, "1", pod.Annotations[common.AnnotationKeyInfrastructureRetries])
+	}
+
+	evict()
+	node = woc.getNodeByName("hello-world")
+	assert.Equal(t, wfv1.NodeFailed, node.Phase)
+	assert.Equal(t, wfv1.InfrastructureFailureEvicted, node.InfrastructureFailure)
+	assert.Equal(t, "The node was low on resource: memory.", node.Message)
+	assert.Equal(t, wfv1.NodeFailed, woc.wf.Status.Phase)
+}
+
+var retryResourceEscalation = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: retry-resource-escalation
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    retryStrategy:
+      limit: 3
+      resourceEscalation:
+        factor: "2"
+        max:
+          memory: 300Mi
+    container:
+      image: docker/whalesay:latest
+      resources:
+        requests:
+          cpu: 500m
+          memory: 100Mi
+        limits:
+          memory: 100Mi
+`
+
+// TestRetryResourceEscalation verifies the memory of the retries of an OOMKilled container is
+// escalated up to the cap, kept after other failures, and recorded in the node status
+func TestRetryResourceEscalation(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	wf, err := wfcset.Create(unmarshalWF(retryResourceEscalation))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	oomKill := func(attempt string) {
+		pod, err := podcs.Get(woc.wf.NodeID(attempt), metav1.GetOptions{})
+		assert.NoError(t, err)
+		pod.Status.Phase = apiv1.PodFailed
+		pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+			Name:  common.MainContainerName,
+			State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}},
+		}}
+		_, err = podcs.Update(pod)
+		assert.NoError(t, err)
+		woc = newWorkflowOperationCtx(woc.wf, controller)
+		woc.operate()
+		assert.Equal(t, []apiv1.ResourceName{apiv1.ResourceMemory}, woc.getNodeByName(attempt).ExhaustedResources)
+	}
+	memoryOf := func(attempt string) (string, string) {
+		node := woc.getNodeByName(attempt)
+		if assert.NotNil(t, node) && assert.NotNil(t, node.Resources) {
+			request, limit := node.Resources.Requests[apiv1.ResourceMemory], node.Resources.Limits[apiv1.ResourceMemory]
+			assert.Equal(t, "500m", node.Resources.Requests.Cpu().String())
+			return request.String(), limit.String()
+		}
+		return "", ""
+	}
+	request, limit := memoryOf("retry-resource-escalation(0)")
+	assert.Equal(t, "100Mi", request)
+	assert.Equal(t, "100Mi", limit)
+
+	oomKill("retry-resource-escalation(0)")
+	request, limit = memoryOf("retry-resource-escalation(1)")
+	assert.Equal(t, "200Mi", request)
+	assert.Equal(t, "200Mi", limit)
+	pod, err := podcs.Get(woc.wf.NodeID("retry-resource-escalation(1)"), metav1.GetOptions{})
+	assert.NoError(t, err)
+	for _, ctr := range pod.Spec.Containers {
+		if ctr.Name == common.MainContainerName {
+			assert.Equal(t, "200Mi", ctr.Resources.Limits.Memory().String())
+		}
+	}
+
+	oomKill("retry-resource-escalation(1)")
+	request, limit = memoryOf("retry-resource-escalation(2)")
+	assert.Equal(t, "300Mi", request)
+	assert.Equal(t, "300Mi", limit)
+
+	// a failure unrelated to the resources keeps the escalated resources
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("retry-resource-escalation(2)"), 1)
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	assert.Empty(t, woc.getNodeByName("retry-resource-escalation(2)").ExhaustedResources)
+	request, limit = memoryOf("retry-resource-escalation(3)")
+	assert.Equal(t, "300Mi", request)
+	assert.Equal(t, "300Mi", limit)
+}
+
+var pendingPolicy = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: pending-policy
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: pull
+        template: pull
+      - name: schedule
+        template: schedule
+  - name: pull
+    retryStrategy:
+      limit: 1
+    container:
+      image: docker/whalesay:missing
+  - name: schedule
+    pendingPolicy:
+      maxDuration: 60
+    container:
+      image: docker/whalesay:latest
+`
+
+// TestPendingPolicy verifies pods pending for a failure reason, or for too long, are deleted and
+// their node failed, subject to the retry strategy
+func TestPendingPolicy(t *testing.T) {
+	controller := newController()
+	controller.Config.PendingPolicy = &wfv1.PendingPolicy{FailureReasons: []string{"ImagePullBackOff"}}
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	wf, err := wfcset.Create(unmarshalWF(pendingPolicy))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	pullPod, err := podcs.Get(woc.wf.NodeID("pending-policy[0].pull(0)"), metav1.GetOptions{})
+	assert.NoError(t, err)
+	pullPod.Status.Phase = apiv1.PodPending
+	pullPod.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+		Name:  common.MainContainerName,
+		State: apiv1.ContainerState{Waiting: &apiv1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image"}},
+	}}
+	_, err = podcs.Update(pullPod)
+	assert.NoError(t, err)
+	schedulePod, err := podcs.Get(woc.wf.NodeID("pending-policy[0].schedule"), metav1.GetOptions{})
+	assert.NoError(t, err)
+	schedulePod.CreationTimestamp = metav1.Time{Time: time.Now()}
+	schedulePod.Status.Phase = apiv1.PodPending
+	schedulePod.Status.Conditions = []apiv1.PodCondition{{Type: apiv1.PodScheduled, Reason: apiv1.PodReasonUnschedulable}}
+	_, err = podcs.Update(schedulePod)
+	assert.NoError(t, err)
+
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	pull := woc.getNodeByName("pending-policy[0].pull(0)")
+	assert.Equal(t, wfv1.NodeFailed, pull.Phase)
+	assert.Equal(t, "pod failed while pending: ImagePullBackOff: Back-off pulling image", pull.Message)
+	_, err = podcs.Get(pull.ID, metav1.GetOptions{})
+	assert.Error(t, err)
+	// the node is retried, and the template overrides the failure reasons of the controller
+	assert.NotNil(t, woc.getNodeByName("pending-policy[0].pull(1)"))
+	assert.Equal(t, wfv1.NodePending, woc.getNodeByName("pending-policy[0].schedule").Phase)
+
+	schedulePod, err = podcs.Get(woc.wf.NodeID("pending-policy[0].schedule"), metav1.GetOptions{})
+	assert.NoError(t, err)
+	schedulePod.CreationTimestamp = metav1.Time{Time: time.Now().Add(-2 * time.Minute)}
+	_, err = podcs.Update(schedulePod)
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	schedule := woc.getNodeByName("pending-policy[0].schedule")
+	assert.Equal(t, wfv1.NodeFailed, schedule.Phase)
+	assert.Equal(t, "pod pending for more than 1m0s: Unschedulable", schedule.Message)
+}
+
+func TestInferInfrastructureFailure(t *testing.T) {
+	pod := &apiv1.Pod{Status: apiv1.PodStatus{Phase: apiv1.PodFailed}}
+	assert.Equal(t, wfv1.InfrastructureFailureReason(""), inferInfrastructureFailure(pod))
+	pod.Status.Reason = "NodeLost"
+	assert.Equal(t, wfv1.InfrastructureFailureNodeLost, inferInfrastructureFailure(pod))
+	pod.Status.Reason = ""
+	pod.Status.Conditions = []apiv1.PodCondition{{Type: "DisruptionTarget", Status: apiv1.ConditionTrue, Reason: "PreemptionByKubeScheduler"}}
+	assert.Equal(t, wfv1.InfrastructureFailurePreempted, inferInfrastructureFailure(pod))
+}
+
+func TestFailureToleranceValue(t *testing.T) {
+	for tolerance, allowed := range map[string]int{"1": 1, "50%": 2, "0%": 0} {
+		value, err := failureToleranceValue(&intstr.IntOrString{Type: intstr.String, StrVal: tolerance}, 4)
+		if assert.NoError(t, err) {
+			assert.Equal(t, allowed, value)
+		}
+	}
+	for _, tolerance := range []string{"one", "-1", "110%", ""} {
+		_, err := failureToleranceValue(&intstr.IntOrString{Type: intstr.String, StrVal: tolerance}, 4)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), fmt.Sprintf("failure tolerance '%s' is not a number or a percentage", tolerance))
+		}
+	}
+}
+
+func TestInferExhaustedResources(t *testing.T) {
+	pod := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{common.AnnotationKeyTemplate: `{"retryStrategy":{"resourceEscalation":{}}}`}},
+		Status:     apiv1.PodStatus{Phase: apiv1.PodFailed, Reason: "DeadlineExceeded"},
+	}
+	// an exceeded deadline is no evidence of CPU starvation unless the template opts in
+	assert.Empty(t, inferExhaustedResources(pod))
+	pod.Annotations[common.AnnotationKeyTemplate] = `{"retryStrategy":{"resourceEscalation":{"cpuOnDeadlineExceeded":true}}}`
+	assert.Equal(t, []apiv1.ResourceName{apiv1.ResourceCPU}, inferExhaustedResources(pod))
+
+	pod.Status.Reason = ""
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+		Name:  common.MainContainerName,
+		State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{Reason: "OOMKilled"}},
+	}}
+	assert.Equal(t, []apiv1.ResourceName{apiv1.ResourceMemory}, inferExhaustedResources(pod))
+}
+
+var nodeExitCode = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: node-exit-code
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: first
+        template: whalesay
+    - - name: on-success
+        template: whalesay
+        when: "{{steps.first.exitCode}} == 0"
+      - name: on-failure
+        template: whalesay
+        when: "{{steps.first.exitCode}} != 0"
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+      resources:
+        requests:
+          cpu: 500m
+`
+
+// TestNodeExitCode verifies the exit code, host node, resources and scheduling latency of a pod are
+// recorded in its node, and the exit code is available to the following steps
+func TestNodeExitCode(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	wf, err := wfcset.Create(unmarshalWF(nodeExitCode))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	pod, err := podcs.Get(woc.wf.NodeID("node-exit-code[0].first"), metav1.GetOptions{})
+	assert.NoError(t, err)
+	created := time.Now().Add(-time.Minute).Truncate(time.Second)
+	pod.CreationTimestamp = metav1.Time{Time: created}
+	pod.Spec.NodeName = "worker-1"
+	pod.Status.Phase = apiv1.PodSucceeded
+	pod.Status.Conditions = []apiv1.PodCondition{{
+		Type:               apiv1.PodScheduled,
+		Status:             apiv1.ConditionTrue,
+		LastTransitionTime: metav1.Time{Time: created.Add(5 * time.Second)},
+	}}
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+		Name:  common.MainContainerName,
+		State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 0}},
+	}}
+	_, err = podcs.Update(pod)
+	assert.NoError(t, err)
+
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	first := woc.getNodeByName("node-exit-code[0].first")
+	assert.Equal(t, wfv1.NodeSucceeded, first.Phase)
+	if assert.NotNil(t, first.ExitCode) {
+		assert.Equal(t, int32(0), *first.ExitCode)
+	}
+	assert.Equal(t, "worker-1", first.HostNodeName)
+	if assert.NotNil(t, first.SchedulingLatency) {
+		assert.Equal(t, 5*time.Second, first.SchedulingLatency.Duration)
+	}
+	if assert.NotNil(t, first.Resources) {
+		cpu := first.Resources.Requests[apiv1.ResourceCPU]
+		assert.Equal(t, "500m", cpu.String())
+	}
+	assert.Equal(t, wfv1.NodePending, woc.getNodeByName("node-exit-code[1].on-success").Phase)
+	assert.Equal(t, wfv1.NodeSkipped, woc.getNodeByName("node-exit-code[1].on-failure").Phase)
+}
+
+// TestNodeExitCodeUnavailable verifies a when expression referencing the exit code of a pod whose
+// main container did not terminate fails with an explicit error
+func TestNodeExitCodeUnavailable(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	manifest := strings.Replace(nodeExitCode, "        template: whalesay\n    - - name: on-success", "        template: whalesay\n        continueOn:\n          failed: true\n    - - name: on-success", 1)
+	wf, err := wfcset.Create(unmarshalWF(manifest))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	pod, err := podcs.Get(woc.wf.NodeID("node-exit-code[0].first"), metav1.GetOptions{})
+	assert.NoError(t, err)
+	pod.Status.Phase = apiv1.PodFailed
+	pod.Status.Message = "the pod was never run"
+	_, err = podcs.Update(pod)
+	assert.NoError(t, err)
+
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	first := woc.getNodeByName("node-exit-code[0].first")
+	assert.Equal(t, wfv1.NodeFailed, first.Phase)
+	assert.Nil(t, first.ExitCode)
+	onSuccess := woc.getNodeByName("node-exit-code[1].on-success")
+	assert.Equal(t, wfv1.NodeError, onSuccess.Phase)
+	assert.Equal(t, "Invalid 'when' expression '{{steps.first.exitCode}} == 0': exit code of 'steps.first' unavailable, its main container did not terminate", onSuccess.Message)
+	assert.Equal(t, wfv1.NodeFailed, woc.wf.Status.Phase)
+}
+
 var workflowParallelismLimit = `
 apiVersion: argoproj.io/v1alpha1
 kind: Workflow
@@ -809,6 +1178,220 @@ func TestSuspendResumeAfterTemplateNoWait(t *testing.T) {
 	assert.Equal(t, 0, len(pods.Items))
 }
 
+var suspendWithOutputs = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: suspend-with-outputs
+spec:
+  entrypoint: suspend
+  templates:
+  - name: suspend
+    steps:
+    - - name: approve
+        template: approve
+      - name: soak
+        template: soak
+    - - name: release
+        template: whalesay
+        arguments:
+          parameters:
+          - name: message
+            value: "{{steps.approve.outputs.parameters.decision}} {{steps.soak.outputs.parameters.result}}"
+
+  - name: approve
+    suspend: {}
+    outputs:
+      parameters:
+      - name: decision
+
+  - name: soak
+    suspend:
+      duration: 1
+    outputs:
+      parameters:
+      - name: result
+        default: healthy
+
+  - name: whalesay
+    inputs:
+      parameters:
+      - name: message
+    container:
+      image: docker/whalesay
+      command: [cowsay]
+      args: ["{{inputs.parameters.message}}"]
+`
+
+// TestSuspendResumeNode verifies suspend nodes are resumed individually, with the output parameters
+// supplied at resume time, or automatically after their duration with the default values
+func TestSuspendResumeNode(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(suspendWithOutputs))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	approve := woc.getNodeByName("suspend-with-outputs[0].approve")
+	assert.Equal(t, wfv1.NodeRunning, approve.Phase)
+	if assert.NotNil(t, approve.Outputs) && assert.Len(t, approve.Outputs.Parameters, 1) {
+		assert.Nil(t, approve.Outputs.Parameters[0].Value)
+	}
+
+	// a value must be supplied for output parameters without default
+	err = util.ResumeWorkflowNodes(wfcset, wf.ObjectMeta.Name, util.ResumeOpts{NodeName: "approve"})
+	assert.Error(t, err)
+	err = util.ResumeWorkflowNodes(wfcset, wf.ObjectMeta.Name, util.ResumeOpts{NodeName: "approve", Parameters: []string{"decision=yes", "other=no"}})
+	assert.Error(t, err)
+	err = util.ResumeWorkflowNodes(wfcset, wf.ObjectMeta.Name, util.ResumeOpts{NodeName: "deploy"})
+	assert.Error(t, err)
+	err = util.ResumeWorkflowNodes(wfcset, wf.ObjectMeta.Name, util.ResumeOpts{NodeName: "approve", Parameters: []string{"decision=yes"}})
+	assert.NoError(t, err)
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	assert.Equal(t, wfv1.NodeSucceeded, woc.getNodeByName("suspend-with-outputs[0].approve").Phase)
+	assert.Equal(t, wfv1.NodeRunning, woc.getNodeByName("suspend-with-outputs[0].soak").Phase)
+
+	time.Sleep(time.Second)
+	woc.operate()
+	soak := woc.getNodeByName("suspend-with-outputs[0].soak")
+	assert.Equal(t, wfv1.NodeSucceeded, soak.Phase)
+	pods, err := controller.kubeclientset.CoreV1().Pods("").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, pods.Items, 1) {
+		for _, ctr := range pods.Items[0].Spec.Containers {
+			if ctr.Name == common.MainContainerName {
+				assert.Equal(t, []string{"yes healthy"}, ctr.Args)
+			}
+		}
+	}
+}
+
+var approvalGates = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: approval-gates
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: release
+        template: release
+      - name: hotfix
+        template: hotfix
+      - name: expire
+        template: expire
+
+  - name: release
+    approval:
+      users: [alice, bob, carol]
+      required: 2
+
+  - name: hotfix
+    approval: {}
+
+  - name: expire
+    approval:
+      timeout: 1
+      defaultDecision: Approve
+`
+
+// TestApproval verifies approval nodes are completed from the recorded decisions of the allowed
+// approvers, or from their default decision once their timeout expired
+func TestApproval(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(approvalGates))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	release := woc.getNodeByName("approval-gates[0].release")
+	assert.Equal(t, wfv1.NodeRunning, release.Phase)
+	assert.Equal(t, "awaiting approval (0/2)", release.Message)
+	if assert.NotNil(t, release.Approval) {
+		assert.Equal(t, []string{"alice", "bob", "carol"}, release.Approval.Users)
+	}
+
+	// approval nodes are not resumed and the node must be specified if several nodes await approval
+	err = util.ResumeWorkflowNodes(wfcset, wf.ObjectMeta.Name, util.ResumeOpts{NodeName: "release"})
+	assert.Error(t, err)
+	approve := util.ApprovalOpts{Approver: "alice", Decision: wfv1.ApprovalDecisionApprove}
+	err = util.DecideWorkflowApproval(wfcset, wf.ObjectMeta.Name, approve)
+	assert.Error(t, err)
+
+	approve.NodeName = "release"
+	err = util.DecideWorkflowApproval(wfcset, wf.ObjectMeta.Name, approve)
+	assert.NoError(t, err)
+	// approvers decide only once
+	err = util.DecideWorkflowApproval(wfcset, wf.ObjectMeta.Name, approve)
+	assert.Error(t, err)
+	err = util.DecideWorkflowApproval(wfcset, wf.ObjectMeta.Name, util.ApprovalOpts{NodeName: "release", Approver: "mallory", Decision: wfv1.ApprovalDecisionApprove})
+	assert.Error(t, err)
+	err = util.DecideWorkflowApproval(wfcset, wf.ObjectMeta.Name, util.ApprovalOpts{NodeName: "release", Approver: "carol", Decision: wfv1.ApprovalDecisionApprove, Comment: "lgtm"})
+	assert.NoError(t, err)
+	err = util.DecideWorkflowApproval(wfcset, wf.ObjectMeta.Name, util.ApprovalOpts{NodeName: "hotfix", Approver: "bob", Decision: wfv1.ApprovalDecisionReject, Comment: "not now"})
+	assert.NoError(t, err)
+
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	time.Sleep(time.Second)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	release = woc.getNodeByName("approval-gates[0].release")
+	assert.Equal(t, wfv1.NodeSucceeded, release.Phase)
+	assert.Equal(t, "approved by alice, carol", release.Message)
+	if assert.Len(t, release.Approval.Decisions, 2) {
+		record := release.Approval.Decisions[1]
+		assert.Equal(t, "carol", record.Approver)
+		assert.Equal(t, "lgtm", record.Comment)
+		assert.False(t, record.Time.IsZero())
+	}
+	hotfix := woc.getNodeByName("approval-gates[0].hotfix")
+	assert.Equal(t, wfv1.NodeFailed, hotfix.Phase)
+	assert.Equal(t, "rejected by bob: not now", hotfix.Message)
+	expire := woc.getNodeByName("approval-gates[0].expire")
+	assert.Equal(t, wfv1.NodeSucceeded, expire.Phase)
+	assert.Equal(t, "approved by default after timeout", expire.Message)
+	assert.Equal(t, wfv1.NodeFailed, woc.wf.Status.Phase)
+}
+
+// TestApprovalForgedDecisions verifies the decisions recorded directly in the status are checked
+// against the policy of the template, and that an approver is counted once
+func TestApprovalForgedDecisions(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(approvalGates))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	release := woc.getNodeByName("approval-gates[0].release")
+	release.Approval.Users = append(release.Approval.Users, "mallory")
+	release.Approval.Decisions = []wfv1.ApprovalRecord{
+		{Approver: "alice", Decision: wfv1.ApprovalDecisionApprove},
+		{Approver: "alice", Decision: wfv1.ApprovalDecisionApprove},
+		{Approver: "mallory", Decision: wfv1.ApprovalDecisionApprove},
+		{Approver: "eve", Decision: wfv1.ApprovalDecisionReject},
+	}
+	woc.wf.Status.Nodes[release.ID] = *release
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	release = woc.getNodeByName("approval-gates[0].release")
+	assert.Equal(t, wfv1.NodeRunning, release.Phase)
+	assert.Equal(t, "awaiting approval (1/2)", release.Message)
+
+	release.Approval.Decisions = append(release.Approval.Decisions, wfv1.ApprovalRecord{Approver: "bob", Decision: wfv1.ApprovalDecisionApprove})
+	woc.wf.Status.Nodes[release.ID] = *release
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	release = woc.getNodeByName("approval-gates[0].release")
+	assert.Equal(t, wfv1.NodeSucceeded, release.Phase)
+	assert.Equal(t, "approved by alice, bob", release.Message)
+}
+
 var volumeWithParam = `
 apiVersion: argoproj.io/v1alpha1
 kind: Workflow
@@ -1665,3 +2248,437 @@ func TestWithParamAsJsonList(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 4, len(pods.Items))
 }
+
+// TestWorkflowDefaults verifies the namespace workflow defaults are merged into the workflow spec
+func TestWorkflowDefaults(t *testing.T) {
+	controller := newController()
+	ttl := int32(60)
+	controller.Config.Namespaces = map[string]config.NamespaceConfig{
+		"team-a": {
+			WorkflowDefaults: &config.WorkflowDefaults{
+				ServiceAccountName:      "team-a",
+				NodeSelector:            map[string]string{"pool": "batch", "zone": "a"},
+				Tolerations:             []apiv1.Toleration{{Key: "batch", Operator: apiv1.TolerationOpExists}},
+				PodGC:                   &wfv1.PodGC{Strategy: wfv1.PodGCOnWorkflowSuccess},
+				TTLSecondsAfterFinished: &ttl,
+			},
+		},
+	}
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("team-a")
+	wf := unmarshalWF(helloWorldWf)
+	wf.ObjectMeta.Namespace = "team-a"
+	wf.Spec.NodeSelector = map[string]string{"zone": "b"}
+	wf, err := wfcset.Create(wf)
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", wf.Spec.ServiceAccountName)
+	assert.Equal(t, map[string]string{"pool": "batch", "zone": "b"}, wf.Spec.NodeSelector)
+	assert.Len(t, wf.Spec.Tolerations, 1)
+	assert.Equal(t, wfv1.PodGCOnWorkflowSuccess, wf.Spec.PodGC.Strategy)
+	assert.Equal(t, int32(60), *wf.Spec.TTLSecondsAfterFinished)
+	assert.Nil(t, wf.Spec.ActiveDeadlineSeconds)
+
+	// workflows of other namespaces are left untouched
+	wf = unmarshalWF(helloWorldWf)
+	wf.ObjectMeta.Namespace = "team-b"
+	wf, err = controller.wfclientset.ArgoprojV1alpha1().Workflows("team-b").Create(wf)
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	assert.Equal(t, "", woc.wf.Spec.ServiceAccountName)
+	assert.Nil(t, woc.wf.Spec.PodGC)
+}
+
+// TestNamespaceMaxActivePods verifies pods are not created beyond the namespace max active pods
+func TestNamespaceMaxActivePods(t *testing.T) {
+	controller := newController()
+	_, err := controller.kubeclientset.CoreV1().Pods("team-a").Create(&apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "team-a"},
+		Status:     apiv1.PodStatus{Phase: apiv1.PodRunning},
+	})
+	assert.NoError(t, err)
+	controller.podInformer = informers.NewSharedInformerFactory(controller.kubeclientset, 0).Core().V1().Pods().Informer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go controller.podInformer.Run(ctx.Done())
+	assert.True(t, cache.WaitForCacheSync(ctx.Done(), controller.podInformer.HasSynced))
+	controller.Config.Namespaces = map[string]config.NamespaceConfig{"team-a": {MaxActivePods: 1}}
+
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("team-a")
+	wf := unmarshalWF(helloWorldWf)
+	wf.ObjectMeta.Namespace = "team-a"
+	wf, err = wfcset.Create(wf)
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	pods, err := controller.kubeclientset.CoreV1().Pods("team-a").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods.Items, 1)
+	assert.Equal(t, wfv1.NodeRunning, woc.wf.Status.Phase)
+
+	controller.Config.Namespaces = map[string]config.NamespaceConfig{"team-a": {MaxActivePods: 2}}
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	pods, err = controller.kubeclientset.CoreV1().Pods("team-a").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods.Items, 2)
+
+	// a workflow waiting for the quota is requeued once a pod of the namespace completes
+	wf = unmarshalWF(helloWorldWf)
+	wf.ObjectMeta.Name = "hello-world-2"
+	wf.ObjectMeta.Namespace = "team-a"
+	wf, err = wfcset.Create(wf)
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	assert.Len(t, woc.wf.Status.Nodes, 0)
+	for controller.wfQueue.Len() > 0 {
+		key, _ := controller.wfQueue.Get()
+		controller.wfQueue.Done(key)
+	}
+	podcs := controller.kubeclientset.CoreV1().Pods("team-a")
+	pod, err := podcs.Get("running", metav1.GetOptions{})
+	assert.NoError(t, err)
+	pod.Status.Phase = apiv1.PodSucceeded
+	_, err = podcs.Update(pod)
+	assert.NoError(t, err)
+	err = wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		obj, exists, err := controller.podInformer.GetIndexer().GetByKey("team-a/running")
+		return exists && obj.(*apiv1.Pod).Status.Phase == apiv1.PodSucceeded, err
+	})
+	assert.NoError(t, err)
+	controller.podQueue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	controller.podQueue.Add("team-a/running")
+	controller.processNextPodItem()
+	var keys []interface{}
+	for controller.wfQueue.Len() > 0 {
+		key, _ := controller.wfQueue.Get()
+		controller.wfQueue.Done(key)
+		keys = append(keys, key)
+	}
+	assert.Contains(t, keys, "team-a/hello-world-2")
+}
+
+var stopWithExitHandler = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: stop-with-exit-handler
+spec:
+  entrypoint: steps
+  onExit: exit-handler
+  templates:
+  - name: steps
+    steps:
+    - - name: approve
+        template: approve
+    - - name: whalesay
+        template: whalesay
+  - name: approve
+    suspend: {}
+  - name: exit-handler
+    steps:
+    - - name: cleanup
+        template: whalesay
+        when: "{{workflow.status}} == Succeeded"
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+// TestStopWorkflow verifies a stopped workflow does not schedule new nodes but runs its exit handler
+func TestStopWorkflow(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(stopWithExitHandler))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodeRunning, woc.wf.Status.Phase)
+
+	err = util.StopWorkflow(wfcset, wf.ObjectMeta.Name, nil)
+	assert.NoError(t, err)
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, wfv1.ShutdownStrategyStop, wf.Spec.Shutdown)
+	assert.True(t, util.IsWorkflowStopped(wf))
+	assert.False(t, util.IsWorkflowTerminated(wf))
+
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	approve := woc.getNodeByName("stop-with-exit-handler[0].approve")
+	assert.Equal(t, wfv1.NodeFailed, approve.Phase)
+	assert.Equal(t, "Stopped with strategy 'Stop'", approve.Message)
+	assert.Nil(t, woc.getNodeByName("stop-with-exit-handler[1].whalesay"))
+	onExit := woc.getNodeByName("stop-with-exit-handler.onExit")
+	if assert.NotNil(t, onExit) {
+		assert.Equal(t, wfv1.NodeSucceeded, onExit.Phase)
+	}
+	assert.Equal(t, wfv1.NodeFailed, woc.wf.Status.Phase)
+	assert.Equal(t, "Stopped with strategy 'Stop'", woc.wf.Status.Message)
+	pods, err := controller.kubeclientset.CoreV1().Pods("").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods.Items, 0)
+}
+
+// TestStopWorkflowBeforeStart verifies new nodes of a stopped workflow are failed with the shutdown message
+func TestStopWorkflowBeforeStart(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf := unmarshalWF(helloWorldWf)
+	wf.Spec.Shutdown = wfv1.ShutdownStrategyStop
+	wf, err := wfcset.Create(wf)
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodeFailed, woc.wf.Status.Phase)
+	node := woc.getNodeByName(wf.ObjectMeta.Name)
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeFailed, node.Phase)
+		assert.Equal(t, "Stopped with strategy 'Stop'", node.Message)
+	}
+	pods, err := controller.kubeclientset.CoreV1().Pods("").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods.Items, 0)
+}
+
+// TestStoppedOnExitNodes verifies only exit handler nodes, their retries and their children are
+// scheduled once a workflow is stopped
+func TestStoppedOnExitNodes(t *testing.T) {
+	controller := newController()
+	wf := unmarshalWF(helloWorldWf)
+	wf.Spec.Shutdown = wfv1.ShutdownStrategyStop
+	woc := newWorkflowOperationCtx(wf, controller)
+	for nodeName, hook := range map[string]bool{
+		"wf.onExit":                   true,
+		"wf[0].step.onExit":           true,
+		"wf[0].step.onExit(1)":        true,
+		"wf.onExit[0].cleanup":        false,
+		"wf.task.onExit.cleanup":      false,
+		"wf[0].onExitCleanup":         false,
+		"wf.onExitCleanup(0)":         false,
+		"wf[0].step.onExitCleanup[0]": false,
+	} {
+		assert.Equal(t, hook, isOnExitHookNode(nodeName), nodeName)
+	}
+	for nodeName, stopped := range map[string]bool{
+		"wf.onExit":                   false,
+		"wf[0].step.onExit(1)":        false,
+		"wf.onExit[0].cleanup":        false,
+		"wf.task.onExit.cleanup":      false,
+		"wf[0].onExitCleanup":         true,
+		"wf.onExitCleanup(0)":         true,
+		"wf[0].step.onExitCleanup[0]": true,
+		"wf[0].step":                  true,
+	} {
+		assert.Equal(t, stopped, woc.isStopped(nodeName), nodeName)
+	}
+}
+
+// TestTerminateWorkflowMessage verifies a terminated workflow and its nodes keep the "terminated"
+// message
+func TestTerminateWorkflowMessage(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(helloWorldWf))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodeRunning, woc.wf.Status.Phase)
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	pod, err := podcs.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	pod.Status.Phase = apiv1.PodPending
+	_, err = podcs.Update(pod)
+	assert.NoError(t, err)
+
+	err = util.TerminateWorkflow(wfcset, wf.ObjectMeta.Name)
+	assert.NoError(t, err)
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, wfv1.ShutdownStrategyTerminate, wf.Spec.Shutdown)
+	wf.Status = woc.wf.Status
+
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	node := woc.getNodeByName(wf.ObjectMeta.Name)
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeFailed, node.Phase)
+		assert.Equal(t, "terminated", node.Message)
+	}
+	assert.Equal(t, wfv1.NodeFailed, woc.wf.Status.Phase)
+	assert.Equal(t, "terminated", woc.wf.Status.Message)
+}
+
+var stepsTimeout = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: steps-timeout
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: group
+        template: group
+        continueOn:
+          failed: true
+    - - name: after
+        template: whalesay
+  - name: group
+    timeout: 10
+    steps:
+    - - name: slow
+        template: whalesay
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+// startedBefore moves the start of a node to the given duration ago
+func startedBefore(wf *wfv1.Workflow, nodeName string, d time.Duration) {
+	node := wf.Status.Nodes[wf.NodeID(nodeName)]
+	node.StartedAt = metav1.Time{Time: time.Now().UTC().Add(-d)}
+	wf.Status.Nodes[node.ID] = node
+}
+
+// TestStepsTimeout verifies a timed out steps template fails with the nodes it started, and the
+// workflow continues according to continueOn
+func TestStepsTimeout(t *testing.T) {
+	controller := newController()
+	controller.restConfig = &rest.Config{}
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(stepsTimeout))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	makePodsRunning(t, controller.kubeclientset, "")
+
+	// the group is left to run until its timeout
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	startedBefore(wf, "steps-timeout[0].group", 5*time.Second)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	node := woc.getNodeByName("steps-timeout[0].group")
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeRunning, node.Phase)
+	}
+
+	startedBefore(woc.wf, "steps-timeout[0].group", 20*time.Second)
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	for _, nodeName := range []string{"steps-timeout[0].group", "steps-timeout[0].group[0]", "steps-timeout[0].group[0].slow"} {
+		node := woc.getNodeByName(nodeName)
+		if assert.NotNil(t, node, nodeName) {
+			assert.Equal(t, wfv1.NodeFailed, node.Phase, nodeName)
+			assert.Equal(t, "timeout of 10s exceeded", node.Message, nodeName)
+			assert.True(t, node.TimedOut, nodeName)
+		}
+	}
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	pod, err := podcs.Get(woc.wf.NodeID("steps-timeout[0].group[0].slow"), metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		var execCtl common.ExecutionControl
+		err = json.Unmarshal([]byte(pod.Annotations[common.AnnotationKeyExecutionControl]), &execCtl)
+		assert.NoError(t, err)
+		if assert.NotNil(t, execCtl.Deadline) {
+			assert.True(t, execCtl.Deadline.IsZero())
+		}
+	}
+	_, err = podcs.Get(woc.wf.NodeID("steps-timeout[1].after"), metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	// the node of the running pod is not updated back to running
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	node = woc.getNodeByName("steps-timeout[0].group[0].slow")
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeFailed, node.Phase)
+	}
+
+	// nor once the pod completed
+	for _, complete := range []func(){
+		func() { makePodSucceeded(t, controller.kubeclientset, pod.Name, nil) },
+		func() { makePodFailed(t, controller.kubeclientset, pod.Name, 143) },
+	} {
+		complete()
+		woc = newWorkflowOperationCtx(woc.wf, controller)
+		woc.operate()
+		node = woc.getNodeByName("steps-timeout[0].group[0].slow")
+		if assert.NotNil(t, node) {
+			assert.Equal(t, wfv1.NodeFailed, node.Phase)
+			assert.Equal(t, "timeout of 10s exceeded", node.Message)
+			assert.True(t, node.TimedOut)
+		}
+	}
+}
+
+// TestAssessNodeStatusTimeoutMessage verifies a pod failed with a message which reads like a
+// timeout is still assessed, unless its node was failed by the timeout of its template
+func TestAssessNodeStatusTimeoutMessage(t *testing.T) {
+	pod := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pod",
+			Annotations: map[string]string{common.AnnotationKeyOutputs: `{"result": "partial"}`},
+		},
+		Status: apiv1.PodStatus{Phase: apiv1.PodFailed, Message: "timeout of 30s exceeded"},
+	}
+	node := &wfv1.NodeStatus{Name: "pod", Phase: wfv1.NodeFailed, Message: "timeout of 30s exceeded"}
+	updated := assessNodeStatus(pod, node)
+	if assert.NotNil(t, updated) && assert.NotNil(t, updated.Outputs) {
+		assert.Equal(t, "partial", *updated.Outputs.Result)
+	}
+
+	node = &wfv1.NodeStatus{Name: "pod", Phase: wfv1.NodeFailed, Message: "timeout of 30s exceeded", TimedOut: true}
+	assert.Nil(t, assessNodeStatus(pod, node))
+}
+
+var retryTimeout = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: retry-timeout
+spec:
+  entrypoint: whalesay
+  templates:
+  - name: whalesay
+    timeout: 10
+    retryStrategy:
+      limit: 1
+    container:
+      image: docker/whalesay:latest
+`
+
+// TestRetryTimeout verifies a timed out attempt is retried
+func TestRetryTimeout(t *testing.T) {
+	controller := newController()
+	controller.restConfig = &rest.Config{}
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(retryTimeout))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	makePodsRunning(t, controller.kubeclientset, "")
+
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	startedBefore(wf, "retry-timeout(0)", 20*time.Second)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	node := woc.getNodeByName("retry-timeout(0)")
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeFailed, node.Phase)
+		assert.Equal(t, "timeout of 10s exceeded", node.Message)
+		assert.True(t, node.TimedOut)
+	}
+	node = woc.getNodeByName("retry-timeout")
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeRunning, node.Phase)
+		assert.Len(t, node.Children, 2)
+	}
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"fmt"
+
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+)
+
+// raceWinner returns the child of a racing step or task group which succeeded first, if any
+func (woc *wfOperationCtx) raceWinner(groupNode *wfv1.NodeStatus) *wfv1.NodeStatus {
+	var winner *wfv1.NodeStatus
+	for _, childID := range groupNode.Children {
+		childNode, ok := woc.wf.Status.Nodes[childID]
+		if !ok || childNode.Phase != wfv1.NodeSucceeded {
+			continue
+		}
+		if winner == nil || childNode.FinishedAt.Before(&winner.FinishedAt) {
+			winner = childNode.DeepCopy()
+		}
+	}
+	return winner
+}
+
+// raceLostMessage is the message of the children of a racing group which lost the race
+func raceLostMessage(winner *wfv1.NodeStatus) string {
+	return fmt.Sprintf("lost the race to %s", winner.DisplayName)
+}
+
+// endRace terminates the children of a racing step or task group other than its winner, and marks
+// them skipped. The children which already completed are left as is.
+func (woc *wfOperationCtx) endRace(groupNode *wfv1.NodeStatus, winner *wfv1.NodeStatus) {
+	for _, childID := range groupNode.Children {
+		if childID == winner.ID {
+			continue
+		}
+		woc.killNodeTree(childID, wfv1.NodeSkipped, raceLostMessage(winner))
+	}
+}
+
+// completeRace marks a racing step or task group succeeded with the outputs of its winner
+func (woc *wfOperationCtx) completeRace(groupNodeName string, winner *wfv1.NodeStatus) *wfv1.NodeStatus {
+	groupNode := woc.getNodeByName(groupNodeName)
+	groupNode.Outputs = winner.Outputs.DeepCopy()
+	woc.wf.Status.Nodes[groupNode.ID] = *groupNode
+	woc.log.Infof("Node %s won the race of %s", winner.Name, groupNodeName)
+	return woc.markNodePhase(groupNodeName, wfv1.NodeSucceeded, fmt.Sprintf("%s won the race", winner.DisplayName))
+}
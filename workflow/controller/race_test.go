@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+	"github.com/cyrusbiotechnology/argo/workflow/common"
+)
+
+var stepsRace = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: steps-race
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: heuristic
+        template: solve
+        race: true
+      - name: exact
+        template: solve
+        race: true
+    - - name: report
+        template: report
+        arguments:
+          parameters:
+          - name: answer
+            value: "{{steps.heuristic.outputs.parameters.answer}}"
+  - name: solve
+    container:
+      image: alpine:latest
+    outputs:
+      parameters:
+      - name: answer
+        valueFrom:
+          path: /tmp/answer
+  - name: report
+    inputs:
+      parameters:
+      - name: answer
+    container:
+      image: alpine:latest
+      args: ["{{inputs.parameters.answer}}"]
+`
+
+func TestStepsRace(t *testing.T) {
+	controller := newController()
+	controller.restConfig = &rest.Config{}
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(stepsRace))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	makePodsRunning(t, controller.kubeclientset, "")
+	answer := "42"
+	makePodSucceeded(t, controller.kubeclientset, woc.wf.NodeID("steps-race[0].exact"), &wfv1.Outputs{
+		Parameters: []wfv1.Parameter{{Name: "answer", Value: &answer}},
+	})
+
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	node := woc.getNodeByName("steps-race[0]")
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeSucceeded, node.Phase)
+		assert.Equal(t, "exact won the race", node.Message)
+		if assert.NotNil(t, node.Outputs) && assert.Len(t, node.Outputs.Parameters, 1) {
+			assert.Equal(t, "42", *node.Outputs.Parameters[0].Value)
+		}
+	}
+	node = woc.getNodeByName("steps-race[0].heuristic")
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeSkipped, node.Phase)
+		assert.Equal(t, "lost the race to exact", node.Message)
+	}
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	pod, err := podcs.Get(woc.wf.NodeID("steps-race[0].heuristic"), metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		var execCtl common.ExecutionControl
+		err = json.Unmarshal([]byte(pod.Annotations[common.AnnotationKeyExecutionControl]), &execCtl)
+		assert.NoError(t, err)
+		if assert.NotNil(t, execCtl.Deadline) {
+			assert.True(t, execCtl.Deadline.IsZero())
+		}
+	}
+	// the outputs of every step of the race are those of the winner
+	pod, err = podcs.Get(woc.wf.NodeID("steps-race[1].report"), metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"42"}, pod.Spec.Containers[1].Args)
+	}
+
+	// the node of the terminated pod stays skipped
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("steps-race[0].heuristic"), 143)
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	node = woc.getNodeByName("steps-race[0].heuristic")
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeSkipped, node.Phase)
+	}
+}
+
+var stepsRaceFailed = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: steps-race-failed
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: heuristic
+        template: solve
+        race: true
+      - name: exact
+        template: solve
+        race: true
+  - name: solve
+    container:
+      image: alpine:latest
+`
+
+func TestStepsRaceFailed(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(stepsRaceFailed))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	makePodsRunning(t, controller.kubeclientset, "")
+
+	// the race goes on after a failure
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("steps-race-failed[0].heuristic"), 1)
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	node := woc.getNodeByName("steps-race-failed[0]")
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeRunning, node.Phase)
+	}
+
+	// the race fails when nobody wins it
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("steps-race-failed[0].exact"), 1)
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	node = woc.getNodeByName("steps-race-failed[0]")
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeFailed, node.Phase)
+	}
+	assert.Equal(t, wfv1.NodeFailed, woc.wf.Status.Phase)
+}
+
+var dagRace = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: dag-race
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    dag:
+      tasks:
+      - name: fetch
+        template: fetch
+        arguments:
+          parameters:
+          - name: region
+            value: "{{item}}"
+        withItems: [us-east1, europe-west1, asia-east1]
+        race: true
+      - name: report
+        dependencies: [fetch]
+        template: report
+        arguments:
+          parameters:
+          - name: region
+            value: "{{tasks.fetch.outputs.parameters.region}}"
+  - name: fetch
+    inputs:
+      parameters:
+      - name: region
+    container:
+      image: alpine:latest
+      args: ["{{inputs.parameters.region}}"]
+    outputs:
+      parameters:
+      - name: region
+        valueFrom:
+          path: /tmp/region
+  - name: report
+    inputs:
+      parameters:
+      - name: region
+    container:
+      image: alpine:latest
+      args: ["{{inputs.parameters.region}}"]
+`
+
+func TestDagRace(t *testing.T) {
+	controller := newController()
+	controller.restConfig = &rest.Config{}
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(dagRace))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	makePodsRunning(t, controller.kubeclientset, "")
+	region := "europe-west1"
+	makePodSucceeded(t, controller.kubeclientset, woc.wf.NodeID("dag-race.fetch(1:europe-west1)"), &wfv1.Outputs{
+		Parameters: []wfv1.Parameter{{Name: "region", Value: &region}},
+	})
+
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	node := woc.getNodeByName("dag-race.fetch")
+	if assert.NotNil(t, node) {
+		assert.Equal(t, wfv1.NodeSucceeded, node.Phase)
+		assert.Equal(t, "fetch(1:europe-west1) won the race", node.Message)
+	}
+	for _, nodeName := range []string{"dag-race.fetch(0:us-east1)", "dag-race.fetch(2:asia-east1)"} {
+		node := woc.getNodeByName(nodeName)
+		if assert.NotNil(t, node, nodeName) {
+			assert.Equal(t, wfv1.NodeSkipped, node.Phase, nodeName)
+			assert.Equal(t, "lost the race to fetch(1:europe-west1)", node.Message, nodeName)
+		}
+	}
+	pod, err := controller.kubeclientset.CoreV1().Pods("").Get(woc.wf.NodeID("dag-race.report"), metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"europe-west1"}, pod.Spec.Containers[1].Args)
+	}
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/Knetic/govaluate"
@@ -90,6 +91,11 @@ func (woc *wfOperationCtx) executeSteps(nodeName string, tmplCtx *templateresolu
 
 		// Add all outputs of each step in the group to the scope
 		for _, step := range stepGroup {
+			if step.Race {
+				// the outputs of the steps of a race are those of the winner, kept by the step group
+				woc.processNodeOutputs(stepsCtx.scope, fmt.Sprintf("steps.%s", step.Name), sgNode)
+				continue
+			}
 			childNodeName := fmt.Sprintf("%s.%s", sgNodeName, step.Name)
 			childNode := woc.getNodeByName(childNodeName)
 			prefix := fmt.Sprintf("steps.%s", step.Name)
@@ -98,7 +104,7 @@ func (woc *wfOperationCtx) executeSteps(nodeName string, tmplCtx *templateresolu
 				// We add the aggregate outputs of our children to the scope as a JSON list
 				var childNodes []wfv1.NodeStatus
 				for _, node := range woc.wf.Status.Nodes {
-					if node.BoundaryID == stepsCtx.boundaryID && strings.HasPrefix(node.Name, childNodeName+"(") && node.Type != wfv1.NodeTypeSkipped {
+					if node.BoundaryID == stepsCtx.boundaryID && strings.HasPrefix(node.Name, childNodeName+"(") && node.Type != wfv1.NodeTypeSkipped && !isOnExitHookNode(node.Name) {
 						childNodes = append(childNodes, node)
 					}
 				}
@@ -108,7 +114,7 @@ func (woc *wfOperationCtx) executeSteps(nodeName string, tmplCtx *templateresolu
 					if err != nil {
 						return err
 					}
-					err = woc.processAggregateNodeOutputs(tmpl, stepsCtx.scope, prefix, childNodes)
+					err = woc.processAggregateNodeOutputs(tmpl, stepsCtx.scope, prefix, childNodes, step.BatchSize > 0)
 					if err != nil {
 						return err
 					}
@@ -178,8 +184,10 @@ func (woc *wfOperationCtx) executeStepGroup(stepGroup []wfv1.WorkflowStep, sgNod
 		return woc.markNodeError(sgNodeName, err)
 	}
 
+	loopSteps := stepGroup
+
 	// Next, expand the step's withItems (if any)
-	stepGroup, err = woc.expandStepGroup(stepGroup)
+	stepGroup, err = woc.expandStepGroup(sgNodeName, stepGroup, stepsCtx.scope)
 	if err != nil {
 		return woc.markNodeError(sgNodeName, err)
 	}
@@ -187,12 +195,28 @@ func (woc *wfOperationCtx) executeStepGroup(stepGroup []wfv1.WorkflowStep, sgNod
 	// Maps nodes to their steps
 	nodeSteps := make(map[string]wfv1.WorkflowStep)
 
+	// The first step of a race to succeed wins, and the others are terminated
+	race := len(loopSteps) > 0 && loopSteps[0].Race
+	var winner *wfv1.NodeStatus
+	if race {
+		winner = woc.raceWinner(node)
+		if winner != nil {
+			woc.endRace(node, winner)
+		}
+	}
+
 	// Kick off all parallel steps in the group
 	for _, step := range stepGroup {
 		childNodeName := fmt.Sprintf("%s.%s", sgNodeName, step.Name)
 
+		if winner != nil && woc.getNodeByName(childNodeName) == nil {
+			woc.initializeNode(childNodeName, wfv1.NodeTypeSkipped, &step, stepsCtx.boundaryID, wfv1.NodeSkipped, raceLostMessage(winner))
+			woc.addChildNode(sgNodeName, childNodeName)
+			continue
+		}
+
 		// Check the step's when clause to decide if it should execute
-		proceed, err := shouldExecute(step.When)
+		proceed, err := woc.evaluateWhen(step.When, stepsCtx.scope)
 		if err != nil {
 			woc.initializeNode(childNodeName, wfv1.NodeTypeSkipped, &step, stepsCtx.boundaryID, wfv1.NodeError, err.Error())
 			woc.addChildNode(sgNodeName, childNodeName)
@@ -224,30 +248,125 @@ func (woc *wfOperationCtx) executeStepGroup(stepGroup []wfv1.WorkflowStep, sgNod
 		if childNode != nil {
 			nodeSteps[childNodeName] = step
 			woc.addChildNode(sgNodeName, childNodeName)
+			if childNode.Completed() {
+				_, err = woc.executeOnExitHook(step.OnExit, childNode, stepsCtx.tmplCtx, stepsCtx.boundaryID)
+				if err == ErrDeadlineExceeded {
+					return node
+				}
+			}
 		}
 	}
 
 	node = woc.getNodeByName(sgNodeName)
-	// Return if not all children completed
+	if race && winner == nil && woc.raceWinner(node) != nil {
+		// a step won the race during this operation, the others are terminated in the next one
+		woc.requeue()
+		return node
+	}
+	// Return if not all children and their exit hooks completed
 	for _, childNodeID := range node.Children {
-		if !woc.wf.Status.Nodes[childNodeID].Completed() {
+		childNode := woc.wf.Status.Nodes[childNodeID]
+		if !childNode.Completed() || !woc.onExitHookCompleted(nodeSteps[childNode.Name].OnExit, &childNode) {
 			return node
 		}
 	}
-	// All children completed. Determine step group status as a whole
+	// All children completed. Judge the loops with a failure tolerance against it as a whole, the
+	// failures of their items within the tolerance do not fail the step group.
+	tolerated := make(map[string]bool)
+	var toleranceMessages []string
+	for _, step := range loopSteps {
+		if step.FailureTolerance == nil {
+			continue
+		}
+		itemNodeNamePrefix := fmt.Sprintf("%s.%s(", sgNodeName, step.Name)
+		var itemNodes []wfv1.NodeStatus
+		for _, childNodeID := range node.Children {
+			childNode := woc.wf.Status.Nodes[childNodeID]
+			if strings.HasPrefix(childNode.Name, itemNodeNamePrefix) {
+				itemNodes = append(itemNodes, childNode)
+			}
+		}
+		withinTolerance, toleranceMessage := assessFailureTolerance(step.FailureTolerance, itemNodes, step.ContinuesOn)
+		toleranceMessage = fmt.Sprintf("step '%s': %s", step.Name, toleranceMessage)
+		if !withinTolerance {
+			woc.log.Infof("Step group node %s deemed failed: %s", node, toleranceMessage)
+			return woc.markNodePhase(node.Name, wfv1.NodeFailed, toleranceMessage)
+		}
+		for _, itemNode := range itemNodes {
+			tolerated[itemNode.ID] = true
+		}
+		toleranceMessages = append(toleranceMessages, toleranceMessage)
+	}
+	// Determine step group status as a whole
 	for _, childNodeID := range node.Children {
 		childNode := woc.wf.Status.Nodes[childNodeID]
 		step := nodeSteps[childNode.Name]
-		if !childNode.Successful() && !step.ContinuesOn(childNode.Phase) {
+		if winner != nil {
+			if childNodeID != winner.ID {
+				// the steps which lost the race do not fail the step group
+				continue
+			}
+		} else if !childNode.Successful() && !tolerated[childNodeID] && !step.ContinuesOn(&childNode) {
 			failMessage := fmt.Sprintf("child '%s' failed", childNodeID)
 			woc.log.Infof("Step group node %s deemed failed: %s", node, failMessage)
 			return woc.markNodePhase(node.Name, wfv1.NodeFailed, failMessage)
 		}
+		hookNode := woc.getOnExitHookNode(step.OnExit, &childNode)
+		if hookNode != nil && !hookNode.Successful() && !step.ContinuesOn(hookNode) {
+			failMessage := fmt.Sprintf("exit hook of child '%s' failed", childNodeID)
+			woc.log.Infof("Step group node %s deemed failed: %s", node, failMessage)
+			return woc.markNodePhase(node.Name, wfv1.NodeFailed, failMessage)
+		}
+	}
+	if winner != nil {
+		return woc.completeRace(node.Name, winner)
 	}
 	woc.log.Infof("Step group node %v successful", node)
+	if len(toleranceMessages) > 0 {
+		return woc.markNodePhase(node.Name, wfv1.NodeSucceeded, strings.Join(toleranceMessages, "; "))
+	}
 	return woc.markNodePhase(node.Name, wfv1.NodeSucceeded)
 }
 
+// exitCodeReference matches the references to the exit code of a step or task in a when expression
+var exitCodeReference = regexp.MustCompile(`\b(?:steps|tasks)\.[\w-]+\.exitCode\b`)
+
+// evaluateWhen evaluates an already substituted when expression to decide whether or not a step or
+// task should execute. Typed expressions are evaluated against the typed values of the inputs of
+// the template, the outputs, statuses and exit codes in scope, and the global workflow variables,
+// as converted by common.WhenValue. The others are evaluated by shouldExecute. The exit code of a
+// pod is not in scope when its main container did not terminate, e.g. the pod was deleted, which
+// fails the evaluation of the expressions referencing it.
+func (woc *wfOperationCtx) evaluateWhen(when string, scope *wfScope) (bool, error) {
+	for _, ref := range exitCodeReference.FindAllString(when, -1) {
+		if _, ok := scope.scope[ref]; !ok {
+			return false, errors.Errorf(errors.CodeBadRequest, "Invalid 'when' expression '%s': exit code of '%s' unavailable, its main container did not terminate", when, strings.TrimSuffix(ref, ".exitCode"))
+		}
+	}
+	if !common.IsTypedWhen(when) {
+		return shouldExecute(when)
+	}
+	context := make(map[string]interface{})
+	for key, val := range woc.globalParams {
+		context[key] = common.WhenValue(val)
+	}
+	if scope.tmpl != nil {
+		for _, param := range scope.tmpl.Inputs.Parameters {
+			if param.Value != nil {
+				context["inputs.parameters."+param.Name] = common.WhenValue(*param.Value)
+			}
+		}
+	}
+	for key, val := range scope.scope {
+		if valStr, ok := val.(string); ok {
+			context[key] = common.WhenValue(valStr)
+			continue
+		}
+		context[key] = val
+	}
+	return common.EvaluateWhen(when, context)
+}
+
 // shouldExecute evaluates a already substituted when expression to decide whether or not a step should execute
 func shouldExecute(when string) (bool, error) {
 	if when == "" {
@@ -339,14 +458,27 @@ func (woc *wfOperationCtx) resolveReferences(stepGroup []wfv1.WorkflowStep, scop
 	return newStepGroup, nil
 }
 
-// expandStepGroup looks at each step in a collection of parallel steps, and expands all steps using withItems/withParam
-func (woc *wfOperationCtx) expandStepGroup(stepGroup []wfv1.WorkflowStep) ([]wfv1.WorkflowStep, error) {
+// expandStepGroup looks at each step in a collection of parallel steps, and expands all steps using withItems/withParam/withSequence/withMatrix/withArtifact
+func (woc *wfOperationCtx) expandStepGroup(sgNodeName string, stepGroup []wfv1.WorkflowStep, scope *wfScope) ([]wfv1.WorkflowStep, error) {
 	newStepGroup := make([]wfv1.WorkflowStep, 0)
 	for _, step := range stepGroup {
-		if len(step.WithItems) == 0 && step.WithParam == "" && step.WithSequence == nil {
+		if !step.IsLoop() {
 			newStepGroup = append(newStepGroup, step)
 			continue
 		}
+		if step.WithArtifact != nil {
+			items, err := woc.getLoopArtifactItems(fmt.Sprintf("%s.%s", sgNodeName, step.Name), step.WithArtifact, scope)
+			if err != nil {
+				return nil, err
+			}
+			if len(items) == 0 {
+				continue
+			}
+			// the items of the artifact are expanded as withItems, without the artifact reference
+			// which is not substituted in the expanded steps
+			step.WithItems = items
+			step.WithArtifact = nil
+		}
 		expandedStep, err := woc.expandStep(step)
 		if err != nil {
 			return nil, err
@@ -377,14 +509,28 @@ func (woc *wfOperationCtx) expandStep(step wfv1.WorkflowStep) ([]wfv1.WorkflowSt
 		if err != nil {
 			return nil, err
 		}
+	} else if len(step.WithMatrix) > 0 {
+		items, err = expandMatrix(step.WithMatrix)
+		if err != nil {
+			return nil, err
+		}
 	} else {
 		// this should have been prevented in expandStepGroup()
 		return nil, errors.InternalError("expandStep() was called with withItems and withParam empty")
 	}
 
+	batchSize := int(step.BatchSize)
+	if batchSize > 0 {
+		items = batchItems(items, batchSize)
+	}
 	for i, item := range items {
 		var newStep wfv1.WorkflowStep
-		newStepName, err := processItem(fstTmpl, step.Name, i, item, &newStep)
+		var newStepName string
+		if batchSize > 0 {
+			newStepName, err = processBatch(fstTmpl, step.Name, i, batchSize, item, &newStep)
+		} else {
+			newStepName, err = processItem(fstTmpl, step.Name, i, item, &newStep)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -1,12 +1,18 @@
 package controller
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
 	"github.com/cyrusbiotechnology/argo/test"
+	"github.com/cyrusbiotechnology/argo/workflow/common"
+	"github.com/cyrusbiotechnology/argo/workflow/config"
 )
 
 // TestStepsFailedRetries ensures a steps template will recognize exhausted retries
@@ -16,3 +22,448 @@ func TestStepsFailedRetries(t *testing.T) {
 	woc.operate()
 	assert.Equal(t, string(wfv1.NodeFailed), string(woc.wf.Status.Phase))
 }
+
+var stepExitHook = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: step-exit-hook
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: work
+        template: noop
+        onExit: release
+    - - name: after
+        template: noop
+  - name: noop
+    steps:
+    - - name: skipped
+        template: release
+        when: "1 == 2"
+  - name: release
+    container:
+      image: docker/whalesay:latest
+      args: ["release {{status}}"]
+`
+
+// TestStepExitHook verifies the exit hook of a step is given its status and the next step group
+// waits for the hook to complete
+func TestStepExitHook(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(stepExitHook))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	work := woc.getNodeByName("step-exit-hook[0].work")
+	assert.Equal(t, wfv1.NodeSucceeded, work.Phase)
+	hook := woc.getNodeByName("step-exit-hook[0].work.onExit")
+	if assert.NotNil(t, hook) {
+		assert.Equal(t, wfv1.NodePending, hook.Phase)
+		// the hook follows the outbound nodes of the step
+		if assert.Len(t, work.OutboundNodes, 1) {
+			assert.Contains(t, woc.wf.Status.Nodes[work.OutboundNodes[0]].Children, hook.ID)
+		}
+	}
+	assert.Nil(t, woc.getNodeByName("step-exit-hook[1].after"))
+
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	pods, err := podcs.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, pods.Items, 1) {
+		pod := pods.Items[0]
+		for _, ctr := range pod.Spec.Containers {
+			if ctr.Name == common.MainContainerName {
+				assert.Equal(t, []string{"release Succeeded"}, ctr.Args)
+			}
+		}
+		pod.Status.Phase = apiv1.PodSucceeded
+		_, err = podcs.Update(&pod)
+		assert.NoError(t, err)
+	}
+
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodeSucceeded, woc.getNodeByName("step-exit-hook[0].work.onExit").Phase)
+	assert.NotNil(t, woc.getNodeByName("step-exit-hook[1].after"))
+	assert.Equal(t, wfv1.NodeSucceeded, woc.wf.Status.Phase)
+}
+
+var stepContinueOnExitCode = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: step-continue-on
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: search
+        template: search
+        continueOn:
+          exitCodes: [3]
+    - - name: report
+        template: search
+  - name: search
+    container:
+      image: alpine:latest
+      command: [sh, -c, "exit 3"]
+`
+
+// TestStepContinueOnExitCode verifies a step failed with one of its continueOn exit codes does not
+// fail its step group, while another exit code does
+func TestStepContinueOnExitCode(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(stepContinueOnExitCode))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("step-continue-on[0].search"), 3)
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	// the step is still failed for visibility
+	assert.Equal(t, wfv1.NodeFailed, woc.getNodeByName("step-continue-on[0].search").Phase)
+	assert.Equal(t, wfv1.NodeSucceeded, woc.getNodeByName("step-continue-on[0]").Phase)
+	assert.NotNil(t, woc.getNodeByName("step-continue-on[1].report"))
+
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("step-continue-on[1].report"), 1)
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodeFailed, woc.getNodeByName("step-continue-on[1]").Phase)
+	assert.Equal(t, wfv1.NodeFailed, woc.wf.Status.Phase)
+}
+
+var stepFailureTolerance = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: step-failure-tolerance
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: process
+        template: process
+        withItems: [a, b, c, d]
+        failureTolerance: 25%
+  - name: process
+    container:
+      image: alpine:latest
+`
+
+// TestStepFailureTolerance verifies the failures of the items of a loop within its failure tolerance
+// do not fail the step group, and the counts are reported in its message
+func TestStepFailureTolerance(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	wf, err := wfcset.Create(unmarshalWF(stepFailureTolerance))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("step-failure-tolerance[0].process(0:a)"), 1)
+	for _, nodeName := range []string{"step-failure-tolerance[0].process(1:b)", "step-failure-tolerance[0].process(2:c)", "step-failure-tolerance[0].process(3:d)"} {
+		pod, err := podcs.Get(woc.wf.NodeID(nodeName), metav1.GetOptions{})
+		assert.NoError(t, err)
+		pod.Status.Phase = apiv1.PodSucceeded
+		_, err = podcs.Update(pod)
+		assert.NoError(t, err)
+	}
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodeFailed, woc.getNodeByName("step-failure-tolerance[0].process(0:a)").Phase)
+	stepGroup := woc.getNodeByName("step-failure-tolerance[0]")
+	assert.Equal(t, wfv1.NodeSucceeded, stepGroup.Phase)
+	assert.Equal(t, "step 'process': 1/4 items failed (failure tolerance: 25%)", stepGroup.Message)
+	assert.Equal(t, wfv1.NodeSucceeded, woc.wf.Status.Phase)
+}
+
+// TestStepTemplatedFailureTolerance verifies a templated failure tolerance resolved to a plain number
+// is a number of items
+func TestStepTemplatedFailureTolerance(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	manifest := strings.Replace(stepFailureTolerance, "failureTolerance: 25%", `failureTolerance: "{{inputs.parameters.tolerance}}"`, 1)
+	manifest = strings.Replace(manifest, "  entrypoint: main\n", "  entrypoint: main\n  arguments:\n    parameters:\n    - name: tolerance\n      value: 1\n", 1)
+	manifest = strings.Replace(manifest, "  - name: main\n", "  - name: main\n    inputs:\n      parameters:\n      - name: tolerance\n", 1)
+	wf, err := wfcset.Create(unmarshalWF(manifest))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("step-failure-tolerance[0].process(0:a)"), 1)
+	for _, nodeName := range []string{"step-failure-tolerance[0].process(1:b)", "step-failure-tolerance[0].process(2:c)", "step-failure-tolerance[0].process(3:d)"} {
+		pod, err := podcs.Get(woc.wf.NodeID(nodeName), metav1.GetOptions{})
+		assert.NoError(t, err)
+		pod.Status.Phase = apiv1.PodSucceeded
+		_, err = podcs.Update(pod)
+		assert.NoError(t, err)
+	}
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	stepGroup := woc.getNodeByName("step-failure-tolerance[0]")
+	assert.Equal(t, wfv1.NodeSucceeded, stepGroup.Phase)
+	assert.Equal(t, "step 'process': 1/4 items failed (failure tolerance: 1)", stepGroup.Message)
+	assert.Equal(t, wfv1.NodeSucceeded, woc.wf.Status.Phase)
+}
+
+var stepMatrix = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: step-matrix
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: align
+        template: align
+        arguments:
+          parameters:
+          - name: sample
+            value: "{{item.sample}}"
+          - name: reference
+            value: "{{item.reference}}"
+        withMatrix:
+        - name: sample
+          items: [a, b, c]
+        - name: reference
+          param: '["hg19", "hg38"]'
+  - name: align
+    inputs:
+      parameters:
+      - name: sample
+      - name: reference
+    container:
+      image: alpine:latest
+      args: ["{{inputs.parameters.sample}}", "{{inputs.parameters.reference}}"]
+`
+
+// TestStepMatrix verifies a step is expanded to every combination of the values of its matrix
+func TestStepMatrix(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(stepMatrix))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	expected := map[string][]string{
+		"step-matrix[0].align(0:reference:hg19,sample:a)": {"a", "hg19"},
+		"step-matrix[0].align(1:reference:hg38,sample:a)": {"a", "hg38"},
+		"step-matrix[0].align(2:reference:hg19,sample:b)": {"b", "hg19"},
+		"step-matrix[0].align(3:reference:hg38,sample:b)": {"b", "hg38"},
+		"step-matrix[0].align(4:reference:hg19,sample:c)": {"c", "hg19"},
+		"step-matrix[0].align(5:reference:hg38,sample:c)": {"c", "hg38"},
+	}
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	for nodeName, args := range expected {
+		pod, err := podcs.Get(woc.wf.NodeID(nodeName), metav1.GetOptions{})
+		if assert.NoError(t, err, nodeName) {
+			assert.Equal(t, args, pod.Spec.Containers[1].Args, nodeName)
+		}
+	}
+	pods, err := podcs.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods.Items, len(expected))
+}
+
+var stepBatch = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: step-batch
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: measure
+        template: measure
+        arguments:
+          parameters:
+          - name: samples
+            value: "{{item}}"
+          - name: range
+            value: "{{batch.start}}-{{batch.end}}"
+        withItems: [a, b, c, d, e]
+        batchSize: 2
+    - - name: report
+        template: report
+        arguments:
+          parameters:
+          - name: sizes
+            value: "{{steps.measure.outputs.parameters}}"
+  - name: measure
+    inputs:
+      parameters:
+      - name: samples
+      - name: range
+    container:
+      image: alpine:latest
+      args: ["{{inputs.parameters.samples}}", "{{inputs.parameters.range}}"]
+    outputs:
+      parameters:
+      - name: size
+        valueFrom:
+          path: /tmp/sizes
+  - name: report
+    inputs:
+      parameters:
+      - name: sizes
+    container:
+      image: alpine:latest
+      args: ["{{inputs.parameters.sizes}}"]
+`
+
+func TestStepBatch(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(stepBatch))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	expected := map[string][]string{
+		"step-batch[0].measure(0:0-1)": {`["a","b"]`, "0-1"},
+		"step-batch[0].measure(1:2-3)": {`["c","d"]`, "2-3"},
+		"step-batch[0].measure(2:4-4)": {`["e"]`, "4-4"},
+	}
+	podcs := controller.kubeclientset.CoreV1().Pods("")
+	for nodeName, args := range expected {
+		pod, err := podcs.Get(woc.wf.NodeID(nodeName), metav1.GetOptions{})
+		if assert.NoError(t, err, nodeName) {
+			assert.Equal(t, args, pod.Spec.Containers[1].Args, nodeName)
+		}
+	}
+
+	// the outputs of the batches are flattened back to the outputs of the items
+	sizes := map[string]string{
+		"step-batch[0].measure(0:0-1)": `[1, 2]`,
+		"step-batch[0].measure(1:2-3)": `[3, 4]`,
+		"step-batch[0].measure(2:4-4)": `[5]`,
+	}
+	for nodeName, size := range sizes {
+		size := size
+		makePodSucceeded(t, controller.kubeclientset, woc.wf.NodeID(nodeName), &wfv1.Outputs{
+			Parameters: []wfv1.Parameter{{Name: "size", Value: &size}},
+		})
+	}
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	pod, err := podcs.Get(woc.wf.NodeID("step-batch[1].report"), metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{`[{"size":"1"},{"size":"2"},{"size":"3"},{"size":"4"},{"size":"5"}]`}, pod.Spec.Containers[1].Args)
+	}
+}
+
+func TestUnbatchNodeOutputs(t *testing.T) {
+	params := `["a", {"b": 1}]`
+	result := `[{"sample": "a"}, 2]`
+	node := wfv1.NodeStatus{DisplayName: "measure(0:0-1)", Outputs: &wfv1.Outputs{
+		Parameters: []wfv1.Parameter{{Name: "size", Value: &params}},
+		Result:     &result,
+	}}
+	paramList, resultsList, err := unbatchNodeOutputs(&node)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"size": "a"}, {"size": `{"b":1}`}}, paramList)
+	assert.Equal(t, []wfv1.Item{{Value: map[string]interface{}{"sample": "a"}}, {Value: "2"}}, resultsList)
+
+	result = "a"
+	_, _, err = unbatchNodeOutputs(&node)
+	assert.EqualError(t, err, "outputs.result of batch measure(0:0-1) is not a JSON list")
+}
+
+var stepAggregateArtifact = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: step-aggregate-artifact
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: process
+        template: process
+        withItems: [a, b, c]
+    - - name: report
+        template: report
+        arguments:
+          artifacts:
+          - name: results
+            from: "{{steps.process.outputs.artifacts.result}}"
+  - name: process
+    container:
+      image: alpine:latest
+    outputs:
+      artifacts:
+      - name: result
+        path: /tmp/result
+  - name: report
+    inputs:
+      artifacts:
+      - name: results
+        path: /tmp/results
+    container:
+      image: alpine:latest
+`
+
+func TestStepAggregateArtifact(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(stepAggregateArtifact))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.artifactRepository.S3 = new(config.S3ArtifactRepository)
+	woc.operate()
+
+	// the item b did not produce its artifact
+	for nodeName, key := range map[string]string{
+		"step-aggregate-artifact[0].process(0:a)": "a.tgz",
+		"step-aggregate-artifact[0].process(1:b)": "",
+		"step-aggregate-artifact[0].process(2:c)": "c.tgz",
+	} {
+		outputs := &wfv1.Outputs{}
+		if key != "" {
+			outputs.Artifacts = []wfv1.Artifact{{
+				Name:             "result",
+				ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "results"}, Key: key}},
+			}}
+		}
+		makePodSucceeded(t, controller.kubeclientset, woc.wf.NodeID(nodeName), outputs)
+	}
+	wf, err = wfcset.Get(wf.ObjectMeta.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	woc = newWorkflowOperationCtx(wf, controller)
+	woc.artifactRepository.S3 = new(config.S3ArtifactRepository)
+	woc.operate()
+
+	pod, err := controller.kubeclientset.CoreV1().Pods("").Get(woc.wf.NodeID("step-aggregate-artifact[1].report"), metav1.GetOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	var tmpl wfv1.Template
+	err = json.Unmarshal([]byte(pod.Annotations[common.AnnotationKeyTemplate]), &tmpl)
+	assert.NoError(t, err)
+	art := tmpl.Inputs.GetArtifactByName("results")
+	if assert.NotNil(t, art) && assert.NotNil(t, art.Aggregate) {
+		assert.Equal(t, "/tmp/results", art.Path)
+		assert.Equal(t, []wfv1.Artifact{
+			{Name: "0", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "results"}, Key: "a.tgz"}}},
+			{Name: "2", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "results"}, Key: "c.tgz"}}},
+		}, art.Aggregate.Artifacts)
+	}
+}
@@ -2,10 +2,16 @@ package controller
 
 import (
 	"container/heap"
+	"reflect"
+	"sort"
 	"sync"
 	"time"
 
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
+
+	"github.com/cyrusbiotechnology/argo/workflow/config"
+	"github.com/cyrusbiotechnology/argo/workflow/metrics"
 )
 
 // Throttler allows CRD controller to limit number of items it is processing in parallel.
@@ -15,54 +21,110 @@ type Throttler interface {
 	Next(key interface{}) (interface{}, bool)
 	// Remove notifies throttler that item processing is done. In responses the throttler triggers processing of previously throttled items.
 	Remove(key interface{})
-	// SetParallelism update throttler parallelism limit.
-	SetParallelism(parallelism int)
+	// SetParallelism update throttler parallelism limit and fair share configuration.
+	SetParallelism(parallelism int, fairShare *config.FairShareConfig)
+	// SetNamespaceParallelism update the parallelism limits of the namespaces. Keys of the items are
+	// expected to be namespace keys (<namespace>/<name>)
+	SetNamespaceParallelism(parallelism map[string]int)
 }
 
+// GroupFunc returns the fair share group of an item given the configured group keys
+type GroupFunc func(key interface{}, groupBy []string) string
+
 type throttler struct {
-	queue       workqueue.RateLimitingInterface
-	inProgress  map[interface{}]bool
-	pending     *priorityQueue
-	lock        *sync.Mutex
-	parallelism int
+	queue      workqueue.RateLimitingInterface
+	inProgress map[interface{}]string
+	// pending holds the throttled items of every group
+	pending map[string]*priorityQueue
+	// groupInProgress is the number of items in progress of every group
+	groupInProgress map[string]int
+	// currentWeights is the state of the smooth weighted round-robin between groups
+	currentWeights map[string]int
+	// namespaceInProgress is the number of items in progress of every namespace
+	namespaceInProgress  map[string]int
+	namespaceParallelism map[string]int
+	groupFunc            GroupFunc
+	fairShare            config.FairShareConfig
+	lock                 *sync.Mutex
+	parallelism          int
 }
 
-func NewThrottler(parallelism int, queue workqueue.RateLimitingInterface) Throttler {
+// NewThrottler returns a throttler limiting the items in progress to parallelism. groupFunc
+// determines the fair share group of the items and may be nil if fair share is not used.
+func NewThrottler(parallelism int, queue workqueue.RateLimitingInterface, groupFunc GroupFunc) Throttler {
 	return &throttler{
-		queue:       queue,
-		inProgress:  make(map[interface{}]bool),
-		lock:        &sync.Mutex{},
-		parallelism: parallelism,
-		pending:     &priorityQueue{itemByKey: make(map[interface{}]*item)},
+		queue:               queue,
+		inProgress:          make(map[interface{}]string),
+		pending:             make(map[string]*priorityQueue),
+		groupInProgress:     make(map[string]int),
+		currentWeights:      make(map[string]int),
+		namespaceInProgress: make(map[string]int),
+		groupFunc:           groupFunc,
+		lock:                &sync.Mutex{},
+		parallelism:         parallelism,
 	}
 }
 
-func (t *throttler) SetParallelism(parallelism int) {
+func (t *throttler) SetParallelism(parallelism int, fairShare *config.FairShareConfig) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
-	if t.parallelism != parallelism {
-		t.parallelism = parallelism
-		t.queueThrottled()
+	defer t.reportMetrics()
+	var newFairShare config.FairShareConfig
+	if fairShare != nil {
+		newFairShare = *fairShare
+	}
+	regroup := !reflect.DeepEqual(t.fairShare.GroupBy, newFairShare.GroupBy)
+	t.fairShare = newFairShare
+	t.parallelism = parallelism
+	if regroup {
+		t.regroup()
 	}
+	t.queueThrottled()
+}
+
+func (t *throttler) SetNamespaceParallelism(parallelism map[string]int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	defer t.reportMetrics()
+	t.namespaceParallelism = parallelism
+	t.queueThrottled()
 }
 
 func (t *throttler) Add(key interface{}, priority int32, creationTime time.Time) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
-	t.pending.add(key, priority, creationTime)
+	defer t.reportMetrics()
+	group := t.groupOf(key)
+	if inProgressGroup, isInProgress := t.inProgress[key]; isInProgress {
+		if inProgressGroup != group {
+			// the group of the item changed while in progress
+			t.groupInProgress[inProgressGroup]--
+			t.groupInProgress[group]++
+			t.inProgress[key] = group
+		}
+		// the item is updated while in progress, it must not be popped and counted a second time
+		return
+	}
+	for pendingGroup, pq := range t.pending {
+		if pendingGroup != group {
+			t.removePending(pendingGroup, pq, key)
+		}
+	}
+	t.pendingQueue(group).add(key, priority, creationTime)
 }
 
 func (t *throttler) Next(key interface{}) (interface{}, bool) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
+	defer t.reportMetrics()
 
-	if _, isInProgress := t.inProgress[key]; isInProgress || t.pending.Len() == 0 {
+	if _, isInProgress := t.inProgress[key]; isInProgress || t.pendingLen() == 0 {
 		return key, true
 	}
-	if t.parallelism < 1 || t.parallelism > len(t.inProgress) {
-		next := t.pending.pop()
-		t.inProgress[next.key] = true
-		return next.key, true
+	if t.hasCapacity() {
+		if next := t.popNext(); next != nil {
+			return next.key, true
+		}
 	}
 	return key, false
 
@@ -71,20 +133,169 @@ func (t *throttler) Next(key interface{}) (interface{}, bool) {
 func (t *throttler) Remove(key interface{}) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
-	delete(t.inProgress, key)
-	t.pending.remove(key)
+	defer t.reportMetrics()
+	if group, isInProgress := t.inProgress[key]; isInProgress {
+		delete(t.inProgress, key)
+		t.groupInProgress[group]--
+		if t.groupInProgress[group] <= 0 {
+			delete(t.groupInProgress, group)
+		}
+		namespace := namespaceOf(key)
+		t.namespaceInProgress[namespace]--
+		if t.namespaceInProgress[namespace] <= 0 {
+			delete(t.namespaceInProgress, namespace)
+		}
+	}
+	for group, pq := range t.pending {
+		t.removePending(group, pq, key)
+	}
 
 	t.queueThrottled()
 }
 
 func (t *throttler) queueThrottled() {
-	for t.pending.Len() > 0 && (t.parallelism < 1 || t.parallelism > len(t.inProgress)) {
-		next := t.pending.pop()
-		t.inProgress[next.key] = true
+	for t.hasCapacity() {
+		next := t.popNext()
+		if next == nil {
+			return
+		}
 		t.queue.Add(next.key)
 	}
 }
 
+// hasCapacity returns whether the global parallelism allows more items in progress
+func (t *throttler) hasCapacity() bool {
+	return t.parallelism < 1 || t.parallelism > len(t.inProgress)
+}
+
+// popNext marks the next item to process as in progress and returns it, or returns nil if no
+// group has both pending items and room under its max concurrency. Groups are picked using a
+// smooth weighted round-robin, which interleaves the groups according to their weight.
+func (t *throttler) popNext() *item {
+	var next string
+	var nextItem *item
+	totalWeight := 0
+	groups := make([]string, 0, len(t.pending))
+	for group := range t.pending {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		maxConcurrency := t.groupMaxConcurrency(group)
+		if maxConcurrency > 0 && t.groupInProgress[group] >= maxConcurrency {
+			continue
+		}
+		candidate := t.pending[group].peek(t.hasNamespaceCapacity)
+		if candidate == nil {
+			continue
+		}
+		weight := t.groupWeight(group)
+		totalWeight += weight
+		t.currentWeights[group] += weight
+		if nextItem == nil || t.currentWeights[group] > t.currentWeights[next] {
+			next = group
+			nextItem = candidate
+		}
+	}
+	if nextItem == nil {
+		return nil
+	}
+	t.currentWeights[next] -= totalWeight
+	t.removePending(next, t.pending[next], nextItem.key)
+	t.inProgress[nextItem.key] = next
+	t.groupInProgress[next]++
+	t.namespaceInProgress[namespaceOf(nextItem.key)]++
+	return nextItem
+}
+
+// hasNamespaceCapacity returns whether the namespace of the item allows more items in progress
+func (t *throttler) hasNamespaceCapacity(key interface{}) bool {
+	parallelism := t.namespaceParallelism[namespaceOf(key)]
+	return parallelism < 1 || parallelism > t.namespaceInProgress[namespaceOf(key)]
+}
+
+// namespaceOf returns the namespace of a namespace key, or an empty string for other keys
+func namespaceOf(key interface{}) string {
+	keyStr, ok := key.(string)
+	if !ok {
+		return ""
+	}
+	namespace, _, err := cache.SplitMetaNamespaceKey(keyStr)
+	if err != nil {
+		return ""
+	}
+	return namespace
+}
+
+// regroup recomputes the group of every item after a change of the group keys
+func (t *throttler) regroup() {
+	pending := t.pending
+	t.pending = make(map[string]*priorityQueue)
+	t.currentWeights = make(map[string]int)
+	for _, pq := range pending {
+		for _, it := range pq.items {
+			t.pendingQueue(t.groupOf(it.key)).add(it.key, it.priority, it.creationTime)
+		}
+	}
+	t.groupInProgress = make(map[string]int)
+	for key := range t.inProgress {
+		group := t.groupOf(key)
+		t.inProgress[key] = group
+		t.groupInProgress[group]++
+	}
+}
+
+func (t *throttler) groupOf(key interface{}) string {
+	if t.groupFunc == nil || len(t.fairShare.GroupBy) == 0 {
+		return ""
+	}
+	return t.groupFunc(key, t.fairShare.GroupBy)
+}
+
+func (t *throttler) groupMaxConcurrency(group string) int {
+	if groupConfig, ok := t.fairShare.Groups[group]; ok && groupConfig.MaxConcurrency > 0 {
+		return groupConfig.MaxConcurrency
+	}
+	return t.fairShare.MaxConcurrency
+}
+
+func (t *throttler) groupWeight(group string) int {
+	if groupConfig, ok := t.fairShare.Groups[group]; ok && groupConfig.Weight > 0 {
+		return groupConfig.Weight
+	}
+	return 1
+}
+
+func (t *throttler) pendingQueue(group string) *priorityQueue {
+	pq, ok := t.pending[group]
+	if !ok {
+		pq = &priorityQueue{itemByKey: make(map[interface{}]*item)}
+		t.pending[group] = pq
+	}
+	return pq
+}
+
+func (t *throttler) removePending(group string, pq *priorityQueue, key interface{}) {
+	pq.remove(key)
+	if pq.Len() == 0 {
+		delete(t.pending, group)
+		delete(t.currentWeights, group)
+	}
+}
+
+func (t *throttler) pendingLen() int {
+	n := 0
+	for _, pq := range t.pending {
+		n += pq.Len()
+	}
+	return n
+}
+
+// reportMetrics publishes the pending and in-progress counts. Must be called with the lock held.
+func (t *throttler) reportMetrics() {
+	metrics.SetThrottlerCounts(t.pendingLen(), len(t.inProgress))
+}
+
 type item struct {
 	key          interface{}
 	creationTime time.Time
@@ -97,10 +308,6 @@ type priorityQueue struct {
 	itemByKey map[interface{}]*item
 }
 
-func (pq *priorityQueue) pop() *item {
-	return heap.Pop(pq).(*item)
-}
-
 func (pq *priorityQueue) add(key interface{}, priority int32, creationTime time.Time) {
 	if res, ok := pq.itemByKey[key]; ok {
 		if res.priority != priority {
@@ -112,6 +319,20 @@ func (pq *priorityQueue) add(key interface{}, priority int32, creationTime time.
 	}
 }
 
+// peek returns the first item in priority order for which eligible returns true, or nil if none
+func (pq *priorityQueue) peek(eligible func(key interface{}) bool) *item {
+	if pq.Len() > 0 && eligible(pq.items[0].key) {
+		return pq.items[0]
+	}
+	var first *item
+	for i, it := range pq.items {
+		if eligible(it.key) && (first == nil || pq.Less(i, first.index)) {
+			first = it
+		}
+	}
+	return first
+}
+
 func (pq *priorityQueue) remove(key interface{}) {
 	if item, ok := pq.itemByKey[key]; ok {
 		heap.Remove(pq, item.index)
@@ -1,17 +1,20 @@
 package controller
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"k8s.io/client-go/util/workqueue"
+
+	"github.com/cyrusbiotechnology/argo/workflow/config"
 )
 
 func TestNoParallelismSamePriority(t *testing.T) {
 	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
-	throttler := NewThrottler(0, queue)
+	throttler := NewThrottler(0, queue, nil)
 
 	throttler.Add("c", 0, time.Now().Add(2*time.Hour))
 	throttler.Add("b", 0, time.Now().Add(1*time.Hour))
@@ -28,7 +31,7 @@ func TestNoParallelismSamePriority(t *testing.T) {
 
 func TestWithParallelismLimitAndPriority(t *testing.T) {
 	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
-	throttler := NewThrottler(2, queue)
+	throttler := NewThrottler(2, queue, nil)
 
 	throttler.Add("a", 1, time.Now())
 	throttler.Add("b", 2, time.Now())
@@ -59,7 +62,7 @@ func TestWithParallelismLimitAndPriority(t *testing.T) {
 
 func TestChangeParallelism(t *testing.T) {
 	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
-	throttler := NewThrottler(1, queue)
+	throttler := NewThrottler(1, queue, nil)
 
 	throttler.Add("a", 1, time.Now())
 	throttler.Add("b", 2, time.Now())
@@ -76,7 +79,7 @@ func TestChangeParallelism(t *testing.T) {
 	_, ok = throttler.Next("c")
 	assert.False(t, ok)
 
-	throttler.SetParallelism(3)
+	throttler.SetParallelism(3, nil)
 
 	assert.Equal(t, 2, queue.Len())
 	queued, _ := queue.Get()
@@ -84,3 +87,173 @@ func TestChangeParallelism(t *testing.T) {
 	queued, _ = queue.Get()
 	assert.Equal(t, "b", queued)
 }
+
+// groupByPrefix groups the test keys by the part before '/'
+func groupByPrefix(key interface{}, groupBy []string) string {
+	return strings.Split(key.(string), "/")[0]
+}
+
+func nextKeys(throttler Throttler, n int) []interface{} {
+	var keys []interface{}
+	for i := 0; i < n; i++ {
+		next, ok := throttler.Next("")
+		if !ok {
+			break
+		}
+		keys = append(keys, next)
+	}
+	return keys
+}
+
+func TestFairShareRoundRobin(t *testing.T) {
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	throttler := NewThrottler(0, queue, groupByPrefix)
+	throttler.SetParallelism(0, &config.FairShareConfig{GroupBy: []string{"user"}})
+
+	throttler.Add("a/1", 3, time.Now())
+	throttler.Add("a/2", 2, time.Now())
+	throttler.Add("a/3", 1, time.Now())
+	throttler.Add("b/1", 0, time.Now())
+
+	assert.Equal(t, []interface{}{"a/1", "b/1", "a/2", "a/3"}, nextKeys(throttler, 4))
+}
+
+func TestFairShareMaxConcurrency(t *testing.T) {
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	throttler := NewThrottler(0, queue, groupByPrefix)
+	throttler.SetParallelism(0, &config.FairShareConfig{
+		GroupBy:        []string{"user"},
+		MaxConcurrency: 1,
+		Groups:         map[string]config.FairShareGroup{"b": {MaxConcurrency: 2}},
+	})
+
+	throttler.Add("a/1", 0, time.Now())
+	throttler.Add("a/2", 0, time.Now().Add(time.Hour))
+	throttler.Add("b/1", 0, time.Now())
+	throttler.Add("b/2", 0, time.Now().Add(time.Hour))
+	throttler.Add("b/3", 0, time.Now().Add(2*time.Hour))
+
+	assert.Equal(t, []interface{}{"a/1", "b/1", "b/2"}, nextKeys(throttler, 5))
+
+	throttler.Remove("a/1")
+
+	assert.Equal(t, 1, queue.Len())
+	queued, _ := queue.Get()
+	assert.Equal(t, "a/2", queued)
+}
+
+// TestFairShareReAddInProgress verifies an item updated while in progress is not counted twice
+// against the max concurrency of its group
+func TestFairShareReAddInProgress(t *testing.T) {
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	throttler := NewThrottler(0, queue, groupByPrefix)
+	throttler.SetParallelism(0, &config.FairShareConfig{GroupBy: []string{"user"}, MaxConcurrency: 2})
+
+	throttler.Add("a/1", 0, time.Now())
+	throttler.Add("a/2", 0, time.Now().Add(time.Hour))
+	throttler.Add("a/3", 0, time.Now().Add(2*time.Hour))
+	throttler.Add("a/4", 0, time.Now().Add(3*time.Hour))
+
+	next, ok := throttler.Next("a/1")
+	assert.True(t, ok)
+	assert.Equal(t, "a/1", next)
+	// the informer adds the workflows again on every update
+	throttler.Add("a/1", 0, time.Now())
+	next, ok = throttler.Next("a/2")
+	assert.True(t, ok)
+	assert.Equal(t, "a/2", next)
+	_, ok = throttler.Next("a/3")
+	assert.False(t, ok)
+
+	throttler.Remove("a/1")
+	throttler.Remove("a/2")
+
+	assert.Equal(t, 2, queue.Len())
+	queued, _ := queue.Get()
+	assert.Equal(t, "a/3", queued)
+	queued, _ = queue.Get()
+	assert.Equal(t, "a/4", queued)
+}
+
+func TestFairShareWeights(t *testing.T) {
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	throttler := NewThrottler(0, queue, groupByPrefix)
+	throttler.SetParallelism(0, &config.FairShareConfig{
+		GroupBy: []string{"user"},
+		Groups:  map[string]config.FairShareGroup{"a": {Weight: 2}},
+	})
+
+	now := time.Now()
+	for i, key := range []string{"1", "2", "3", "4"} {
+		throttler.Add("a/"+key, 0, now.Add(time.Duration(i)*time.Hour))
+		throttler.Add("b/"+key, 0, now.Add(time.Duration(i)*time.Hour))
+	}
+
+	assert.Equal(t, []interface{}{"a/1", "b/1", "a/2", "a/3", "b/2", "a/4"}, nextKeys(throttler, 6))
+}
+
+func TestFairShareUpdate(t *testing.T) {
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	throttler := NewThrottler(1, queue, groupByPrefix)
+
+	throttler.Add("a/1", 3, time.Now())
+	throttler.Add("a/2", 2, time.Now())
+	throttler.Add("b/1", 1, time.Now())
+
+	next, ok := throttler.Next("a/1")
+	assert.True(t, ok)
+	assert.Equal(t, "a/1", next)
+
+	throttler.SetParallelism(3, &config.FairShareConfig{GroupBy: []string{"user"}, MaxConcurrency: 1})
+
+	assert.Equal(t, 1, queue.Len())
+	queued, _ := queue.Get()
+	assert.Equal(t, "b/1", queued)
+}
+
+func TestNamespaceParallelism(t *testing.T) {
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	throttler := NewThrottler(0, queue, nil)
+	throttler.SetNamespaceParallelism(map[string]int{"a": 1})
+
+	throttler.Add("a/1", 2, time.Now())
+	throttler.Add("a/2", 1, time.Now())
+	throttler.Add("b/1", 0, time.Now())
+
+	assert.Equal(t, []interface{}{"a/1", "b/1"}, nextKeys(throttler, 3))
+
+	throttler.Remove("a/1")
+
+	assert.Equal(t, 1, queue.Len())
+	queued, _ := queue.Get()
+	assert.Equal(t, "a/2", queued)
+}
+
+// TestNamespaceParallelismReAddInProgress verifies an item updated while in progress is not counted
+// twice against the parallelism of its namespace
+func TestNamespaceParallelismReAddInProgress(t *testing.T) {
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	throttler := NewThrottler(0, queue, nil)
+	throttler.SetNamespaceParallelism(map[string]int{"a": 2})
+
+	throttler.Add("a/1", 0, time.Now())
+	throttler.Add("a/2", 0, time.Now().Add(time.Hour))
+	throttler.Add("a/3", 0, time.Now().Add(2*time.Hour))
+
+	next, ok := throttler.Next("a/1")
+	assert.True(t, ok)
+	assert.Equal(t, "a/1", next)
+	// the informer adds the workflows again on every update
+	throttler.Add("a/1", 0, time.Now())
+	next, ok = throttler.Next("a/2")
+	assert.True(t, ok)
+	assert.Equal(t, "a/2", next)
+	_, ok = throttler.Next("a/3")
+	assert.False(t, ok)
+
+	throttler.Remove("a/1")
+
+	assert.Equal(t, 1, queue.Len())
+	queued, _ := queue.Get()
+	assert.Equal(t, "a/3", queued)
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+	"github.com/cyrusbiotechnology/argo/workflow/tracing"
+)
+
+// tracingServiceName is the service name under which the controller reports its spans
+const tracingServiceName = "workflow-controller"
+
+// updateTracerProvider replaces the tracer provider according to the tracing configuration, unless
+// the configuration did not change
+func (wfc *WorkflowController) updateTracerProvider() {
+	if wfc.tracingConfig != nil && reflect.DeepEqual(*wfc.tracingConfig, wfc.Config.TracingConfig) {
+		return
+	}
+	tracingConfig := wfc.Config.TracingConfig
+	wfc.tracingConfig = &tracingConfig
+	if wfc.tracerProvider != nil {
+		err := wfc.tracerProvider.Shutdown(context.Background())
+		if err != nil {
+			log.Warnf("Failed to shutdown tracer provider: %v", err)
+		}
+		wfc.tracerProvider = nil
+	}
+	if !wfc.Config.TracingConfig.Enabled {
+		log.Info("Tracing disabled")
+		return
+	}
+	exporter, err := tracing.NewExporter(wfc.Config.TracingConfig)
+	if err != nil {
+		log.Errorf("Error creating tracing exporter. %v", err)
+		return
+	}
+	wfc.tracerProvider = tracing.NewTracerProvider(exporter, tracingServiceName)
+	log.Info("Tracing enabled")
+}
+
+// tracingEnabled returns whether the controller emits spans
+func (wfc *WorkflowController) tracingEnabled() bool {
+	return wfc.tracerProvider != nil
+}
+
+// snapshotCompletedNodes remembers which nodes were already completed when the operation
+// started, so that the span of every node is emitted only once
+func (woc *wfOperationCtx) snapshotCompletedNodes() {
+	if !woc.controller.tracingEnabled() {
+		return
+	}
+	woc.tracedNodes = make(map[string]bool)
+	for nodeID, node := range woc.wf.Status.Nodes {
+		if node.Completed() {
+			woc.tracedNodes[nodeID] = true
+		}
+	}
+	woc.tracedWorkflow = woc.wf.Status.Completed()
+}
+
+// traceCompletedNodes emits the spans of the nodes, and of the workflow itself, which completed
+// during the operation. Spans are emitted after the fact using the recorded start and finish
+// times, and are given deterministic identifiers so they form a single trace across operations.
+func (woc *wfOperationCtx) traceCompletedNodes(nodes map[string]wfv1.NodeStatus) {
+	if woc.tracedNodes == nil {
+		return
+	}
+	provider := woc.controller.tracerProvider
+	wfSpanContext := tracing.WorkflowSpanContext(woc.wf)
+	parents := spanParents(nodes)
+	for nodeID, node := range nodes {
+		if !node.Completed() || woc.tracedNodes[nodeID] {
+			continue
+		}
+		parent := wfSpanContext
+		if parentID, ok := parents[nodeID]; ok {
+			parent = tracing.NodeSpanContext(woc.wf, parentID)
+		}
+		attrs := []tracing.Attribute{
+			tracing.String("argo.node.id", nodeID),
+			tracing.String("argo.node.name", node.Name),
+			tracing.String("argo.node.type", string(node.Type)),
+			tracing.String("argo.node.phase", string(node.Phase)),
+		}
+		if node.TemplateName != "" {
+			attrs = append(attrs, tracing.String("argo.node.template", node.TemplateName))
+		}
+		if node.PodIP != "" {
+			attrs = append(attrs, tracing.String("argo.node.pod_ip", node.PodIP))
+		}
+		span := provider.StartSpan(node.DisplayName, tracing.NodeSpanContext(woc.wf, nodeID), parent, node.StartedAt.Time, attrs...)
+		tracing.SetSpanPhase(span, node.Phase, node.Message)
+		span.EndAt(spanEndTime(node.FinishedAt.Time))
+		woc.tracedNodes[nodeID] = true
+	}
+	if woc.wf.Status.Completed() && !woc.tracedWorkflow {
+		span := provider.StartSpan(woc.wf.ObjectMeta.Name, wfSpanContext, tracing.SpanContext{}, woc.wf.Status.StartedAt.Time,
+			tracing.String("argo.workflow.namespace", woc.wf.ObjectMeta.Namespace),
+			tracing.String("argo.workflow.name", woc.wf.ObjectMeta.Name),
+			tracing.String("argo.workflow.phase", string(woc.wf.Status.Phase)),
+		)
+		tracing.SetSpanPhase(span, woc.wf.Status.Phase, woc.wf.Status.Message)
+		span.EndAt(spanEndTime(woc.wf.Status.FinishedAt.Time))
+		woc.tracedWorkflow = true
+	}
+}
+
+// startChildSpan starts a span for an operation performed on behalf of the given parent span
+func (woc *wfOperationCtx) startChildSpan(name string, parent tracing.SpanContext, attrs ...tracing.Attribute) *tracing.Span {
+	return woc.controller.tracerProvider.StartChildSpan(name, parent, attrs...)
+}
+
+// spanParents returns the parent node ID of the span of every node, following the same
+// hierarchy as `argo get`: children of step groups, task groups and retry nodes are attached
+// to them, all other nodes are attached to their boundary. Nodes without a parent are
+// attached to the workflow span.
+func spanParents(nodes map[string]wfv1.NodeStatus) map[string]string {
+	parents := make(map[string]string)
+	for nodeID, node := range nodes {
+		if _, ok := nodes[node.BoundaryID]; ok && node.BoundaryID != nodeID {
+			parents[nodeID] = node.BoundaryID
+		}
+	}
+	for nodeID, node := range nodes {
+		switch node.Type {
+		case wfv1.NodeTypeStepGroup, wfv1.NodeTypeTaskGroup, wfv1.NodeTypeRetry:
+			for _, childID := range node.Children {
+				parents[childID] = nodeID
+			}
+		}
+	}
+	return parents
+}
+
+// spanEndTime returns the finish time of a span, defaulting to now if it was not recorded
+func spanEndTime(finishedAt time.Time) time.Time {
+	if finishedAt.IsZero() {
+		return time.Now()
+	}
+	return finishedAt
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+	"github.com/cyrusbiotechnology/argo/workflow/common"
+	"github.com/cyrusbiotechnology/argo/workflow/tracing"
+)
+
+func newTracingController() (*WorkflowController, *tracing.InMemoryExporter) {
+	controller := newController()
+	exporter := tracing.NewInMemoryExporter()
+	controller.tracerProvider = tracing.NewTracerProvider(exporter, tracingServiceName)
+	return controller, exporter
+}
+
+var tracedSteps = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: traced-steps
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: skipped
+        template: whalesay
+        when: "1 == 2"
+  - name: whalesay
+    container:
+      image: docker/whalesay:latest
+`
+
+// TestTraceCompletedNodes verifies a span is emitted for every completed node and for the
+// workflow, following the hierarchy of the nodes
+func TestTraceCompletedNodes(t *testing.T) {
+	controller, exporter := newTracingController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(tracedSteps))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	assert.Equal(t, wfv1.NodeSucceeded, woc.wf.Status.Phase)
+	assert.NoError(t, controller.tracerProvider.ForceFlush(context.Background()))
+
+	spans := make(map[string]tracing.SpanData)
+	for _, span := range exporter.GetSpans() {
+		spans[span.SpanContext.SpanID.String()] = span
+	}
+	assert.Len(t, spans, 4)
+	spanOf := func(sc tracing.SpanContext) tracing.SpanData {
+		span, ok := spans[sc.SpanID.String()]
+		assert.True(t, ok)
+		return span
+	}
+	root := spanOf(tracing.WorkflowSpanContext(woc.wf))
+	assert.False(t, root.Parent.IsValid())
+	stepsSpan := spanOf(tracing.NodeSpanContext(woc.wf, woc.getNodeByName("traced-steps").ID))
+	assert.Equal(t, root.SpanContext.SpanID, stepsSpan.Parent.SpanID)
+	stepGroupSpan := spanOf(tracing.NodeSpanContext(woc.wf, woc.getNodeByName("traced-steps[0]").ID))
+	assert.Equal(t, stepsSpan.SpanContext.SpanID, stepGroupSpan.Parent.SpanID)
+	skippedSpan := spanOf(tracing.NodeSpanContext(woc.wf, woc.getNodeByName("traced-steps[0].skipped").ID))
+	assert.Equal(t, stepGroupSpan.SpanContext.SpanID, skippedSpan.Parent.SpanID)
+	assert.Equal(t, "skipped", skippedSpan.Name)
+
+	// completed nodes are not traced again
+	exporter.Reset()
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.snapshotCompletedNodes()
+	woc.updated = true
+	woc.persistUpdates()
+	assert.NoError(t, controller.tracerProvider.ForceFlush(context.Background()))
+	assert.Len(t, exporter.GetSpans(), 0)
+}
+
+// TestTracePodCreation verifies the trace context is propagated to the pod and pod creation is traced
+func TestTracePodCreation(t *testing.T) {
+	controller, exporter := newTracingController()
+	controller.Config.TracingConfig = tracing.Config{
+		Enabled:  true,
+		Exporter: tracing.ExporterStdout,
+		Headers:  map[string]string{"Authorization": "Bearer secret"},
+	}
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(helloWorldWf))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+	assert.NoError(t, controller.tracerProvider.ForceFlush(context.Background()))
+
+	pods, err := controller.kubeclientset.CoreV1().Pods("").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, pods.Items, 1) {
+		pod := pods.Items[0]
+		nodeSpanContext := tracing.NodeSpanContext(woc.wf, pod.Name)
+		podSpanContext, err := tracing.ExtractAnnotation(pod.Annotations[common.AnnotationKeyTraceContext])
+		assert.NoError(t, err)
+		assert.Equal(t, nodeSpanContext, podSpanContext)
+		waitCtr := pod.Spec.Containers[0]
+		assert.Equal(t, common.WaitContainerName, waitCtr.Name)
+		var tracingConfig *tracing.Config
+		for _, env := range waitCtr.Env {
+			if env.Name == common.EnvVarTracingConfig {
+				tracingConfig = &tracing.Config{}
+				assert.NoError(t, json.Unmarshal([]byte(env.Value), tracingConfig))
+			}
+		}
+		if assert.NotNil(t, tracingConfig) {
+			assert.Equal(t, tracing.ExporterStdout, tracingConfig.Exporter)
+			// the credentials of the collector are not exposed to the pods
+			assert.Empty(t, tracingConfig.Headers)
+		}
+
+		spans := exporter.GetSpans()
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, "create pod", spans[0].Name)
+			assert.Equal(t, nodeSpanContext.SpanID, spans[0].Parent.SpanID)
+		}
+	}
+}
+
+// TestUpdateTracerProvider verifies the tracer provider is only rebuilt when the tracing
+// configuration changes
+func TestUpdateTracerProvider(t *testing.T) {
+	controller := newController()
+	controller.Config.TracingConfig = tracing.Config{Enabled: true, Exporter: tracing.ExporterStdout}
+	controller.updateTracerProvider()
+	tracerProvider := controller.tracerProvider
+	assert.NotNil(t, tracerProvider)
+
+	controller.updateTracerProvider()
+	assert.True(t, tracerProvider == controller.tracerProvider)
+
+	controller.Config.TracingConfig.Headers = map[string]string{"Authorization": "Bearer secret"}
+	controller.updateTracerProvider()
+	assert.NotNil(t, controller.tracerProvider)
+	assert.False(t, tracerProvider == controller.tracerProvider)
+
+	controller.Config.TracingConfig.Enabled = false
+	controller.updateTracerProvider()
+	assert.Nil(t, controller.tracerProvider)
+}
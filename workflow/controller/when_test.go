@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
 )
 
 func TestShouldExecute(t *testing.T) {
@@ -51,3 +53,113 @@ func TestShouldExecute(t *testing.T) {
 		assert.False(t, res)
 	}
 }
+
+var typedWhen = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: typed-when
+spec:
+  entrypoint: main
+  arguments:
+    parameters:
+    - name: branch
+      value: release/v1
+  templates:
+  - name: main
+    inputs:
+      parameters:
+      - name: threshold
+        value: "1"
+    steps:
+    - - name: check
+        template: check
+        continueOn:
+          exitCodes: [2]
+    - - name: retry-check
+        template: check
+        when: "steps.check.exitCode == 2 && int(inputs.parameters.threshold) < 2"
+      - name: release
+        template: check
+        when: "matches(workflow.parameters.branch, '^master$')"
+  - name: check
+    container:
+      image: alpine:latest
+`
+
+// TestTypedWhen verifies the typed when expressions are evaluated against the exit codes, inputs
+// and global variables
+func TestTypedWhen(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(typedWhen))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	makePodFailed(t, controller.kubeclientset, woc.wf.NodeID("typed-when[0].check"), 2)
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	retryCheck := woc.getNodeByName("typed-when[1].retry-check")
+	if assert.NotNil(t, retryCheck) {
+		assert.Equal(t, wfv1.NodeTypePod, retryCheck.Type)
+	}
+	release := woc.getNodeByName("typed-when[1].release")
+	if assert.NotNil(t, release) {
+		assert.Equal(t, wfv1.NodeSkipped, release.Phase)
+		assert.Equal(t, "when 'matches(workflow.parameters.branch, '^master$')' evaluated false", release.Message)
+	}
+}
+
+var typedWhenValues = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  name: typed-when-values
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    inputs:
+      parameters:
+      - name: threshold
+        value: "5"
+    steps:
+    - - name: count
+        template: count
+    - - name: many
+        template: count
+        when: "steps.count.outputs.result == 5 && inputs.parameters.threshold > 3"
+      - name: none
+        template: count
+        when: "steps.count.outputs.result == 0"
+  - name: count
+    script:
+      image: alpine:latest
+      command: [sh]
+      source: echo 5
+`
+
+// TestTypedWhenValues verifies the numeric parameters and outputs are compared as numbers in the
+// typed when expressions
+func TestTypedWhenValues(t *testing.T) {
+	controller := newController()
+	wfcset := controller.wfclientset.ArgoprojV1alpha1().Workflows("")
+	wf, err := wfcset.Create(unmarshalWF(typedWhenValues))
+	assert.NoError(t, err)
+	woc := newWorkflowOperationCtx(wf, controller)
+	woc.operate()
+
+	result := "5"
+	makePodSucceeded(t, controller.kubeclientset, woc.wf.NodeID("typed-when-values[0].count"), &wfv1.Outputs{Result: &result})
+	woc = newWorkflowOperationCtx(woc.wf, controller)
+	woc.operate()
+	many := woc.getNodeByName("typed-when-values[1].many")
+	if assert.NotNil(t, many) {
+		assert.Equal(t, wfv1.NodeTypePod, many.Type)
+	}
+	none := woc.getNodeByName("typed-when-values[1].none")
+	if assert.NotNil(t, none) {
+		assert.Equal(t, wfv1.NodeSkipped, none.Phase)
+	}
+}
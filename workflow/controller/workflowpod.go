@@ -13,11 +13,13 @@ import (
 	"github.com/cyrusbiotechnology/argo/pkg/apis/workflow"
 	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
 	"github.com/cyrusbiotechnology/argo/workflow/common"
+	"github.com/cyrusbiotechnology/argo/workflow/tracing"
 	"github.com/cyrusbiotechnology/argo/workflow/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/valyala/fasttemplate"
 	apiv1 "k8s.io/api/core/v1"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/utils/pointer"
@@ -93,6 +95,10 @@ func (woc *wfOperationCtx) createWorkflowPod(nodeName string, mainCtr apiv1.Cont
 	wfSpec := woc.wf.Spec.DeepCopy()
 
 	mainCtr.Name = common.MainContainerName
+	err := woc.escalateResources(nodeName, &mainCtr, tmpl)
+	if err != nil {
+		return nil, err
+	}
 	pod := &apiv1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      nodeID,
@@ -138,7 +144,7 @@ func (woc *wfOperationCtx) createWorkflowPod(nodeName string, mainCtr apiv1.Cont
 		pod.Spec.ShareProcessNamespace = pointer.BoolPtr(true)
 	}
 
-	err := woc.addArchiveLocation(pod, tmpl)
+	err = woc.addArchiveLocation(pod, tmpl)
 	if err != nil {
 		return nil, err
 	}
@@ -208,6 +214,20 @@ func (woc *wfOperationCtx) createWorkflowPod(nodeName string, mainCtr apiv1.Cont
 	}
 	pod.ObjectMeta.Annotations[common.AnnotationKeyTemplate] = string(tmplBytes)
 
+	// Propagate the trace context of the node so the executor spans become children of the node span
+	if woc.controller.tracingEnabled() {
+		traceCtx, err := tracing.InjectAnnotation(tracing.NodeSpanContext(woc.wf, nodeID))
+		if err != nil {
+			return nil, err
+		}
+		pod.ObjectMeta.Annotations[common.AnnotationKeyTraceContext] = traceCtx
+	}
+
+	// Tell the pods recreated after a failure of the infrastructure apart from the failed one
+	if node := woc.getNodeByName(nodeName); node != nil && node.InfrastructureRetries > 0 {
+		pod.ObjectMeta.Annotations[common.AnnotationKeyInfrastructureRetries] = strconv.Itoa(int(node.InfrastructureRetries))
+	}
+
 	// Perform one last variable substitution here. Some variables come from the from workflow
 	// configmap (e.g. archive location) or volumes attribute, and were not substituted
 	// in executeTemplate.
@@ -270,7 +290,14 @@ func (woc *wfOperationCtx) createWorkflowPod(nodeName string, mainCtr apiv1.Cont
 			return nil, errors.Wrap(err, "", "Error in Unmarshalling after merge the patch")
 		}
 	}
+	for _, ctr := range pod.Spec.Containers {
+		if ctr.Name == common.MainContainerName {
+			woc.setNodeResources(nodeID, ctr.Resources)
+		}
+	}
+	span := woc.startChildSpan("create pod", tracing.NodeSpanContext(woc.wf, nodeID), tracing.String("argo.pod.name", pod.Name))
 	created, err := woc.controller.kubeclientset.CoreV1().Pods(woc.wf.ObjectMeta.Namespace).Create(pod)
+	tracing.EndSpan(span, err)
 	if err != nil {
 		if apierr.IsAlreadyExists(err) {
 			// workflow pod names are deterministic. We can get here if the
@@ -283,9 +310,80 @@ func (woc *wfOperationCtx) createWorkflowPod(nodeName string, mainCtr apiv1.Cont
 	}
 	woc.log.Infof("Created pod: %s (%s)", nodeName, created.Name)
 	woc.activePods++
+	woc.namespaceActivePods++
 	return created, nil
 }
 
+// escalateResources escalates the resources of the main container of a retry attempt, when the
+// previous attempt ran out of them and the retry strategy has a resource escalation. The resources
+// of the previous attempt are carried forward otherwise, so that a failure unrelated to the
+// resources does not fall back to the resources of the template which were already exhausted.
+func (woc *wfOperationCtx) escalateResources(nodeName string, mainCtr *apiv1.Container, tmpl *wfv1.Template) error {
+	if tmpl.RetryStrategy == nil || tmpl.RetryStrategy.ResourceEscalation == nil {
+		return nil
+	}
+	prevAttempt := woc.getPreviousRetryAttempt(nodeName)
+	if prevAttempt == nil || prevAttempt.Resources == nil {
+		return nil
+	}
+	if len(prevAttempt.ExhaustedResources) == 0 {
+		mainCtr.Resources = *prevAttempt.Resources.DeepCopy()
+		return nil
+	}
+	escalation := tmpl.RetryStrategy.ResourceEscalation
+	factor, err := escalation.GetFactor()
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "retryStrategy.resourceEscalation.factor '%s' is invalid: %v", escalation.Factor, err)
+	}
+	resources := prevAttempt.Resources.DeepCopy()
+	for _, name := range prevAttempt.ExhaustedResources {
+		maxQuantity, hasMax := escalation.Max[name]
+		for _, list := range []apiv1.ResourceList{resources.Requests, resources.Limits} {
+			quantity, ok := list[name]
+			if !ok {
+				continue
+			}
+			escalated := resource.NewMilliQuantity(int64(float64(quantity.MilliValue())*factor), quantity.Format)
+			if hasMax && escalated.Cmp(maxQuantity) > 0 {
+				escalated = maxQuantity.Copy()
+			}
+			list[name] = *escalated
+		}
+	}
+	woc.log.Infof("Escalating the %v resources of %s to %v", prevAttempt.ExhaustedResources, nodeName, resources)
+	mainCtr.Resources = *resources
+	return nil
+}
+
+// getPreviousRetryAttempt returns the attempt preceding a retry attempt, or nil if the node is not a
+// retry attempt or is the first one
+func (woc *wfOperationCtx) getPreviousRetryAttempt(nodeName string) *wfv1.NodeStatus {
+	indx := strings.LastIndex(nodeName, "(")
+	if indx < 0 || !strings.HasSuffix(nodeName, ")") {
+		return nil
+	}
+	attempt, err := strconv.Atoi(nodeName[indx+1 : len(nodeName)-1])
+	if err != nil || attempt == 0 {
+		return nil
+	}
+	retryNode := woc.getNodeByName(nodeName[:indx])
+	if retryNode == nil || retryNode.Type != wfv1.NodeTypeRetry {
+		return nil
+	}
+	return woc.getNodeByName(fmt.Sprintf("%s(%d)", retryNode.Name, attempt-1))
+}
+
+// setNodeResources records the effective resources of the main container of a pod node
+func (woc *wfOperationCtx) setNodeResources(nodeID string, resources apiv1.ResourceRequirements) {
+	node, ok := woc.wf.Status.Nodes[nodeID]
+	if !ok || (len(resources.Requests) == 0 && len(resources.Limits) == 0) {
+		return
+	}
+	node.Resources = resources.DeepCopy()
+	woc.wf.Status.Nodes[nodeID] = node
+	woc.updated = true
+}
+
 // substitutePodParams returns a pod spec with parameter references substituted as well as pod.name
 func substitutePodParams(pod *apiv1.Pod, globalParams map[string]string, tmpl *wfv1.Template) (*apiv1.Pod, error) {
 	podParams := make(map[string]string)
@@ -416,6 +514,21 @@ func (woc *wfOperationCtx) createEnvVars() []apiv1.EnvVar {
 			},
 		)
 	}
+	if tracingConfig := woc.controller.Config.TracingConfig; tracingConfig.Enabled {
+		// the headers usually hold the credentials of the collector, which must not end up in the
+		// environment of the pods, readable by anyone allowed to get them
+		tracingConfig.Headers = nil
+		tracingConfigBytes, err := json.Marshal(tracingConfig)
+		if err != nil {
+			panic(err)
+		}
+		execEnvVars = append(execEnvVars,
+			apiv1.EnvVar{
+				Name:  common.EnvVarTracingConfig,
+				Value: string(tracingConfigBytes),
+			},
+		)
+	}
 	return execEnvVars
 }
 
@@ -1095,6 +1208,10 @@ func createSecretVolume(volMap map[string]apiv1.Volume, art wfv1.Artifact, keyMa
 		createSecretVal(volMap, art.HDFS.KrbKeytabSecret, keyMap)
 	} else if art.GCS != nil {
 		createSecretVal(volMap, &art.GCS.CredentialsSecret, keyMap)
+	} else if art.Aggregate != nil {
+		for _, itemArt := range art.Aggregate.Artifacts {
+			createSecretVolume(volMap, itemArt, keyMap)
+		}
 	}
 }
 
@@ -7,7 +7,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/cyrusbiotechnology/argo/workflow/artifacts/gcs"
 	"io"
 	"io/ioutil"
 	"net/url"
@@ -36,13 +35,8 @@ import (
 	"github.com/cyrusbiotechnology/argo/util/archive"
 	"github.com/cyrusbiotechnology/argo/util/retry"
 	artifact "github.com/cyrusbiotechnology/argo/workflow/artifacts"
-	"github.com/cyrusbiotechnology/argo/workflow/artifacts/artifactory"
-	"github.com/cyrusbiotechnology/argo/workflow/artifacts/git"
-	"github.com/cyrusbiotechnology/argo/workflow/artifacts/hdfs"
-	"github.com/cyrusbiotechnology/argo/workflow/artifacts/http"
-	"github.com/cyrusbiotechnology/argo/workflow/artifacts/raw"
-	"github.com/cyrusbiotechnology/argo/workflow/artifacts/s3"
 	"github.com/cyrusbiotechnology/argo/workflow/common"
+	"github.com/cyrusbiotechnology/argo/workflow/tracing"
 	argofile "github.com/argoproj/pkg/file"
 )
 
@@ -72,6 +66,10 @@ type WorkflowExecutor struct {
 	// list of errors that occurred during execution.
 	// the first of these is used as the overall message of the node
 	errors []error
+	// tracerProvider exports the executor spans. nil when tracing is disabled
+	tracerProvider *tracing.TracerProvider
+	// traceParent is the node span propagated by the controller
+	traceParent tracing.SpanContext
 }
 
 // ContainerRuntimeExecutor is the interface for interacting with a container runtime (e.g. docker)
@@ -142,10 +140,6 @@ func (we *WorkflowExecutor) LoadArtifacts() error {
 				return errors.New("required artifact %s not supplied", art.Name)
 			}
 		}
-		artDriver, err := we.InitDriver(art)
-		if err != nil {
-			return err
-		}
 		// Determine the file path of where to load the artifact
 		if art.Path == "" {
 			return errors.InternalErrorf("Artifact %s did not specify a path", art.Name)
@@ -164,20 +158,14 @@ func (we *WorkflowExecutor) LoadArtifacts() error {
 			artPath = path.Join(common.ExecutorMainFilesystemDir, art.Path)
 		}
 
-		// The artifact is downloaded to a temporary location, after which we determine if
-		// the file is a tarball or not. If it is, it is first extracted then renamed to
-		// the desired location. If not, it is simply renamed to the location.
-		tempArtPath := artPath + ".tmp"
-		err = artDriver.Load(&art, tempArtPath)
-		if err != nil {
-			return err
-		}
-		if isTarball(tempArtPath) {
-			err = untar(tempArtPath, artPath)
-			_ = os.Remove(tempArtPath)
+		span := we.startSpan("load artifact", tracing.String("argo.artifact.name", art.Name))
+		var err error
+		if art.Aggregate != nil {
+			err = we.loadAggregateArtifact(art.Aggregate, artPath)
 		} else {
-			err = os.Rename(tempArtPath, artPath)
+			err = we.loadArtifact(&art, artPath)
 		}
+		tracing.EndSpan(span, err)
 		if err != nil {
 			return err
 		}
@@ -193,6 +181,44 @@ func (we *WorkflowExecutor) LoadArtifacts() error {
 	return nil
 }
 
+// loadArtifact loads an artifact to a path. The artifact is downloaded to a temporary location, after
+// which we determine if the file is a tarball or not. If it is, it is first extracted then renamed to
+// the desired location. If not, it is simply renamed to the location.
+func (we *WorkflowExecutor) loadArtifact(art *wfv1.Artifact, artPath string) error {
+	artDriver, err := we.InitDriver(*art)
+	if err != nil {
+		return err
+	}
+	tempArtPath := artPath + ".tmp"
+	err = artDriver.Load(art, tempArtPath)
+	if err != nil {
+		return err
+	}
+	if isTarball(tempArtPath) {
+		err = untar(tempArtPath, artPath)
+		_ = os.Remove(tempArtPath)
+	} else {
+		err = os.Rename(tempArtPath, artPath)
+	}
+	return err
+}
+
+// loadAggregateArtifact loads the artifacts of the items of a loop into a directory, with an entry
+// per index of the loop
+func (we *WorkflowExecutor) loadAggregateArtifact(aggregate *wfv1.AggregateArtifact, artPath string) error {
+	err := os.MkdirAll(artPath, 0755)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	for _, itemArt := range aggregate.Artifacts {
+		err = we.loadArtifact(&itemArt, path.Join(artPath, itemArt.Name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // StageFiles will create any files required by script/resource templates
 func (we *WorkflowExecutor) StageFiles() error {
 	var filePath string
@@ -234,7 +260,9 @@ func (we *WorkflowExecutor) SaveArtifacts() error {
 	}
 
 	for i, art := range we.Template.Outputs.Artifacts {
+		span := we.startSpan("save artifact", tracing.String("argo.artifact.name", art.Name))
 		err := we.saveArtifact(mainCtrID, &art)
+		tracing.EndSpan(span, err)
 		if err != nil {
 			return err
 		}
@@ -558,102 +586,7 @@ func (we *WorkflowExecutor) saveLogToFile(mainCtrID, path string) error {
 
 // InitDriver initializes an instance of an artifact driver
 func (we *WorkflowExecutor) InitDriver(art wfv1.Artifact) (artifact.ArtifactDriver, error) {
-	if art.S3 != nil {
-		var accessKey string
-		var secretKey string
-
-		if art.S3.AccessKeySecret.Name != "" {
-			accessKeyBytes, err := we.GetSecretFromVolMount(art.S3.AccessKeySecret.Name, art.S3.AccessKeySecret.Key)
-			if err != nil {
-				return nil, err
-			}
-			accessKey = string(accessKeyBytes)
-			secretKeyBytes, err := we.GetSecretFromVolMount(art.S3.SecretKeySecret.Name, art.S3.SecretKeySecret.Key)
-			if err != nil {
-				return nil, err
-			}
-			secretKey = string(secretKeyBytes)
-		}
-
-		driver := s3.S3ArtifactDriver{
-			Endpoint:  art.S3.Endpoint,
-			AccessKey: accessKey,
-			SecretKey: secretKey,
-			Secure:    art.S3.Insecure == nil || !*art.S3.Insecure,
-			Region:    art.S3.Region,
-			RoleARN:   art.S3.RoleARN,
-		}
-		return &driver, nil
-	}
-	if art.GCS != nil {
-		credsJSONData, err := we.GetSecretFromVolMount(art.GCS.CredentialsSecret.Name, art.GCS.CredentialsSecret.Key)
-		if err != nil {
-			return nil, err
-		}
-		driver := gcs.GCSArtifactDriver{
-			CredsJSONData: credsJSONData,
-		}
-		return &driver, nil
-	}
-	if art.GCS != nil {
-		driver := gcs.GCSArtifactDriver{}
-		return &driver, nil
-	}
-	if art.HTTP != nil {
-		return &http.HTTPArtifactDriver{}, nil
-	}
-	if art.Git != nil {
-		gitDriver := git.GitArtifactDriver{
-			InsecureIgnoreHostKey: art.Git.InsecureIgnoreHostKey,
-		}
-		if art.Git.UsernameSecret != nil {
-			usernameBytes, err := we.GetSecretFromVolMount(art.Git.UsernameSecret.Name, art.Git.UsernameSecret.Key)
-			if err != nil {
-				return nil, err
-			}
-			gitDriver.Username = string(usernameBytes)
-		}
-		if art.Git.PasswordSecret != nil {
-			passwordBytes, err := we.GetSecretFromVolMount(art.Git.PasswordSecret.Name, art.Git.PasswordSecret.Key)
-			if err != nil {
-				return nil, err
-			}
-			gitDriver.Password = string(passwordBytes)
-		}
-		if art.Git.SSHPrivateKeySecret != nil {
-			sshPrivateKeyBytes, err := we.GetSecretFromVolMount(art.Git.SSHPrivateKeySecret.Name, art.Git.SSHPrivateKeySecret.Key)
-			if err != nil {
-				return nil, err
-			}
-			gitDriver.SSHPrivateKey = string(sshPrivateKeyBytes)
-		}
-
-		return &gitDriver, nil
-	}
-	if art.Artifactory != nil {
-		usernameBytes, err := we.GetSecretFromVolMount(art.Artifactory.UsernameSecret.Name, art.Artifactory.UsernameSecret.Key)
-		if err != nil {
-			return nil, err
-		}
-		passwordBytes, err := we.GetSecretFromVolMount(art.Artifactory.PasswordSecret.Name, art.Artifactory.PasswordSecret.Key)
-		if err != nil {
-			return nil, err
-		}
-		driver := artifactory.ArtifactoryArtifactDriver{
-			Username: string(usernameBytes),
-			Password: string(passwordBytes),
-		}
-		return &driver, nil
-
-	}
-	if art.HDFS != nil {
-		return hdfs.CreateDriver(we, art.HDFS)
-	}
-	if art.Raw != nil {
-		return &raw.RawArtifactDriver{}, nil
-	}
-
-	return nil, errors.Errorf(errors.CodeBadRequest, "Unsupported artifact driver for %s", art.Name)
+	return artifact.NewDriver(&art, we)
 }
 
 // getPod is a wrapper around the pod interface to get the current pod from kube API server
@@ -1,6 +1,9 @@
 package executor
 
 import (
+	"io/ioutil"
+	"os"
+	"path"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -104,3 +107,30 @@ func TestIsBaseImagePath(t *testing.T) {
 	assert.False(t, we.isBaseImagePath("/user-mount/some-path/foo"))
 	assert.True(t, we.isBaseImagePath("/user-mount-coincidence"))
 }
+
+// TestLoadAggregateArtifact verifies the artifacts of the items of a loop are loaded into a directory
+func TestLoadAggregateArtifact(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "aggregate")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	aggregate := wfv1.AggregateArtifact{
+		Artifacts: []wfv1.Artifact{
+			{Name: "0", ArtifactLocation: wfv1.ArtifactLocation{Raw: &wfv1.RawArtifact{Data: "sample-a"}}},
+			{Name: "2", ArtifactLocation: wfv1.ArtifactLocation{Raw: &wfv1.RawArtifact{Data: "sample-c"}}},
+		},
+	}
+	we := WorkflowExecutor{}
+	artPath := path.Join(tmpDir, "results")
+	err = we.loadAggregateArtifact(&aggregate, artPath)
+	assert.NoError(t, err)
+	files, err := ioutil.ReadDir(artPath)
+	if assert.NoError(t, err) && assert.Len(t, files, 2) {
+		assert.Equal(t, "0", files[0].Name())
+		assert.Equal(t, "2", files[1].Name())
+	}
+	data, err := ioutil.ReadFile(path.Join(artPath, "2"))
+	assert.NoError(t, err)
+	assert.Equal(t, "sample-c", string(data))
+}
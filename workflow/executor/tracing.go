@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/cyrusbiotechnology/argo/errors"
+	"github.com/cyrusbiotechnology/argo/workflow/common"
+	"github.com/cyrusbiotechnology/argo/workflow/tracing"
+)
+
+// tracingServiceName is the service name under which the executor reports its spans
+const tracingServiceName = "argoexec"
+
+// InitTracing sets up the export of the executor spans from the tracing configuration passed by
+// the controller. The spans are attached to the node span found in the pod annotations.
+func (we *WorkflowExecutor) InitTracing() error {
+	configStr, ok := os.LookupEnv(common.EnvVarTracingConfig)
+	if !ok {
+		return nil
+	}
+	var config tracing.Config
+	err := json.Unmarshal([]byte(configStr), &config)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	if !config.Enabled {
+		return nil
+	}
+	var carrier map[string]string
+	err = unmarshalAnnotationField(we.PodAnnotationsPath, common.AnnotationKeyTraceContext, &carrier)
+	if err != nil {
+		return err
+	}
+	exporter, err := tracing.NewExporter(config)
+	if err != nil {
+		return err
+	}
+	we.tracerProvider = tracing.NewTracerProvider(exporter, tracingServiceName)
+	we.traceParent = tracing.ExtractCarrier(carrier)
+	return nil
+}
+
+// FlushTraces exports the spans which have not been exported yet
+func (we *WorkflowExecutor) FlushTraces() {
+	if we.tracerProvider == nil {
+		return
+	}
+	err := we.tracerProvider.Shutdown(context.Background())
+	if err != nil {
+		log.Warnf("Failed to export spans: %v", err)
+	}
+}
+
+// startSpan starts a span as a child of the node span
+func (we *WorkflowExecutor) startSpan(name string, attrs ...tracing.Attribute) *tracing.Span {
+	return we.tracerProvider.StartChildSpan(name, we.traceParent, attrs...)
+}
@@ -78,6 +78,9 @@ func NewTelemetryRegistry() *prometheus.Registry {
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(prometheus.NewProcessCollector(os.Getpid(), ""))
 	registry.MustRegister(prometheus.NewGoCollector())
+	for _, collector := range controllerCollectors {
+		registry.MustRegister(collector)
+	}
 	return registry
 }
 
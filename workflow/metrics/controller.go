@@ -0,0 +1,210 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	controllerNamespace = "argo"
+	controllerSubsystem = "workflow_controller"
+)
+
+var (
+	workqueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: controllerNamespace,
+		Subsystem: controllerSubsystem,
+		Name:      "workqueue_depth",
+		Help:      "Current depth of the workqueue.",
+	}, []string{"queue"})
+	workqueueAdds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: controllerNamespace,
+		Subsystem: controllerSubsystem,
+		Name:      "workqueue_adds_total",
+		Help:      "Total number of adds handled by the workqueue.",
+	}, []string{"queue"})
+	workqueueLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: controllerNamespace,
+		Subsystem: controllerSubsystem,
+		Name:      "workqueue_queue_duration_seconds",
+		Help:      "How long in seconds an item stays in the workqueue before being requested.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"queue"})
+	workqueueWorkDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: controllerNamespace,
+		Subsystem: controllerSubsystem,
+		Name:      "workqueue_work_duration_seconds",
+		Help:      "How long in seconds processing an item from the workqueue takes.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"queue"})
+	workqueueRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: controllerNamespace,
+		Subsystem: controllerSubsystem,
+		Name:      "workqueue_retries_total",
+		Help:      "Total number of retries handled by the workqueue.",
+	}, []string{"queue"})
+
+	throttlerPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: controllerNamespace,
+		Subsystem: controllerSubsystem,
+		Name:      "throttler_pending",
+		Help:      "Number of workflows waiting for the throttler to admit them.",
+	})
+	throttlerInProgress = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: controllerNamespace,
+		Subsystem: controllerSubsystem,
+		Name:      "throttler_in_progress",
+		Help:      "Number of workflows admitted by the throttler and being processed.",
+	})
+
+	operationDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: controllerNamespace,
+		Subsystem: controllerSubsystem,
+		Name:      "operation_duration_seconds",
+		Help:      "Time in seconds spent in a single workflow operation.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+	persistConflicts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: controllerNamespace,
+		Subsystem: controllerSubsystem,
+		Name:      "persist_conflicts_total",
+		Help:      "Total number of workflow updates rejected by the API server because of a conflict.",
+	})
+	persistSizeLimitErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: controllerNamespace,
+		Subsystem: controllerSubsystem,
+		Name:      "persist_size_limit_errors_total",
+		Help:      "Total number of workflow updates rejected by the API server because the object was too large.",
+	})
+
+	dbSaveDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: controllerNamespace,
+		Subsystem: controllerSubsystem,
+		Name:      "db_save_duration_seconds",
+		Help:      "Time in seconds spent saving a workflow into the persistence database.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	})
+	dbSaveErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: controllerNamespace,
+		Subsystem: controllerSubsystem,
+		Name:      "db_save_errors_total",
+		Help:      "Total number of failed attempts to save a workflow into the persistence database.",
+	})
+
+	controllerCollectors = []prometheus.Collector{
+		workqueueDepth,
+		workqueueAdds,
+		workqueueLatency,
+		workqueueWorkDuration,
+		workqueueRetries,
+		throttlerPending,
+		throttlerInProgress,
+		operationDuration,
+		persistConflicts,
+		persistSizeLimitErrors,
+		dbSaveDuration,
+		dbSaveErrors,
+	}
+)
+
+func init() {
+	workqueue.SetProvider(workqueueMetricsProvider{})
+}
+
+// SetThrottlerCounts records the number of pending and in-progress workflows of the throttler
+func SetThrottlerCounts(pending, inProgress int) {
+	throttlerPending.Set(float64(pending))
+	throttlerInProgress.Set(float64(inProgress))
+}
+
+// ObserveOperationDuration records the time spent operating on a workflow since the given start time
+func ObserveOperationDuration(start time.Time) {
+	operationDuration.Observe(time.Since(start).Seconds())
+}
+
+// IncPersistConflict records a workflow update rejected because of a conflict
+func IncPersistConflict() {
+	persistConflicts.Inc()
+}
+
+// IncPersistSizeLimitError records a workflow update rejected because of the object size
+func IncPersistSizeLimitError() {
+	persistSizeLimitErrors.Inc()
+}
+
+// ObserveDBSave records the latency and the outcome of a workflow save into the persistence database
+func ObserveDBSave(start time.Time, err error) {
+	dbSaveDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		dbSaveErrors.Inc()
+	}
+}
+
+// workqueueMetricsProvider implements the workqueue.MetricsProvider interface so that named
+// workqueues report their metrics to the telemetry registry. Deprecated metrics are not exported.
+type workqueueMetricsProvider struct{}
+
+func (workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return workqueueDepth.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return workqueueAdds.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return workqueueLatency.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return workqueueWorkDuration.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+
+func (workqueueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+
+func (workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return workqueueRetries.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewDeprecatedDepthMetric(name string) workqueue.GaugeMetric {
+	return noopMetric{}
+}
+
+func (workqueueMetricsProvider) NewDeprecatedAddsMetric(name string) workqueue.CounterMetric {
+	return noopMetric{}
+}
+
+func (workqueueMetricsProvider) NewDeprecatedLatencyMetric(name string) workqueue.SummaryMetric {
+	return noopMetric{}
+}
+
+func (workqueueMetricsProvider) NewDeprecatedWorkDurationMetric(name string) workqueue.SummaryMetric {
+	return noopMetric{}
+}
+
+func (workqueueMetricsProvider) NewDeprecatedUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+
+func (workqueueMetricsProvider) NewDeprecatedLongestRunningProcessorMicrosecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+
+func (workqueueMetricsProvider) NewDeprecatedRetriesMetric(name string) workqueue.CounterMetric {
+	return noopMetric{}
+}
+
+type noopMetric struct{}
+
+func (noopMetric) Inc()            {}
+func (noopMetric) Dec()            {}
+func (noopMetric) Set(float64)     {}
+func (noopMetric) Observe(float64) {}
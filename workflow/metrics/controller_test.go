@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/util/workqueue"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func gatherFamilies(t *testing.T) map[string]*dto.MetricFamily {
+	families, err := NewTelemetryRegistry().Gather()
+	assert.NoError(t, err)
+	byName := make(map[string]*dto.MetricFamily)
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+	return byName
+}
+
+func findQueueMetric(family *dto.MetricFamily, queue string) *dto.Metric {
+	if family == nil {
+		return nil
+	}
+	for _, m := range family.Metric {
+		for _, label := range m.Label {
+			if label.GetName() == "queue" && label.GetValue() == queue {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// metricValue returns the value of a gauge or counter, or the sample count of a histogram, or 0
+// if the metric was not reported yet. The collectors are global, so the tests assert the change
+// of the values rather than the values themselves.
+func metricValue(m *dto.Metric) float64 {
+	switch {
+	case m == nil:
+		return 0
+	case m.Gauge != nil:
+		return m.Gauge.GetValue()
+	case m.Counter != nil:
+		return m.Counter.GetValue()
+	case m.Histogram != nil:
+		return float64(m.Histogram.GetSampleCount())
+	}
+	return 0
+}
+
+// queueMetricValues returns the value of the metrics of a workqueue
+func queueMetricValues(t *testing.T, queue string, names ...string) []float64 {
+	families := gatherFamilies(t)
+	values := make([]float64, len(names))
+	for i, name := range names {
+		values[i] = metricValue(findQueueMetric(families[name], queue))
+	}
+	return values
+}
+
+// controllerMetricValues returns the value of the metrics of the controller without labels
+func controllerMetricValues(t *testing.T, names ...string) []float64 {
+	families := gatherFamilies(t)
+	values := make([]float64, len(names))
+	for i, name := range names {
+		if family, ok := families[name]; ok && len(family.Metric) > 0 {
+			values[i] = metricValue(family.Metric[0])
+		}
+	}
+	return values
+}
+
+// TestWorkqueueMetrics verifies named workqueues report to the telemetry registry
+func TestWorkqueueMetrics(t *testing.T) {
+	names := []string{
+		"argo_workflow_controller_workqueue_depth",
+		"argo_workflow_controller_workqueue_adds_total",
+		"argo_workflow_controller_workqueue_queue_duration_seconds",
+	}
+	before := queueMetricValues(t, "test_queue", names...)
+	queue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "test_queue")
+	defer queue.ShutDown()
+	queue.Add("default/foo")
+	queue.Add("default/bar")
+
+	after := queueMetricValues(t, "test_queue", names...)
+	assert.Equal(t, float64(2), after[0]-before[0])
+	assert.Equal(t, float64(2), after[1]-before[1])
+
+	key, _ := queue.Get()
+	queue.Done(key)
+	after = queueMetricValues(t, "test_queue", names...)
+	assert.Equal(t, float64(1), after[0]-before[0])
+	assert.Equal(t, float64(1), after[2]-before[2])
+}
+
+// TestControllerMetrics verifies the throttler, operation and persistence metrics are exposed
+func TestControllerMetrics(t *testing.T) {
+	names := []string{
+		"argo_workflow_controller_operation_duration_seconds",
+		"argo_workflow_controller_persist_conflicts_total",
+		"argo_workflow_controller_persist_size_limit_errors_total",
+		"argo_workflow_controller_db_save_duration_seconds",
+		"argo_workflow_controller_db_save_errors_total",
+	}
+	before := controllerMetricValues(t, names...)
+	SetThrottlerCounts(3, 1)
+	ObserveOperationDuration(time.Now())
+	IncPersistConflict()
+	IncPersistSizeLimitError()
+	ObserveDBSave(time.Now(), nil)
+	ObserveDBSave(time.Now(), assert.AnError)
+
+	families := gatherFamilies(t)
+	assert.Equal(t, float64(3), families["argo_workflow_controller_throttler_pending"].Metric[0].GetGauge().GetValue())
+	assert.Equal(t, float64(1), families["argo_workflow_controller_throttler_in_progress"].Metric[0].GetGauge().GetValue())
+	after := controllerMetricValues(t, names...)
+	for i, delta := range []float64{1, 1, 1, 2, 1} {
+		assert.Equal(t, delta, after[i]-before[i], names[i])
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/cyrusbiotechnology/argo/errors"
 	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+	"github.com/cyrusbiotechnology/argo/workflow/metrics"
 )
 
 type (
@@ -66,14 +67,17 @@ func (wdc *WorkflowDBContext) Init(sess sqlbuilder.Database) {
 }
 
 // Save will upset the workflow
-func (wdc *WorkflowDBContext) Save(wf *wfv1.Workflow) error {
+func (wdc *WorkflowDBContext) Save(wf *wfv1.Workflow) (err error) {
+	defer func(start time.Time) {
+		metrics.ObserveDBSave(start, err)
+	}(time.Now())
 
 	if wdc != nil && wdc.Session == nil {
 		return DBInvalidSession(nil, "DB session is not initialized")
 	}
 	wfdb := convert(wf)
 
-	err := wdc.update(wfdb)
+	err = wdc.update(wfdb)
 
 	if err != nil {
 		if errors.IsCode(CodeDBUpdateRowNotFound, err) {
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/cyrusbiotechnology/argo/errors"
+)
+
+// stdoutExporter writes every span as a line of JSON
+type stdoutExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewStdoutExporter returns a span exporter writing the spans as JSON lines to w
+func NewStdoutExporter(w io.Writer) Exporter {
+	return &stdoutExporter{enc: json.NewEncoder(w)}
+}
+
+// ExportSpans implements the Exporter interface
+func (e *stdoutExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, span := range spans {
+		err := e.enc.Encode(span)
+		if err != nil {
+			return errors.InternalWrapError(err)
+		}
+	}
+	return nil
+}
+
+// Shutdown implements the Exporter interface
+func (e *stdoutExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// InMemoryExporter keeps the exported spans in memory. It is meant for tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// NewInMemoryExporter returns an empty in-memory exporter
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// ExportSpans implements the Exporter interface
+func (e *InMemoryExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Shutdown implements the Exporter interface
+func (e *InMemoryExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// GetSpans returns the spans exported so far
+func (e *InMemoryExporter) GetSpans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset forgets the spans exported so far
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
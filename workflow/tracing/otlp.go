@@ -0,0 +1,185 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cyrusbiotechnology/argo/errors"
+)
+
+const (
+	// otlpTracesPath is the path of the OTLP/HTTP traces endpoint
+	otlpTracesPath = "/v1/traces"
+
+	// otlpSpanKindInternal is the OTLP kind of all the workflow spans
+	otlpSpanKindInternal = 1
+
+	otlpStatusCodeOk    = 1
+	otlpStatusCodeError = 2
+)
+
+// otlpExporter exports spans to an OpenTelemetry collector using the JSON encoding of OTLP/HTTP.
+// The JSON encoding is used instead of the protobuf one to avoid pulling in a gRPC version
+// incompatible with the one required by the Kubernetes client.
+type otlpExporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewOTLPExporter returns a span exporter sending spans to the OTLP/HTTP collector at endpoint
+func NewOTLPExporter(endpoint string, insecure bool, headers map[string]string) Exporter {
+	scheme := "https"
+	if insecure {
+		scheme = "http"
+	}
+	return &otlpExporter{
+		url:     fmt.Sprintf("%s://%s%s", scheme, endpoint, otlpTracesPath),
+		headers: headers,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ExportSpans implements the Exporter interface
+func (e *otlpExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(newOTLPRequest(spans))
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return errors.InternalWrapError(err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.InternalErrorf("failed to export spans to %s: %s %s", e.url, resp.Status, string(msg))
+	}
+	return nil
+}
+
+// Shutdown implements the Exporter interface
+func (e *otlpExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+}
+
+// newOTLPRequest groups the spans by service
+func newOTLPRequest(spans []SpanData) otlpRequest {
+	var req otlpRequest
+	resourceIndex := make(map[string]int)
+	for _, span := range spans {
+		ri, ok := resourceIndex[span.ServiceName]
+		if !ok {
+			ri = len(req.ResourceSpans)
+			resourceIndex[span.ServiceName] = ri
+			req.ResourceSpans = append(req.ResourceSpans, otlpResourceSpans{
+				Resource:   otlpResource{Attributes: newOTLPAttributes([]Attribute{String("service.name", span.ServiceName)})},
+				ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: InstrumentationName}}},
+			})
+		}
+		req.ResourceSpans[ri].ScopeSpans[0].Spans = append(req.ResourceSpans[ri].ScopeSpans[0].Spans, newOTLPSpan(span))
+	}
+	return req
+}
+
+func newOTLPSpan(span SpanData) otlpSpan {
+	s := otlpSpan{
+		TraceID:           span.SpanContext.TraceID.String(),
+		SpanID:            span.SpanContext.SpanID.String(),
+		Name:              span.Name,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+		Attributes:        newOTLPAttributes(span.Attributes),
+	}
+	if span.Parent.IsValid() {
+		s.ParentSpanID = span.Parent.SpanID.String()
+	}
+	switch span.StatusCode {
+	case StatusOk:
+		s.Status.Code = otlpStatusCodeOk
+	case StatusError:
+		s.Status.Code = otlpStatusCodeError
+		s.Status.Message = span.StatusMessage
+	}
+	return s
+}
+
+func newOTLPAttributes(attrs []Attribute) []otlpKeyValue {
+	var kvs []otlpKeyValue
+	for _, attr := range attrs {
+		v := attr.Value
+		kvs = append(kvs, otlpKeyValue{Key: attr.Key, Value: otlpValue{StringValue: &v}})
+	}
+	return kvs
+}
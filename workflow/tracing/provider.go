@@ -0,0 +1,226 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// batchTimeout is the maximum time a span waits in the queue before being exported
+	batchTimeout = 5 * time.Second
+	// maxBatchSize is the number of queued spans which triggers an export
+	maxBatchSize = 512
+)
+
+// StatusCode is the status of a span
+type StatusCode int
+
+const (
+	// StatusUnset is the status of spans whose outcome is unknown
+	StatusUnset StatusCode = iota
+	// StatusOk is the status of spans whose operation succeeded
+	StatusOk
+	// StatusError is the status of spans whose operation failed
+	StatusError
+)
+
+// Attribute is a key value pair describing a span
+type Attribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// String returns a string attribute
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData is an ended span, as handed to the exporters
+type SpanData struct {
+	Name          string      `json:"name"`
+	ServiceName   string      `json:"serviceName"`
+	SpanContext   SpanContext `json:"spanContext"`
+	Parent        SpanContext `json:"parent"`
+	StartTime     time.Time   `json:"startTime"`
+	EndTime       time.Time   `json:"endTime"`
+	Attributes    []Attribute `json:"attributes,omitempty"`
+	StatusCode    StatusCode  `json:"statusCode,omitempty"`
+	StatusMessage string      `json:"statusMessage,omitempty"`
+}
+
+// Exporter sends ended spans to a tracing backend
+type Exporter interface {
+	// ExportSpans exports a batch of spans
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	// Shutdown releases the resources of the exporter
+	Shutdown(ctx context.Context) error
+}
+
+// Span is a span being recorded. All methods of a nil span are no-ops, which is what the
+// methods of a nil tracer provider return when tracing is disabled.
+type Span struct {
+	provider *TracerProvider
+	data     SpanData
+}
+
+// SpanContext returns the identity of the span
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// SetStatus sets the status of the span
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.data.StatusCode = code
+	s.data.StatusMessage = ""
+	if code == StatusError {
+		s.data.StatusMessage = message
+	}
+}
+
+// End ends the span now and queues it for export
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt ends the span at the given time and queues it for export
+func (s *Span) EndAt(endTime time.Time) {
+	if s == nil {
+		return
+	}
+	s.data.EndTime = endTime
+	s.provider.enqueue(s.data)
+}
+
+// TracerProvider starts spans and exports them in batches
+type TracerProvider struct {
+	exporter    Exporter
+	serviceName string
+
+	mu       sync.Mutex
+	queue    []SpanData
+	shutdown bool
+
+	flushCh chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+}
+
+// NewTracerProvider returns a tracer provider which sends the spans to the given exporter.
+// Spans started with StartSpan keep the identity they were given, so that spans emitted by
+// separate operations of the controller and by the executor end up in the same trace.
+func NewTracerProvider(exporter Exporter, serviceName string) *TracerProvider {
+	p := &TracerProvider{
+		exporter:    exporter,
+		serviceName: serviceName,
+		flushCh:     make(chan struct{}, 1),
+		stopCh:      make(chan struct{}),
+		doneCh:      make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// StartSpan starts a span with the identity given by sc as a child of parent. If parent is not
+// valid the span is the root of the trace of sc. A zero start time means now.
+func (p *TracerProvider) StartSpan(name string, sc SpanContext, parent SpanContext, startTime time.Time, attrs ...Attribute) *Span {
+	if p == nil {
+		return nil
+	}
+	if startTime.IsZero() {
+		startTime = time.Now()
+	}
+	return &Span{
+		provider: p,
+		data: SpanData{
+			Name:        name,
+			ServiceName: p.serviceName,
+			SpanContext: sc,
+			Parent:      parent,
+			StartTime:   startTime,
+			Attributes:  attrs,
+		},
+	}
+}
+
+// StartChildSpan starts a span with a new identity as a child of parent
+func (p *TracerProvider) StartChildSpan(name string, parent SpanContext, attrs ...Attribute) *Span {
+	if p == nil {
+		return nil
+	}
+	sc := SpanContext{TraceID: parent.TraceID, SpanID: randomSpanID()}
+	return p.StartSpan(name, sc, parent, time.Time{}, attrs...)
+}
+
+// ForceFlush exports all the ended spans which have not been exported yet
+func (p *TracerProvider) ForceFlush(ctx context.Context) error {
+	p.mu.Lock()
+	spans := p.queue
+	p.queue = nil
+	p.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	return p.exporter.ExportSpans(ctx, spans)
+}
+
+// Shutdown exports the remaining spans and shuts the exporter down. Spans ended afterwards are
+// dropped.
+func (p *TracerProvider) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if p.shutdown {
+		p.mu.Unlock()
+		return nil
+	}
+	p.shutdown = true
+	p.mu.Unlock()
+	close(p.stopCh)
+	<-p.doneCh
+	err := p.ForceFlush(ctx)
+	if err != nil {
+		return err
+	}
+	return p.exporter.Shutdown(ctx)
+}
+
+func (p *TracerProvider) enqueue(span SpanData) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.shutdown {
+		return
+	}
+	p.queue = append(p.queue, span)
+	if len(p.queue) >= maxBatchSize {
+		select {
+		case p.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run exports the queued spans periodically, or as soon as a batch is full
+func (p *TracerProvider) run() {
+	defer close(p.doneCh)
+	ticker := time.NewTicker(batchTimeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.flushCh:
+		case <-p.stopCh:
+			return
+		}
+		err := p.ForceFlush(context.Background())
+		if err != nil {
+			log.Warnf("Failed to export spans: %v", err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/cyrusbiotechnology/argo/errors"
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+)
+
+const (
+	// ExporterOTLP exports spans to an OpenTelemetry collector using OTLP over HTTP
+	ExporterOTLP = "otlp"
+	// ExporterStdout writes spans to the standard output
+	ExporterStdout = "stdout"
+
+	// InstrumentationName is the name of the instrumentation scope of the workflow spans
+	InstrumentationName = "github.com/cyrusbiotechnology/argo"
+
+	// traceParentHeader is the W3C trace context header holding the span context
+	traceParentHeader = "traceparent"
+)
+
+// Config defines the configuration of workflow tracing
+type Config struct {
+	// Enabled turns on the emission of spans
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Exporter is the span exporter to use. One of: otlp|stdout. Defaults to otlp
+	Exporter string `json:"exporter,omitempty"`
+
+	// Endpoint is the host:port of the OTLP/HTTP collector (e.g. otel-collector:4318)
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Insecure sends spans to the OTLP collector over plain HTTP instead of HTTPS
+	Insecure bool `json:"insecure,omitempty"`
+
+	// Headers are additional HTTP headers sent to the OTLP collector. They are not passed to the
+	// executors, whose spans are exported without them, since they usually hold credentials
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// IsValid returns whether the trace ID is set
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// String returns the hex encoding of the trace ID
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (t TraceID) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// IsValid returns whether the span ID is set
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// String returns the hex encoding of the span ID
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (s SpanID) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// SpanContext is the identity of a span
+type SpanContext struct {
+	TraceID TraceID `json:"traceId"`
+	SpanID  SpanID  `json:"spanId"`
+}
+
+// IsValid returns whether both the trace and span IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// NewExporter returns the span exporter described by the config
+func NewExporter(config Config) (Exporter, error) {
+	switch config.Exporter {
+	case "", ExporterOTLP:
+		if config.Endpoint == "" {
+			return nil, errors.Errorf(errors.CodeBadRequest, "tracing endpoint is required for the %s exporter", ExporterOTLP)
+		}
+		return NewOTLPExporter(config.Endpoint, config.Insecure, config.Headers), nil
+	case ExporterStdout:
+		return NewStdoutExporter(os.Stdout), nil
+	default:
+		return nil, errors.Errorf(errors.CodeBadRequest, "unsupported tracing exporter '%s'", config.Exporter)
+	}
+}
+
+// WorkflowSpanContext returns the span context of the root span of a workflow
+func WorkflowSpanContext(wf *wfv1.Workflow) SpanContext {
+	return SpanContext{TraceID: workflowTraceID(wf), SpanID: spanID("workflow/" + workflowKey(wf))}
+}
+
+// NodeSpanContext returns the span context of the span of a workflow node
+func NodeSpanContext(wf *wfv1.Workflow, nodeID string) SpanContext {
+	return SpanContext{TraceID: workflowTraceID(wf), SpanID: spanID("node/" + nodeID)}
+}
+
+// SetSpanPhase sets the status of a span from the phase of a node or workflow
+func SetSpanPhase(span *Span, phase wfv1.NodePhase, message string) {
+	switch phase {
+	case wfv1.NodeSucceeded, wfv1.NodeSkipped:
+		span.SetStatus(StatusOk, "")
+	case wfv1.NodeFailed, wfv1.NodeError:
+		span.SetStatus(StatusError, message)
+	}
+}
+
+// EndSpan ends a span, recording the error of the traced operation if any
+func EndSpan(span *Span, err error) {
+	if err != nil {
+		span.SetStatus(StatusError, err.Error())
+	}
+	span.End()
+}
+
+// InjectAnnotation serializes the span context into a pod annotation value, which holds the
+// W3C trace context headers as a JSON object
+func InjectAnnotation(sc SpanContext) (string, error) {
+	carrier := map[string]string{
+		traceParentHeader: fmt.Sprintf("00-%s-%s-01", sc.TraceID, sc.SpanID),
+	}
+	annotation, err := json.Marshal(carrier)
+	if err != nil {
+		return "", errors.InternalWrapError(err)
+	}
+	return string(annotation), nil
+}
+
+// ExtractCarrier returns the span context serialized by InjectAnnotation. The span context is
+// invalid if the carrier does not hold a valid traceparent header.
+func ExtractCarrier(carrier map[string]string) SpanContext {
+	var sc SpanContext
+	parts := strings.Split(carrier[traceParentHeader], "-")
+	if len(parts) != 4 || parts[0] == "ff" || len(parts[0]) != 2 {
+		return SpanContext{}
+	}
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil || len(traceID) != len(sc.TraceID) {
+		return SpanContext{}
+	}
+	spanID, err := hex.DecodeString(parts[2])
+	if err != nil || len(spanID) != len(sc.SpanID) {
+		return SpanContext{}
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	return sc
+}
+
+// ExtractAnnotation returns the span context of a pod annotation value
+func ExtractAnnotation(annotation string) (SpanContext, error) {
+	var carrier map[string]string
+	err := json.Unmarshal([]byte(annotation), &carrier)
+	if err != nil {
+		return SpanContext{}, errors.InternalWrapError(err)
+	}
+	return ExtractCarrier(carrier), nil
+}
+
+// workflowKey identifies a workflow. The UID is preferred so that re-submitted workflows
+// with the same name get a distinct trace.
+func workflowKey(wf *wfv1.Workflow) string {
+	if wf.UID != "" {
+		return string(wf.UID)
+	}
+	return wf.Namespace + "/" + wf.Name
+}
+
+func workflowTraceID(wf *wfv1.Workflow) TraceID {
+	var traceID TraceID
+	sum := sha256.Sum256([]byte(workflowKey(wf)))
+	copy(traceID[:], sum[:len(traceID)])
+	return traceID
+}
+
+func spanID(key string) SpanID {
+	var spanID SpanID
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	copy(spanID[:], h.Sum(nil))
+	return spanID
+}
+
+// randomSpanID returns a span ID for spans which are not given an identity
+func randomSpanID() SpanID {
+	var spanID SpanID
+	_, err := rand.Read(spanID[:])
+	if err != nil {
+		log.Warnf("Failed to generate random span identifier: %v", err)
+	}
+	return spanID
+}
@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	wfv1 "github.com/cyrusbiotechnology/argo/pkg/apis/workflow/v1alpha1"
+)
+
+func newWorkflow(uid string) *wfv1.Workflow {
+	return &wfv1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello-world", Namespace: "default", UID: types.UID(uid)},
+	}
+}
+
+func TestSpanContexts(t *testing.T) {
+	wf := newWorkflow("1")
+	root := WorkflowSpanContext(wf)
+	node := NodeSpanContext(wf, "hello-world-123")
+	assert.True(t, root.IsValid())
+	assert.True(t, node.IsValid())
+	assert.Equal(t, root.TraceID, node.TraceID)
+	assert.NotEqual(t, root.SpanID, node.SpanID)
+	// identifiers are stable across calls
+	assert.Equal(t, node, NodeSpanContext(wf, "hello-world-123"))
+	// distinct workflows get distinct traces
+	assert.NotEqual(t, root.TraceID, WorkflowSpanContext(newWorkflow("2")).TraceID)
+}
+
+func TestStartSpan(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	provider := NewTracerProvider(exporter, "test")
+	wf := newWorkflow("1")
+	root := WorkflowSpanContext(wf)
+	node := NodeSpanContext(wf, "hello-world-123")
+
+	startTime := time.Now().Add(-time.Minute)
+	provider.StartSpan("hello-world", root, SpanContext{}, startTime).End()
+	span := provider.StartSpan("node", node, root, time.Time{}, String("argo.node.id", "hello-world-123"))
+	provider.StartChildSpan("child", span.SpanContext()).End()
+	span.End()
+	assert.NoError(t, provider.ForceFlush(context.Background()))
+
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 3) {
+		assert.Equal(t, root, spans[0].SpanContext)
+		assert.False(t, spans[0].Parent.IsValid())
+		assert.True(t, startTime.Equal(spans[0].StartTime))
+		assert.Equal(t, "test", spans[0].ServiceName)
+		// the requested identity is not reused for children of the span
+		assert.Equal(t, node.TraceID, spans[1].SpanContext.TraceID)
+		assert.NotEqual(t, node.SpanID, spans[1].SpanContext.SpanID)
+		assert.Equal(t, node.SpanID, spans[1].Parent.SpanID)
+		assert.Equal(t, node, spans[2].SpanContext)
+		assert.Equal(t, root, spans[2].Parent)
+		assert.Equal(t, []Attribute{String("argo.node.id", "hello-world-123")}, spans[2].Attributes)
+	}
+
+	// spans ended after the shutdown are dropped
+	assert.NoError(t, provider.Shutdown(context.Background()))
+	provider.StartSpan("late", root, SpanContext{}, time.Time{}).End()
+	assert.NoError(t, provider.ForceFlush(context.Background()))
+	assert.Len(t, exporter.GetSpans(), 3)
+}
+
+func TestDisabledTracing(t *testing.T) {
+	var provider *TracerProvider
+	span := provider.StartSpan("hello-world", WorkflowSpanContext(newWorkflow("1")), SpanContext{}, time.Time{})
+	assert.Nil(t, span)
+	SetSpanPhase(span, wfv1.NodeFailed, "failed")
+	EndSpan(span, nil)
+	assert.False(t, span.SpanContext().IsValid())
+}
+
+func TestAnnotation(t *testing.T) {
+	sc := NodeSpanContext(newWorkflow("1"), "hello-world-123")
+	annotation, err := InjectAnnotation(sc)
+	assert.NoError(t, err)
+	assert.Contains(t, annotation, "traceparent")
+	extracted, err := ExtractAnnotation(annotation)
+	assert.NoError(t, err)
+	assert.Equal(t, sc, extracted)
+
+	_, err = ExtractAnnotation("not json")
+	assert.Error(t, err)
+	assert.False(t, ExtractCarrier(map[string]string{"traceparent": "00-abc-def-01"}).IsValid())
+	assert.False(t, ExtractCarrier(nil).IsValid())
+}
+
+func TestNewExporter(t *testing.T) {
+	_, err := NewExporter(Config{Enabled: true, Exporter: ExporterStdout})
+	assert.NoError(t, err)
+	_, err = NewExporter(Config{Enabled: true})
+	assert.Error(t, err)
+	_, err = NewExporter(Config{Enabled: true, Exporter: "zipkin"})
+	assert.Error(t, err)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body []byte
+	var path, header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		header = r.Header.Get("X-Tenant")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(strings.TrimPrefix(server.URL, "http://"), true, map[string]string{"X-Tenant": "argo"})
+	provider := NewTracerProvider(exporter, "test")
+	wf := newWorkflow("1")
+	span := provider.StartSpan("hello-world", WorkflowSpanContext(wf), SpanContext{}, time.Time{})
+	SetSpanPhase(span, wfv1.NodeFailed, "failed with exit code 1")
+	span.End()
+	assert.NoError(t, provider.Shutdown(context.Background()))
+
+	assert.Equal(t, otlpTracesPath, path)
+	assert.Equal(t, "argo", header)
+	var req otlpRequest
+	assert.NoError(t, json.Unmarshal(body, &req))
+	if assert.Len(t, req.ResourceSpans, 1) && assert.Len(t, req.ResourceSpans[0].ScopeSpans, 1) {
+		assert.Equal(t, InstrumentationName, req.ResourceSpans[0].ScopeSpans[0].Scope.Name)
+		spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, WorkflowSpanContext(wf).TraceID.String(), spans[0].TraceID)
+			assert.Equal(t, WorkflowSpanContext(wf).SpanID.String(), spans[0].SpanID)
+			assert.Equal(t, "hello-world", spans[0].Name)
+			assert.Equal(t, 2, spans[0].Status.Code)
+			assert.Equal(t, "failed with exit code 1", spans[0].Status.Message)
+		}
+	}
+}
@@ -316,23 +316,69 @@ func SuspendWorkflow(wfIf v1alpha1.WorkflowInterface, workflowName string) error
 // ResumeWorkflow resumes a workflow by setting spec.suspend to nil and any suspended nodes to Successful.
 // Retries conflict errors
 func ResumeWorkflow(wfIf v1alpha1.WorkflowInterface, workflowName string) error {
+	return ResumeWorkflowNodes(wfIf, workflowName, ResumeOpts{})
+}
+
+// ResumeOpts are options to resume a workflow
+type ResumeOpts struct {
+	NodeName   string   // resume only the suspended nodes with this name, display name or ID
+	Parameters []string // output parameters of the resumed nodes, in the form NAME=VALUE
+}
+
+// ResumeWorkflowNodes resumes the suspended nodes of a workflow selected by opts.NodeName, or all the
+// suspended nodes and the workflow itself if it is empty. The output parameters of the resumed nodes
+// are set from opts.Parameters, or their default value. Retries conflict errors
+func ResumeWorkflowNodes(wfIf v1alpha1.WorkflowInterface, workflowName string, opts ResumeOpts) error {
+	params := make(map[string]string)
+	for _, paramStr := range opts.Parameters {
+		parts := strings.SplitN(paramStr, "=", 2)
+		if len(parts) == 1 {
+			return fmt.Errorf("Expected parameter of the form: NAME=VALUE. Received: %s", paramStr)
+		}
+		params[parts[0]] = parts[1]
+	}
 	err := wait.ExponentialBackoff(retry.DefaultRetry, func() (bool, error) {
 		wf, err := wfIf.Get(workflowName, metav1.GetOptions{})
 		if err != nil {
 			return false, err
 		}
 		updated := false
-		if wf.Spec.Suspend != nil && *wf.Spec.Suspend {
+		if opts.NodeName == "" && wf.Spec.Suspend != nil && *wf.Spec.Suspend {
 			wf.Spec.Suspend = nil
 			updated = true
 		}
 		// To resume a workflow with a suspended node we simply mark the node as Successful
+		usedParams := make(map[string]bool)
+		resumedNodes := 0
 		for nodeID, node := range wf.Status.Nodes {
-			if node.Type == wfv1.NodeTypeSuspend && node.Phase == wfv1.NodeRunning {
-				node.Phase = wfv1.NodeSucceeded
-				node.FinishedAt = metav1.Time{Time: time.Now().UTC()}
-				wf.Status.Nodes[nodeID] = node
-				updated = true
+			// approval nodes are only completed by the decisions of their approvers
+			if node.Type != wfv1.NodeTypeSuspend || node.Phase != wfv1.NodeRunning || node.Approval != nil {
+				continue
+			}
+			if opts.NodeName != "" && opts.NodeName != node.Name && opts.NodeName != node.DisplayName && opts.NodeName != node.ID {
+				continue
+			}
+			err = SetSuspendNodeOutputs(&node, params)
+			if err != nil {
+				return false, err
+			}
+			if node.Outputs != nil {
+				for _, param := range node.Outputs.Parameters {
+					usedParams[param.Name] = true
+				}
+			}
+			node.Phase = wfv1.NodeSucceeded
+			node.FinishedAt = metav1.Time{Time: time.Now().UTC()}
+			wf.Status.Nodes[nodeID] = node
+			resumedNodes++
+			updated = true
+		}
+		if opts.NodeName != "" && resumedNodes == 0 {
+			return false, errors.Errorf(errors.CodeNotFound, "no suspended node '%s' in workflow %s", opts.NodeName, workflowName)
+		}
+		for name := range params {
+			if !usedParams[name] {
+				return false, errors.Errorf(errors.CodeBadRequest, "parameter '%s' is not an output parameter of the resumed nodes", name)
 			}
 		}
 		if updated {
@@ -349,6 +395,95 @@ func ResumeWorkflow(wfIf v1alpha1.WorkflowInterface, workflowName string) error
 	return err
 }
 
+// SetSuspendNodeOutputs sets the output parameters of a suspend node from the supplied values, or
+// from their default value if they were not supplied
+func SetSuspendNodeOutputs(node *wfv1.NodeStatus, params map[string]string) error {
+	if node.Outputs == nil {
+		return nil
+	}
+	for i, param := range node.Outputs.Parameters {
+		if value, ok := params[param.Name]; ok {
+			node.Outputs.Parameters[i].Value = &value
+			continue
+		}
+		if param.Value != nil {
+			continue
+		}
+		if param.Default == nil {
+			return errors.Errorf(errors.CodeBadRequest, "output parameter '%s' of node %s was not supplied", param.Name, node.DisplayName)
+		}
+		value := *param.Default
+		node.Outputs.Parameters[i].Value = &value
+	}
+	return nil
+}
+
+// ApprovalOpts are options to decide on an approval node of a workflow
+type ApprovalOpts struct {
+	NodeName string                // name, display name or ID of the approval node, optional if the workflow awaits a single approval
+	Approver string                // name of the approver, as declared by the client
+	Decision wfv1.ApprovalDecision // decision of the approver
+	Comment  string                // optional comment of the approver
+}
+
+// DecideWorkflowApproval records the decision of an approver in the status of an approval node awaiting
+// approval. The node itself is completed by the controller. Retries conflict errors
+func DecideWorkflowApproval(wfIf v1alpha1.WorkflowInterface, workflowName string, opts ApprovalOpts) error {
+	if opts.Approver == "" {
+		return errors.Errorf(errors.CodeBadRequest, "approver must be specified")
+	}
+	err := wait.ExponentialBackoff(retry.DefaultRetry, func() (bool, error) {
+		wf, err := wfIf.Get(workflowName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		var pending []wfv1.NodeStatus
+		for _, node := range wf.Status.Nodes {
+			if node.Approval == nil || node.Phase != wfv1.NodeRunning {
+				continue
+			}
+			if opts.NodeName != "" && opts.NodeName != node.Name && opts.NodeName != node.DisplayName && opts.NodeName != node.ID {
+				continue
+			}
+			pending = append(pending, node)
+		}
+		if len(pending) == 0 {
+			if opts.NodeName != "" {
+				return false, errors.Errorf(errors.CodeNotFound, "no node '%s' awaiting approval in workflow %s", opts.NodeName, workflowName)
+			}
+			return false, errors.Errorf(errors.CodeNotFound, "no node awaiting approval in workflow %s", workflowName)
+		}
+		if len(pending) > 1 {
+			return false, errors.Errorf(errors.CodeBadRequest, "%d nodes are awaiting approval in workflow %s, the node must be specified", len(pending), workflowName)
+		}
+		node := pending[0]
+		if !node.Approval.IsAllowed(opts.Approver) {
+			return false, errors.Errorf(errors.CodeForbidden, "%s is not allowed to approve node %s", opts.Approver, node.Name)
+		}
+		for _, record := range node.Approval.Decisions {
+			if record.Approver == opts.Approver {
+				return false, errors.Errorf(errors.CodeBadRequest, "%s already decided on node %s", opts.Approver, node.Name)
+			}
+		}
+		node.Approval.Decisions = append(node.Approval.Decisions, wfv1.ApprovalRecord{
+			Approver: opts.Approver,
+			Decision: opts.Decision,
+			Comment:  opts.Comment,
+			Time:     metav1.Time{Time: time.Now().UTC()},
+		})
+		wf.Status.Nodes[node.ID] = node
+		_, err = wfIf.Update(wf)
+		if err != nil {
+			if apierr.IsConflict(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	})
+	return err
+}
+
 const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
 
 func init() {
@@ -393,6 +528,9 @@ func FormulateResubmitWorkflow(wf *wfv1.Workflow, memoized bool) (*wfv1.Workflow
 		// if it was terminated, unset the deadline
 		newWF.Spec.ActiveDeadlineSeconds = nil
 	}
+	// if it was stopped or terminated, unset the shutdown strategy
+	newWF.Spec.Shutdown = ""
+	newWF.Spec.ShutdownGracePeriodSeconds = nil
 
 	// carry over user labels and annotations from previous workflow.
 	// skip any argoproj.io labels except for the controller instanceID label.
@@ -496,6 +634,9 @@ func RetryWorkflow(kubeClient kubernetes.Interface, wfClient v1alpha1.WorkflowIn
 		// if it was terminated, unset the deadline
 		newWF.Spec.ActiveDeadlineSeconds = nil
 	}
+	// if it was stopped or terminated, unset the shutdown strategy
+	newWF.Spec.Shutdown = ""
+	newWF.Spec.ShutdownGracePeriodSeconds = nil
 
 	// Iterate the previous nodes. If it was successful Pod carry it forward
 	newWF.Status.Nodes = make(map[string]wfv1.NodeStatus)
@@ -565,15 +706,38 @@ func IsWorkflowTerminated(wf *wfv1.Workflow) bool {
 	if wf.Spec.ActiveDeadlineSeconds != nil && *wf.Spec.ActiveDeadlineSeconds == 0 {
 		return true
 	}
-	return false
+	return wf.Spec.Shutdown == wfv1.ShutdownStrategyTerminate
+}
+
+// IsWorkflowStopped returns whether or not a workflow is considered stopped
+func IsWorkflowStopped(wf *wfv1.Workflow) bool {
+	return wf.Spec.Shutdown == wfv1.ShutdownStrategyStop && !IsWorkflowTerminated(wf)
 }
 
 // TerminateWorkflow terminates a workflow by setting its activeDeadlineSeconds to 0
 func TerminateWorkflow(wfClient v1alpha1.WorkflowInterface, name string) error {
+	return patchWorkflowSpec(wfClient, name, map[string]interface{}{
+		"activeDeadlineSeconds": 0,
+		"shutdown":              wfv1.ShutdownStrategyTerminate,
+	})
+}
+
+// StopWorkflow stops a workflow by setting its shutdown strategy to Stop. No new node is
+// scheduled, but the exit handler is run. Running pods are sent SIGTERM after gracePeriod if set.
+func StopWorkflow(wfClient v1alpha1.WorkflowInterface, name string, gracePeriod *time.Duration) error {
+	spec := map[string]interface{}{
+		"shutdown": wfv1.ShutdownStrategyStop,
+	}
+	if gracePeriod != nil {
+		spec["shutdownGracePeriodSeconds"] = int64(gracePeriod.Seconds())
+	}
+	return patchWorkflowSpec(wfClient, name, spec)
+}
+
+// patchWorkflowSpec merge patches the spec of a workflow, retrying on conflicts
+func patchWorkflowSpec(wfClient v1alpha1.WorkflowInterface, name string, spec map[string]interface{}) error {
 	patchObj := map[string]interface{}{
-		"spec": map[string]interface{}{
-			"activeDeadlineSeconds": 0,
-		},
+		"spec": spec,
 	}
 	var err error
 	patch, err := json.Marshal(patchObj)
@@ -740,9 +904,10 @@ type WorkflowCost struct {
 	TemplateCosts []TemplateDuration
 }
 
-func getTemplateRuntimes(wf *wfv1.Workflow) []TemplateDuration {
+func getTemplateRuntimes(wf *wfv1.Workflow, cpuCost float64) []TemplateDuration {
 
 	perTemplateTotals := map[string]time.Duration{}
+	perTemplateCosts := map[string]float64{}
 
 	for _, node := range wf.Status.Nodes {
 		if node.Type != wfv1.NodeTypePod {
@@ -762,6 +927,7 @@ func getTemplateRuntimes(wf *wfv1.Workflow) []TemplateDuration {
 		} else {
 			perTemplateTotals[node.TemplateName] = duration
 		}
+		perTemplateCosts[node.TemplateName] += duration.Hours() * getNodeCPUs(node) * cpuCost
 
 	}
 
@@ -771,6 +937,7 @@ func getTemplateRuntimes(wf *wfv1.Workflow) []TemplateDuration {
 		templateDurations = append(templateDurations, TemplateDuration{
 			Name:     templateName,
 			Duration: duration,
+			Cost:     perTemplateCosts[templateName],
 		})
 	}
 
@@ -781,8 +948,21 @@ func getTemplateRuntimes(wf *wfv1.Workflow) []TemplateDuration {
 	return templateDurations
 }
 
+// getNodeCPUs returns the number of CPUs accounted for a pod node, which is the CPU request, or
+// limit, of its effective resources. Nodes without CPU resources account for one CPU.
+func getNodeCPUs(node wfv1.NodeStatus) float64 {
+	if node.Resources != nil {
+		for _, list := range []apiv1.ResourceList{node.Resources.Requests, node.Resources.Limits} {
+			if cpu, ok := list[apiv1.ResourceCPU]; ok {
+				return float64(cpu.MilliValue()) / 1000
+			}
+		}
+	}
+	return 1
+}
+
 func ComputeWorkflowCost(wf *wfv1.Workflow, cpuCost float64) WorkflowCost {
-	templateRuntimes := getTemplateRuntimes(wf)
+	templateRuntimes := getTemplateRuntimes(wf, cpuCost)
 
 	workflowCost := WorkflowCost{
 		TotalCost:     0.0,
@@ -791,11 +971,8 @@ func ComputeWorkflowCost(wf *wfv1.Workflow, cpuCost float64) WorkflowCost {
 	}
 
 	for _, templateDuration := range templateRuntimes {
-		templateCost := templateDuration.Duration.Hours() * cpuCost
 		workflowCost.TotalDuration += templateDuration.Duration
-		workflowCost.TotalCost += templateCost
-
-		templateDuration.Cost = templateCost
+		workflowCost.TotalCost += templateDuration.Cost
 		workflowCost.TemplateCosts = append(workflowCost.TemplateCosts, templateDuration)
 	}
 	return workflowCost
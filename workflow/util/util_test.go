@@ -13,6 +13,7 @@ import (
 	"github.com/ghodss/yaml"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -289,3 +290,38 @@ func TestComputeWorkflowCost(t *testing.T) {
 	assert.Equal(t, expected.TemplateCosts[0], result.TemplateCosts[0])
 	assert.Equal(t, expected.TemplateCosts[1], result.TemplateCosts[1])
 }
+
+func TestComputeWorkflowCostWithResources(t *testing.T) {
+	startTime := time.Now()
+	wf := wfv1.Workflow{
+		Status: wfv1.WorkflowStatus{
+			Nodes: map[string]wfv1.NodeStatus{
+				"step-1": {
+					Type:         wfv1.NodeTypePod,
+					TemplateName: "step-1-template",
+					Phase:        wfv1.NodeSucceeded,
+					StartedAt:    metav1.Time{Time: startTime},
+					FinishedAt:   metav1.Time{Time: startTime.Add(time.Hour)},
+					Resources: &v1.ResourceRequirements{
+						Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")},
+					},
+				},
+				"step-2": {
+					Type:         wfv1.NodeTypePod,
+					TemplateName: "step-2-template",
+					Phase:        wfv1.NodeSucceeded,
+					StartedAt:    metav1.Time{Time: startTime},
+					FinishedAt:   metav1.Time{Time: startTime.Add(time.Hour)},
+					Resources: &v1.ResourceRequirements{
+						Limits: v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")},
+					},
+				},
+			},
+		},
+	}
+
+	// the cost of the nodes is proportional to their CPU
+	result := ComputeWorkflowCost(&wf, 0.10)
+	assert.InDelta(t, 0.45, result.TotalCost, 0.001)
+	assert.Equal(t, 2*time.Hour, result.TotalDuration)
+}
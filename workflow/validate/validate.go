@@ -10,7 +10,9 @@ import (
 	"strings"
 
 	"github.com/valyala/fasttemplate"
+	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	apivalidation "k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/yaml"
 
@@ -147,6 +149,15 @@ func ValidateWorkflow(wftmplGetter templateresolution.WorkflowTemplateNamespaced
 		}
 	}
 
+	switch wf.Spec.Shutdown {
+	case "", wfv1.ShutdownStrategyStop, wfv1.ShutdownStrategyTerminate:
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "shutdown unknown strategy '%s'", wf.Spec.Shutdown)
+	}
+	if wf.Spec.ShutdownGracePeriodSeconds != nil && *wf.Spec.ShutdownGracePeriodSeconds < 0 {
+		return errors.Errorf(errors.CodeBadRequest, "shutdownGracePeriodSeconds must be a non-negative integer")
+	}
+
 	// Check if all templates can be resolved.
 	for _, template := range wf.Spec.Templates {
 		_, err := ctx.validateTemplateHolder(&wfv1.Template{Template: template.Name}, tmplCtx, &FakeArguments{}, map[string]interface{}{})
@@ -172,6 +183,20 @@ func ValidateWorkflowTemplate(wftmplGetter templateresolution.WorkflowTemplateNa
 	return nil
 }
 
+// ValidateDAG validates a DAG template whose tasks were generated at runtime. The tasks are
+// checked as those of a DAG in a workflow spec, in the scope of the inputs of the template.
+func ValidateDAG(wf *wfv1.Workflow, tmplCtx *templateresolution.Context, tmpl *wfv1.Template, opts ValidateOpts) error {
+	ctx := newTemplateValidationCtx(wf, opts)
+	scope, err := validateInputs(tmpl, map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	for globalVar, val := range ctx.globalParams {
+		scope[globalVar] = val
+	}
+	return ctx.validateDAG(scope, tmplCtx, tmpl)
+}
+
 func (ctx *templateValidationCtx) validateTemplate(tmpl *wfv1.Template, tmplCtx *templateresolution.Context, args wfv1.ArgumentsProvider, extraScope map[string]interface{}) error {
 	tmplID := getTemplateID(tmpl)
 	_, ok := ctx.results[tmplID]
@@ -189,10 +214,20 @@ func (ctx *templateValidationCtx) validateTemplate(tmpl *wfv1.Template, tmplCtx
 		return err
 	}
 
+	if tmpl.Timeout != nil && *tmpl.Timeout <= 0 {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.timeout must be a positive integer > 0", tmpl.Name)
+	}
+
 	scope, err := validateInputs(tmpl, extraScope)
 	if err != nil {
 		return err
 	}
+	if tmpl.DAG != nil && tmpl.DAG.TasksFrom != "" {
+		err = validateTasksFrom(tmpl)
+		if err != nil {
+			return err
+		}
+	}
 	localParams := make(map[string]string)
 	if tmpl.IsPodType() {
 		localParams[common.LocalVarPodName] = placeholderValue
@@ -293,13 +328,43 @@ func (ctx *templateValidationCtx) validateTemplateHolder(tmplHolder wfv1.Templat
 		return nil, err
 	}
 
+	err = validateParameterValues(resolvedTmpl, args)
+	if err != nil {
+		return nil, err
+	}
 	return resolvedTmpl, ctx.validateTemplate(resolvedTmpl, tmplCtx, args, extraScope)
 }
 
+// validateParameterValues validates the values of the input parameters of a template, supplied by
+// the arguments or the defaults, against the enum, pattern, type and schema of the parameters. The
+// values which reference variables are only known, and validated, at runtime.
+func validateParameterValues(tmpl *wfv1.Template, args wfv1.ArgumentsProvider) error {
+	if _, ok := args.(*FakeArguments); ok {
+		args = &wfv1.Arguments{}
+	}
+	for _, param := range tmpl.Inputs.Parameters {
+		value := param.Value
+		if param.Default != nil {
+			value = param.Default
+		}
+		if argParam := args.GetParameterByName(param.Name); argParam != nil && argParam.Value != nil {
+			value = argParam.Value
+		}
+		if value == nil || strings.Contains(*value, "{{") {
+			continue
+		}
+		err := common.ValidateParameterValue(param, *value)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.%s", tmpl.Name, err.Error())
+		}
+	}
+	return nil
+}
+
 // validateTemplateType validates that only one template type is defined
 func validateTemplateType(tmpl *wfv1.Template) error {
 	numTypes := 0
-	for _, tmplType := range []interface{}{tmpl.TemplateRef, tmpl.Container, tmpl.Steps, tmpl.Script, tmpl.Resource, tmpl.DAG, tmpl.Suspend} {
+	for _, tmplType := range []interface{}{tmpl.TemplateRef, tmpl.Container, tmpl.Steps, tmpl.Script, tmpl.Resource, tmpl.DAG, tmpl.Suspend, tmpl.Approval} {
 		if !reflect.ValueOf(tmplType).IsNil() {
 			numTypes++
 		}
@@ -309,10 +374,64 @@ func validateTemplateType(tmpl *wfv1.Template) error {
 	}
 	switch numTypes {
 	case 0:
-		return errors.Errorf(errors.CodeBadRequest, "templates.%s template type unspecified. choose one of: container, steps, script, resource, dag, suspend, template, template ref", tmpl.Name)
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s template type unspecified. choose one of: container, steps, script, resource, dag, suspend, approval, template, template ref", tmpl.Name)
 	case 1:
 	default:
-		return errors.Errorf(errors.CodeBadRequest, "templates.%s multiple template types specified. choose one of: container, steps, script, resource, dag, suspend, template, template ref", tmpl.Name)
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s multiple template types specified. choose one of: container, steps, script, resource, dag, suspend, approval, template, template ref", tmpl.Name)
+	}
+	return nil
+}
+
+// validateResourceEscalation validates the resource escalation of a retry strategy
+func validateResourceEscalation(tmpl *wfv1.Template) error {
+	escalation := tmpl.RetryStrategy.ResourceEscalation
+	factor, err := escalation.GetFactor()
+	if err != nil || factor <= 1 {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.retryStrategy.resourceEscalation.factor must be a number greater than 1", tmpl.Name)
+	}
+	for name := range escalation.Max {
+		if name != apiv1.ResourceMemory && name != apiv1.ResourceCPU {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.retryStrategy.resourceEscalation.max.%s is not supported, only %s and %s are escalated", tmpl.Name, name, apiv1.ResourceMemory, apiv1.ResourceCPU)
+		}
+	}
+	return nil
+}
+
+// validateApproval validates the approval policy of an approval template
+func validateApproval(tmpl *wfv1.Template) error {
+	approval := tmpl.Approval
+	for i, user := range approval.Users {
+		if user == "" {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.approval.users[%d] is empty", tmpl.Name, i)
+		}
+		for _, prev := range approval.Users[:i] {
+			if prev == user {
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.approval.users[%d] '%s' is listed more than once", tmpl.Name, i, user)
+			}
+		}
+	}
+	if len(approval.Groups) > 0 {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.approval.groups is not supported: the groups of the approvers are not authenticated", tmpl.Name)
+	}
+	if approval.Required != nil {
+		if *approval.Required < 1 {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.approval.required must be a positive integer", tmpl.Name)
+		}
+		if len(approval.Users) > 0 && int(*approval.Required) > len(approval.Users) {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.approval.required %d exceeds the number of allowed users (%d)", tmpl.Name, *approval.Required, len(approval.Users))
+		}
+	}
+	if approval.Timeout != nil && *approval.Timeout <= 0 {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.approval.timeout must be a positive integer", tmpl.Name)
+	}
+	switch approval.DefaultDecision {
+	case "":
+	case wfv1.ApprovalDecisionApprove, wfv1.ApprovalDecisionReject:
+		if approval.Timeout == nil {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.approval.defaultDecision requires a timeout", tmpl.Name)
+		}
+	default:
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.approval.defaultDecision must be one of: %s, %s", tmpl.Name, wfv1.ApprovalDecisionApprove, wfv1.ApprovalDecisionReject)
 	}
 	return nil
 }
@@ -331,6 +450,10 @@ func validateInputs(tmpl *wfv1.Template, extraScope map[string]interface{}) (map
 		scope[name] = value
 	}
 	for _, param := range tmpl.Inputs.Parameters {
+		err = common.ValidateParameterConstraints(param)
+		if err != nil {
+			return nil, errors.Errorf(errors.CodeBadRequest, "templates.%s.%s", tmpl.Name, err.Error())
+		}
 		scope[fmt.Sprintf("inputs.parameters.%s", param.Name)] = true
 	}
 	if len(tmpl.Inputs.Parameters) > 0 {
@@ -378,6 +501,36 @@ func validateArtifactLocation(errPrefix string, art wfv1.ArtifactLocation) error
 	return nil
 }
 
+// addMatrixToScope validates the axes of a withMatrix and adds their values, referenced as
+// {{item.<axis>}}, to the scope
+func addMatrixToScope(withMatrix []wfv1.MatrixAxis, scope map[string]interface{}) error {
+	axisNames := make(map[string]bool)
+	for i, axis := range withMatrix {
+		if axis.Name == "" {
+			return fmt.Errorf("withMatrix[%d].name is required", i)
+		}
+		if errs := isValidParamOrArtifactName(axis.Name); len(errs) != 0 {
+			return fmt.Errorf("withMatrix[%d].name '%s' is invalid: %s", i, axis.Name, strings.Join(errs, ";"))
+		}
+		if axisNames[axis.Name] {
+			return fmt.Errorf("withMatrix[%d].name '%s' is not unique", i, axis.Name)
+		}
+		axisNames[axis.Name] = true
+		if (len(axis.Items) > 0) == (axis.Param != "") {
+			return fmt.Errorf("withMatrix[%d] exactly one of items or param must be specified", i)
+		}
+		for j := range axis.Items {
+			switch val := axis.Items[j].Value.(type) {
+			case string, int, int32, int64, float32, float64, bool:
+			default:
+				return fmt.Errorf("withMatrix[%d].items[%d] expected string, number or boolean. received: %v", i, j, val)
+			}
+		}
+		scope[fmt.Sprintf("item.%s", axis.Name)] = true
+	}
+	return nil
+}
+
 // resolveAllVariables is a helper to ensure all {{variables}} are resolveable from current scope
 func resolveAllVariables(scope map[string]interface{}, tmplStr string) error {
 	var unresolvedErr error
@@ -386,6 +539,14 @@ func resolveAllVariables(scope map[string]interface{}, tmplStr string) error {
 
 	fstTmpl.ExecuteFuncString(func(w io.Writer, tag string) (int, error) {
 
+		// Verify the expressions parse, and the variables they reference resolve
+		if strings.HasPrefix(tag, "=") {
+			if unresolvedErr == nil {
+				unresolvedErr = resolveExpressionVariables(scope, tag)
+			}
+			return 0, nil
+		}
+
 		// Skip the custom variable references
 		if !checkValidWorkflowVariablePrefix(tag) {
 			return 0, nil
@@ -405,6 +566,44 @@ func resolveAllVariables(scope map[string]interface{}, tmplStr string) error {
 	return unresolvedErr
 }
 
+// resolveExpressionVariables verifies the expression of a {{=expr}} tag parses, and the variables it
+// references are in scope
+func resolveExpressionVariables(scope map[string]interface{}, tag string) error {
+	expression, err := common.ParseExpressionTag(tag)
+	if err != nil {
+		return err
+	}
+	_, allowAllItemRefs := scope[anyItemMagicValue]
+	for _, v := range expression.Variables {
+		if _, ok := scope[v]; ok || strings.HasPrefix(v, common.GlobalVarWorkflowCreationTimestamp) {
+			continue
+		}
+		if (v == "item" || strings.HasPrefix(v, "item.")) && allowAllItemRefs {
+			continue
+		}
+		return fmt.Errorf("failed to resolve %s in {{%s}}", v, tag)
+	}
+	return nil
+}
+
+// resolveWhenVariables verifies a typed when expression parses, and the variables it references are in scope
+func resolveWhenVariables(scope map[string]interface{}, when string) error {
+	if !common.IsTypedWhen(when) {
+		return nil
+	}
+	expression, err := common.ParseWhen(when)
+	if err != nil {
+		return err
+	}
+	for _, v := range expression.Variables {
+		if _, ok := scope[v]; ok || strings.HasPrefix(v, common.GlobalVarWorkflowCreationTimestamp) {
+			continue
+		}
+		return fmt.Errorf("failed to resolve %s", v)
+	}
+	return nil
+}
+
 // checkValidWorkflowVariablePrefix is a helper methood check variable starts workflow root elements
 func checkValidWorkflowVariablePrefix(tag string) bool {
 	for _, rootTag := range common.GlobalVarValidWorkflowVariablePrefix {
@@ -422,6 +621,9 @@ func validateNonLeaf(tmpl *wfv1.Template) error {
 	if tmpl.RetryStrategy != nil {
 		return errors.Errorf(errors.CodeBadRequest, "templates.%s.retryStrategy is only valid for container templates", tmpl.Name)
 	}
+	if tmpl.PendingPolicy != nil {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.pendingPolicy is only valid for leaf templates", tmpl.Name)
+	}
 	return nil
 }
 
@@ -464,6 +666,31 @@ func (ctx *templateValidationCtx) validateLeaf(scope map[string]interface{}, tmp
 			return errors.Errorf(errors.CodeBadRequest, "templates.%s.resource.manifest must be a valid yaml", tmpl.Name)
 		}
 	}
+	if tmpl.RetryStrategy != nil && tmpl.RetryStrategy.InfrastructureLimit != nil && *tmpl.RetryStrategy.InfrastructureLimit < 0 {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.retryStrategy.infrastructureLimit must not be negative", tmpl.Name)
+	}
+	if tmpl.RetryStrategy != nil && tmpl.RetryStrategy.ResourceEscalation != nil {
+		err = validateResourceEscalation(tmpl)
+		if err != nil {
+			return err
+		}
+	}
+	if tmpl.PendingPolicy != nil {
+		if tmpl.PendingPolicy.MaxDuration != nil && *tmpl.PendingPolicy.MaxDuration <= 0 {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.pendingPolicy.maxDuration must be a positive integer", tmpl.Name)
+		}
+		for i, reason := range tmpl.PendingPolicy.FailureReasons {
+			if reason == "" {
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.pendingPolicy.failureReasons[%d] is empty", tmpl.Name, i)
+			}
+		}
+	}
+	if tmpl.Approval != nil {
+		err = validateApproval(tmpl)
+		if err != nil {
+			return err
+		}
+	}
 	if tmpl.ActiveDeadlineSeconds != nil {
 		if *tmpl.ActiveDeadlineSeconds <= 0 {
 			return errors.Errorf(errors.CodeBadRequest, "templates.%s.activeDeadlineSeconds must be a positive integer > 0", tmpl.Name)
@@ -549,10 +776,15 @@ func (ctx *templateValidationCtx) validateSteps(scope map[string]interface{}, tm
 			stepNames[step.Name] = true
 			prefix := fmt.Sprintf("steps.%s", step.Name)
 			scope[fmt.Sprintf("%s.status", prefix)] = true
-			err := addItemsToScope(prefix, step.WithItems, step.WithParam, step.WithSequence, scope)
+			err := addItemsToScope(prefix, step.WithItems, step.WithParam, step.WithSequence, step.WithMatrix, step.WithArtifact, scope)
 			if err != nil {
 				return errors.Errorf(errors.CodeBadRequest, "templates.%s.steps[%d].%s %s", tmpl.Name, i, step.Name, err.Error())
 			}
+			loop := step.IsLoop()
+			err = addBatchToScope(step.BatchSize, loop, scope)
+			if err != nil {
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.steps[%d].%s.batchSize %s", tmpl.Name, i, step.Name, err.Error())
+			}
 			stepBytes, err := json.Marshal(stepGroup)
 			if err != nil {
 				return errors.InternalWrapError(err)
@@ -561,6 +793,10 @@ func (ctx *templateValidationCtx) validateSteps(scope map[string]interface{}, tm
 			if err != nil {
 				return errors.Errorf(errors.CodeBadRequest, "templates.%s.steps[%d].%s %s", tmpl.Name, i, step.Name, err.Error())
 			}
+			err = resolveWhenVariables(scope, step.When)
+			if err != nil {
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.steps[%d].%s.when %s", tmpl.Name, i, step.Name, err.Error())
+			}
 			err = validateArguments(fmt.Sprintf("templates.%s.steps[%d].%s.arguments.", tmpl.Name, i, step.Name), step.Arguments)
 			if err != nil {
 				return err
@@ -570,11 +806,30 @@ func (ctx *templateValidationCtx) validateSteps(scope map[string]interface{}, tm
 				return errors.Errorf(errors.CodeBadRequest, "templates.%s.steps[%d].%s %s", tmpl.Name, i, step.Name, err.Error())
 			}
 			resolvedTemplates[step.Name] = resolvedTmpl
+			err = ctx.validateOnExitHook(step.OnExit, resolvedTmpl, tmplCtx)
+			if err != nil {
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.steps[%d].%s.onExit %s", tmpl.Name, i, step.Name, err.Error())
+			}
+			err = validateContinueOn(step.ContinueOn, resolvedTmpl)
+			if err != nil {
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.steps[%d].%s.continueOn%s", tmpl.Name, i, step.Name, err.Error())
+			}
+			err = validateFailureTolerance(step.FailureTolerance, loop)
+			if err != nil {
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.steps[%d].%s.failureTolerance %s", tmpl.Name, i, step.Name, err.Error())
+			}
+			if step.Race != stepGroup[0].Race {
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.steps[%d].%s.race must be set on all the steps of the step group or on none", tmpl.Name, i, step.Name)
+			}
+			if step.Race && step.FailureTolerance != nil {
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.steps[%d].%s.race cannot be combined with failureTolerance", tmpl.Name, i, step.Name)
+			}
 		}
 		for i, step := range stepGroup {
-			aggregate := len(step.WithItems) > 0 || step.WithParam != ""
+			// the outputs of a race are those of its winner
+			aggregate := step.IsLoop() && !step.Race
 			resolvedTmpl := resolvedTemplates[step.Name]
-			ctx.addOutputsToScope(resolvedTmpl, fmt.Sprintf("steps.%s", step.Name), scope, aggregate, false)
+			ctx.addOutputsToScope(resolvedTmpl, fmt.Sprintf("steps.%s", step.Name), scope, aggregate, aggregate && step.BatchSize > 0, false)
 
 			// Validate the template again with actual arguments.
 			_, err = ctx.validateTemplateHolder(&step, tmplCtx, &step.Arguments, scope)
@@ -586,8 +841,106 @@ func (ctx *templateValidationCtx) validateSteps(scope map[string]interface{}, tm
 	return nil
 }
 
-func addItemsToScope(prefix string, withItems []wfv1.Item, withParam string, withSequence *wfv1.Sequence, scope map[string]interface{}) error {
+// validateOnExitHook validates the exit hook of a step or task. The hook is only given the status
+// and the outputs of the step or task, which templates it references as {{status}} and {{outputs.*}}.
+func (ctx *templateValidationCtx) validateOnExitHook(onExit string, resolvedTmpl *wfv1.Template, tmplCtx *templateresolution.Context) error {
+	if onExit == "" {
+		return nil
+	}
+	scope := map[string]interface{}{
+		common.LocalVarStatus: true,
+	}
+	if resolvedTmpl != nil {
+		if resolvedTmpl.Script != nil {
+			scope["outputs.result"] = true
+		}
+		for _, param := range resolvedTmpl.Outputs.Parameters {
+			scope[fmt.Sprintf("outputs.parameters.%s", param.Name)] = true
+		}
+	}
+	_, err := ctx.validateTemplateHolder(&wfv1.Template{Template: onExit}, tmplCtx, &wfv1.Arguments{}, scope)
+	return err
+}
+
+// validateContinueOn validates the exit codes and error conditions of a step or task are applicable
+// to its template. The error returned is prefixed by the field at fault.
+func validateContinueOn(continueOn *wfv1.ContinueOn, resolvedTmpl *wfv1.Template) error {
+	if continueOn == nil {
+		return nil
+	}
+	for i, exitCode := range continueOn.ExitCodes {
+		if exitCode == 0 {
+			return errors.Errorf(errors.CodeBadRequest, ".exitCodes[%d] 0 is not the exit code of a failure", i)
+		}
+	}
+	conditions := make(map[string]bool)
+	for i, condition := range continueOn.Conditions {
+		if condition == "" {
+			return errors.Errorf(errors.CodeBadRequest, ".conditions[%d] may not be empty", i)
+		}
+		if conditions[condition] {
+			return errors.Errorf(errors.CodeBadRequest, ".conditions[%d] '%s' is duplicated", i, condition)
+		}
+		conditions[condition] = true
+	}
+	if resolvedTmpl == nil {
+		// the template is resolved at runtime
+		return nil
+	}
+	if len(continueOn.ExitCodes) > 0 && !resolvedTmpl.IsPodType() {
+		return errors.Errorf(errors.CodeBadRequest, ".exitCodes is only applicable to the templates which run a pod")
+	}
+	for i, condition := range continueOn.Conditions {
+		found := false
+		for _, errorCondition := range resolvedTmpl.Errors {
+			if errorCondition.Name == condition {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf(errors.CodeBadRequest, ".conditions[%d] '%s' is not an error condition of template '%s'", i, condition, resolvedTmpl.Name)
+		}
+	}
+	return nil
+}
+
+// validateFailureTolerance validates the failure tolerance of a step or task is a non-negative number
+// or percentage, and applies to a loop
+func validateFailureTolerance(tolerance *intstr.IntOrString, loop bool) error {
+	if tolerance == nil {
+		return nil
+	}
+	if !loop {
+		return errors.New(errors.CodeBadRequest, "is only applicable to withItems, withParam, withSequence, withMatrix or withArtifact")
+	}
+	if tolerance.Type == intstr.Int {
+		if tolerance.IntVal < 0 {
+			return errors.Errorf(errors.CodeBadRequest, "%d may not be negative", tolerance.IntVal)
+		}
+		return nil
+	}
+	if strings.Contains(tolerance.StrVal, "{{") {
+		// the tolerance is resolved at runtime
+		return nil
+	}
+	// a string tolerance is either a plain number, as resolved from a parameter, or a percentage
+	number := strings.TrimSuffix(tolerance.StrVal, "%")
+	value, err := strconv.Atoi(number)
+	if err != nil || value < 0 || (number != tolerance.StrVal && value > 100) {
+		return errors.Errorf(errors.CodeBadRequest, "'%s' is not a number or a percentage between 0%% and 100%%", tolerance.StrVal)
+	}
+	return nil
+}
+
+func addItemsToScope(prefix string, withItems []wfv1.Item, withParam string, withSequence *wfv1.Sequence, withMatrix []wfv1.MatrixAxis, withArtifact *wfv1.LoopArtifact, scope map[string]interface{}) error {
 	defined := 0
+	if withArtifact != nil {
+		defined++
+	}
+	if len(withMatrix) > 0 {
+		defined++
+	}
 	if len(withItems) > 0 {
 		defined++
 	}
@@ -598,7 +951,10 @@ func addItemsToScope(prefix string, withItems []wfv1.Item, withParam string, wit
 		defined++
 	}
 	if defined > 1 {
-		return fmt.Errorf("only one of withItems, withParam, withSequence can be specified")
+		return fmt.Errorf("only one of withItems, withParam, withSequence, withMatrix, withArtifact can be specified")
+	}
+	if len(withMatrix) > 0 {
+		return addMatrixToScope(withMatrix, scope)
 	}
 	if len(withItems) > 0 {
 		for i := range withItems {
@@ -618,6 +974,17 @@ func addItemsToScope(prefix string, withItems []wfv1.Item, withParam string, wit
 		// 'item.*' is magic placeholder value which resolveAllVariables() will look for
 		// when considering if all variables are resolveable.
 		scope[anyItemMagicValue] = true
+	} else if withArtifact != nil {
+		if withArtifact.From == "" {
+			return errors.New(errors.CodeBadRequest, "withArtifact.from is required")
+		}
+		switch withArtifact.Format {
+		case "", wfv1.LoopArtifactFormatJSON, wfv1.LoopArtifactFormatLines:
+		default:
+			return errors.Errorf(errors.CodeBadRequest, "withArtifact.format '%s' is invalid: must be json or lines", withArtifact.Format)
+		}
+		scope["item"] = true
+		scope[anyItemMagicValue] = true
 	} else if withSequence != nil {
 		if withSequence.Count != "" && withSequence.End != "" {
 			return errors.New(errors.CodeBadRequest, "only one of count or end can be defined in withSequence")
@@ -627,10 +994,34 @@ func addItemsToScope(prefix string, withItems []wfv1.Item, withParam string, wit
 	return nil
 }
 
-func (ctx *templateValidationCtx) addOutputsToScope(tmpl *wfv1.Template, prefix string, scope map[string]interface{}, aggregate bool, isAncestor bool) {
+// addBatchToScope adds the variables of the batches of a loop to the scope. The items of a batch
+// are a JSON list in {{item}}.
+func addBatchToScope(batchSize int32, loop bool, scope map[string]interface{}) error {
+	if batchSize == 0 {
+		return nil
+	}
+	if !loop {
+		return errors.New(errors.CodeBadRequest, "is only applicable to withItems, withParam, withSequence, withMatrix or withArtifact")
+	}
+	if batchSize < 0 {
+		return errors.Errorf(errors.CodeBadRequest, "%d must be positive", batchSize)
+	}
+	scope["item"] = true
+	scope["batch.start"] = true
+	scope["batch.end"] = true
+	return nil
+}
+
+// addOutputsToScope adds the outputs of a step or task to the scope. The output artifacts of a batched
+// loop cannot be aggregated, as a batch produces a single artifact for all its items, so they are left
+// out of the scope.
+func (ctx *templateValidationCtx) addOutputsToScope(tmpl *wfv1.Template, prefix string, scope map[string]interface{}, aggregate bool, batched bool, isAncestor bool) {
 	if tmpl.Daemon != nil && *tmpl.Daemon {
 		scope[fmt.Sprintf("%s.ip", prefix)] = true
 	}
+	if tmpl.IsPodType() {
+		scope[fmt.Sprintf("%s.exitCode", prefix)] = true
+	}
 	if tmpl.Script != nil {
 		scope[fmt.Sprintf("%s.outputs.result", prefix)] = true
 	}
@@ -643,7 +1034,9 @@ func (ctx *templateValidationCtx) addOutputsToScope(tmpl *wfv1.Template, prefix
 		}
 	}
 	for _, art := range tmpl.Outputs.Artifacts {
-		scope[fmt.Sprintf("%s.outputs.artifacts.%s", prefix, art.Name)] = true
+		if !batched {
+			scope[fmt.Sprintf("%s.outputs.artifacts.%s", prefix, art.Name)] = true
+		}
 		if art.GlobalName != "" && !isParameter(art.GlobalName) {
 			globalArtName := fmt.Sprintf("workflow.outputs.artifacts.%s", art.GlobalName)
 			scope[globalArtName] = true
@@ -701,9 +1094,19 @@ func validateOutputs(scope map[string]interface{}, tmpl *wfv1.Template) error {
 	}
 	for _, param := range tmpl.Outputs.Parameters {
 		paramRef := fmt.Sprintf("templates.%s.outputs.parameters.%s", tmpl.Name, param.Name)
-		err = validateOutputParameter(paramRef, &param)
-		if err != nil {
-			return err
+		if tmpl.GetType() == wfv1.TemplateTypeApproval {
+			return errors.Errorf(errors.CodeBadRequest, "%s output parameters are not supported by approval templates", paramRef)
+		}
+		if tmpl.GetType() == wfv1.TemplateTypeSuspend {
+			// the values of the output parameters of suspend templates are supplied when resuming
+			if param.ValueFrom != nil || param.Value != nil {
+				return errors.Errorf(errors.CodeBadRequest, "%s can only have a default, its value is supplied when resuming", paramRef)
+			}
+		} else {
+			err = validateOutputParameter(paramRef, &param)
+			if err != nil {
+				return err
+			}
 		}
 		if param.ValueFrom != nil {
 			tmplType := tmpl.GetType()
@@ -857,11 +1260,33 @@ func validateWorkflowFieldNames(slice interface{}) error {
 	return nil
 }
 
+// validateTasksFrom validates that the tasks of a dynamic DAG are taken from an input of the template
+func validateTasksFrom(tmpl *wfv1.Template) error {
+	if len(tmpl.DAG.Tasks) > 0 {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasksFrom cannot be used with tasks", tmpl.Name)
+	}
+	ref := tmpl.DAG.TasksFrom
+	if strings.HasPrefix(ref, "{{") && strings.HasSuffix(ref, "}}") {
+		ref = strings.TrimSpace(ref[2 : len(ref)-2])
+		if strings.HasPrefix(ref, "inputs.parameters.") && tmpl.Inputs.GetParameterByName(strings.TrimPrefix(ref, "inputs.parameters.")) != nil {
+			return nil
+		}
+		if strings.HasPrefix(ref, "inputs.artifacts.") && tmpl.Inputs.GetArtifactByName(strings.TrimPrefix(ref, "inputs.artifacts.")) != nil {
+			return nil
+		}
+	}
+	return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasksFrom '%s' must reference an input parameter or artifact of the template", tmpl.Name, tmpl.DAG.TasksFrom)
+}
+
 func (ctx *templateValidationCtx) validateDAG(scope map[string]interface{}, tmplCtx *templateresolution.Context, tmpl *wfv1.Template) error {
 	err := validateNonLeaf(tmpl)
 	if err != nil {
 		return err
 	}
+	if tmpl.DAG.TasksFrom != "" {
+		// the tasks are generated, and validated, when the DAG runs
+		return nil
+	}
 	err = validateWorkflowFieldNames(tmpl.DAG.Tasks)
 	if err != nil {
 		return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasks%s", tmpl.Name, err.Error())
@@ -879,8 +1304,28 @@ func (ctx *templateValidationCtx) validateDAG(scope map[string]interface{}, tmpl
 			return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasks.%s %s", tmpl.Name, task.Name, err.Error())
 		}
 		prefix := fmt.Sprintf("tasks.%s", task.Name)
-		ctx.addOutputsToScope(resolvedTmpl, prefix, scope, false, false)
+		ctx.addOutputsToScope(resolvedTmpl, prefix, scope, false, false, false)
 		resolvedTemplates[task.Name] = resolvedTmpl
+		err = ctx.validateOnExitHook(task.OnExit, resolvedTmpl, tmplCtx)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasks.%s.onExit %s", tmpl.Name, task.Name, err.Error())
+		}
+		err = validateContinueOn(task.ContinueOn, resolvedTmpl)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasks.%s.continueOn%s", tmpl.Name, task.Name, err.Error())
+		}
+		err = validateFailureTolerance(task.FailureTolerance, task.IsLoop())
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasks.%s.failureTolerance %s", tmpl.Name, task.Name, err.Error())
+		}
+		if task.Race {
+			if !task.IsLoop() {
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasks.%s.race is only applicable to withItems, withParam, withSequence, withMatrix or withArtifact", tmpl.Name, task.Name)
+			}
+			if task.FailureTolerance != nil {
+				return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasks.%s.race cannot be combined with failureTolerance", tmpl.Name, task.Name)
+			}
+		}
 		dupDependencies := make(map[string]bool)
 		for j, depName := range task.Dependencies {
 			if _, ok := dupDependencies[depName]; ok {
@@ -895,8 +1340,20 @@ func (ctx *templateValidationCtx) validateDAG(scope map[string]interface{}, tmpl
 					tmpl.Name, task.Name, j, depName)
 			}
 		}
+		err = validateDepends(&task, nameToTask)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasks.%s.depends %s", tmpl.Name, task.Name, err.Error())
+		}
 	}
 
+	// the dependencies of the tasks with a depends expression are the tasks it references
+	tasks, err := common.ExpandDepends(tmpl.DAG.Tasks)
+	if err != nil {
+		return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasks %s", tmpl.Name, err.Error())
+	}
+	for _, task := range tasks {
+		nameToTask[task.Name] = task
+	}
 	if err = verifyNoCycles(tmpl, nameToTask); err != nil {
 		return err
 	}
@@ -913,7 +1370,7 @@ func (ctx *templateValidationCtx) validateDAG(scope map[string]interface{}, tmpl
 		resolvedTmpl := resolvedTemplates[task.Name]
 		// add all tasks outputs to scope so that a nested DAGs can have outputs
 		prefix := fmt.Sprintf("tasks.%s", task.Name)
-		ctx.addOutputsToScope(resolvedTmpl, prefix, scope, false, false)
+		ctx.addOutputsToScope(resolvedTmpl, prefix, scope, false, false, false)
 		taskBytes, err := json.Marshal(task)
 		if err != nil {
 			return errors.InternalWrapError(err)
@@ -922,22 +1379,31 @@ func (ctx *templateValidationCtx) validateDAG(scope map[string]interface{}, tmpl
 		for k, v := range scope {
 			taskScope[k] = v
 		}
-		ancestry := common.GetTaskAncestry(nil, task.Name, tmpl.DAG.Tasks)
+		ancestry := common.GetTaskAncestry(nil, task.Name, tasks)
 		for _, ancestor := range ancestry {
 			ancestorTask := nameToTask[ancestor]
 			resolvedTmpl := resolvedTemplates[ancestor]
 			ancestorPrefix := fmt.Sprintf("tasks.%s", ancestor)
-			aggregate := len(ancestorTask.WithItems) > 0 || ancestorTask.WithParam != ""
-			ctx.addOutputsToScope(resolvedTmpl, ancestorPrefix, taskScope, aggregate, true)
+			// the outputs of a race are those of its winner
+			aggregate := ancestorTask.IsLoop() && !ancestorTask.Race
+			ctx.addOutputsToScope(resolvedTmpl, ancestorPrefix, taskScope, aggregate, aggregate && ancestorTask.BatchSize > 0, true)
 		}
-		err = addItemsToScope(prefix, task.WithItems, task.WithParam, task.WithSequence, taskScope)
+		err = addItemsToScope(prefix, task.WithItems, task.WithParam, task.WithSequence, task.WithMatrix, task.WithArtifact, taskScope)
 		if err != nil {
 			return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasks.%s %s", tmpl.Name, task.Name, err.Error())
 		}
+		err = addBatchToScope(task.BatchSize, task.IsLoop(), taskScope)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasks.%s.batchSize %s", tmpl.Name, task.Name, err.Error())
+		}
 		err = resolveAllVariables(taskScope, string(taskBytes))
 		if err != nil {
 			return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasks.%s %s", tmpl.Name, task.Name, err.Error())
 		}
+		err = resolveWhenVariables(taskScope, task.When)
+		if err != nil {
+			return errors.Errorf(errors.CodeBadRequest, "templates.%s.tasks.%s.when %s", tmpl.Name, task.Name, err.Error())
+		}
 		err = validateArguments(fmt.Sprintf("templates.%s.tasks.%s.arguments.", tmpl.Name, task.Name), task.Arguments)
 		if err != nil {
 			return err
@@ -952,6 +1418,33 @@ func (ctx *templateValidationCtx) validateDAG(scope map[string]interface{}, tmpl
 	return nil
 }
 
+// validateDepends validates the depends expression of a task references defined tasks, with results
+// applicable to them
+func validateDepends(task *wfv1.DAGTask, nameToTask map[string]wfv1.DAGTask) error {
+	if task.Depends == "" {
+		return nil
+	}
+	if len(task.Dependencies) > 0 {
+		return errors.New(errors.CodeBadRequest, "may not be specified with dependencies")
+	}
+	refs, err := common.ParseDepends(task.Depends)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		depTask, ok := nameToTask[ref.TaskName]
+		if !ok {
+			return errors.Errorf(errors.CodeBadRequest, "task '%s' not defined", ref.TaskName)
+		}
+		if ref.Result == common.DependsResultAnySucceeded || ref.Result == common.DependsResultAllFailed {
+			if !depTask.IsLoop() {
+				return errors.Errorf(errors.CodeBadRequest, "%s is only applicable to the tasks with withItems, withParam, withSequence, withMatrix or withArtifact", ref)
+			}
+		}
+	}
+	return nil
+}
+
 func validateDAGTargets(tmpl *wfv1.Template, nameToTask map[string]wfv1.DAGTask) error {
 	if tmpl.DAG.Target == "" {
 		return nil
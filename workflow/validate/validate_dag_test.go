@@ -1,6 +1,7 @@
 package validate
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -655,3 +656,112 @@ func TestDAGTargetMissingInputParam(t *testing.T) {
 	err := validate(dagTargetMissingInputParam)
 	assert.NotNil(t, err)
 }
+
+var dagContinueOn = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: dag-continue-on-
+spec:
+  entrypoint: dag-continue-on
+  templates:
+  - name: dag-continue-on
+    dag:
+      tasks:
+      - name: A
+        template: search
+        continueOn:
+          exitCodes: [3]
+          conditions: [no-results]
+      - name: B
+        dependencies: [A]
+        template: nested
+        continueOn:
+          failed: true
+  - name: nested
+    steps:
+    - - name: search
+        template: search
+  - name: search
+    container:
+      image: alpine:3.7
+      command: [sh, -c, "exit 3"]
+    errors:
+    - name: no-results
+      patternMatched: no results
+`
+
+func TestDAGContinueOn(t *testing.T) {
+	err := validate(dagContinueOn)
+	assert.NoError(t, err)
+
+	err = validate(strings.Replace(dagContinueOn, "exitCodes: [3]", "exitCodes: [0]", 1))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "templates.dag-continue-on.tasks.A.continueOn.exitCodes[0] 0 is not the exit code of a failure")
+	}
+	err = validate(strings.Replace(dagContinueOn, "conditions: [no-results]", "conditions: [no-result]", 1))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "templates.dag-continue-on.tasks.A.continueOn.conditions[0] 'no-result' is not an error condition of template 'search'")
+	}
+	err = validate(strings.Replace(dagContinueOn, "failed: true", "exitCodes: [3]", 1))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "templates.dag-continue-on.tasks.B.continueOn.exitCodes is only applicable to the templates which run a pod")
+	}
+}
+
+var dagDepends = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: dag-depends-
+spec:
+  entrypoint: dag-depends
+  templates:
+  - name: dag-depends
+    dag:
+      tasks:
+      - name: A
+        template: echo
+        withItems: [a, b]
+      - name: B
+        template: echo
+        depends: A.AllFailed
+      - name: C
+        template: echo
+        depends: "A.AnySucceeded || B.Succeeded"
+        arguments:
+          parameters:
+          - name: message
+            value: "{{tasks.B.outputs.result}}"
+  - name: echo
+    inputs:
+      parameters:
+      - name: message
+        value: hello
+    script:
+      image: alpine:3.7
+      command: [sh]
+      source: echo {{inputs.parameters.message}}
+`
+
+func TestDAGDepends(t *testing.T) {
+	err := validate(dagDepends)
+	assert.NoError(t, err)
+
+	err = validate(strings.Replace(dagDepends, "depends: A.AllFailed", "depends: A.AllFailed\n        dependencies: [A]", 1))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "templates.dag-depends.tasks.B.depends may not be specified with dependencies")
+	}
+	err = validate(strings.Replace(dagDepends, "depends: A.AllFailed", "depends: D.Failed", 1))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "templates.dag-depends.tasks.B.depends task 'D' not defined")
+	}
+	err = validate(strings.Replace(dagDepends, "B.Succeeded", "B.AnySucceeded", 1))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "templates.dag-depends.tasks.C.depends B.AnySucceeded is only applicable to the tasks with withItems, withParam, withSequence, withMatrix or withArtifact")
+	}
+	err = validate(strings.Replace(dagDepends, "depends: A.AllFailed", "depends: C.Failed", 1))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "dependency cycle detected")
+	}
+}
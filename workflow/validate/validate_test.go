@@ -8,8 +8,11 @@ import (
 	"github.com/cyrusbiotechnology/argo/workflow/templateresolution"
 	"github.com/stretchr/testify/assert"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/yaml"
+	"strings"
 	"testing"
 )
 
@@ -383,6 +386,574 @@ func TestStepStatusReferenceNoFutureReference(t *testing.T) {
 	}
 }
 
+var stepExitCodeReferences = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: exit-code-ref-
+spec:
+  entrypoint: exitcoderef
+  templates:
+  - name: exitcoderef
+    steps:
+    - - name: one
+        template: say
+    - - name: two
+        template: say
+        when: "{{steps.one.exitCode}} == 0"
+    - - name: three
+        template: nested
+    - - name: four
+        template: say
+        when: "{{steps.three.exitCode}} == 0"
+  - name: nested
+    steps:
+    - - name: one
+        template: say
+  - name: say
+    container:
+      image: alpine:latest
+      command: [sh, -c, "exit 0"]
+`
+
+func TestStepExitCodeReference(t *testing.T) {
+	err := validate(stepExitCodeReferences)
+	// only the pods have an exit code
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "failed to resolve {{steps.three.exitCode}}")
+	}
+}
+
+var stepContinueOn = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: continue-on-
+spec:
+  entrypoint: continue-on
+  templates:
+  - name: continue-on
+    steps:
+    - - name: search
+        template: search
+        continueOn:
+          conditions: [no-results, no-results]
+  - name: search
+    container:
+      image: alpine:latest
+      command: [sh, -c, "exit 3"]
+    errors:
+    - name: no-results
+      patternMatched: no results
+`
+
+func TestStepContinueOn(t *testing.T) {
+	err := validate(stepContinueOn)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.continue-on.steps[0].search.continueOn.conditions[1] 'no-results' is duplicated")
+	}
+}
+
+var stepFailureTolerance = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: failure-tolerance-
+spec:
+  entrypoint: failure-tolerance
+  templates:
+  - name: failure-tolerance
+    steps:
+    - - name: process
+        template: process
+        withItems: [a, b, c]
+        failureTolerance: 10%
+  - name: process
+    container:
+      image: alpine:latest
+`
+
+func TestStepFailureTolerance(t *testing.T) {
+	err := validate(stepFailureTolerance)
+	assert.NoError(t, err)
+	err = validate(strings.Replace(stepFailureTolerance, "10%", "110%", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.failure-tolerance.steps[0].process.failureTolerance '110%' is not a number or a percentage between 0% and 100%")
+	}
+	err = validate(strings.Replace(stepFailureTolerance, "10%", `"2"`, 1))
+	assert.NoError(t, err)
+	err = validate(strings.Replace(stepFailureTolerance, "10%", "two", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "failureTolerance 'two' is not a number or a percentage between 0% and 100%")
+	}
+	err = validate(strings.Replace(stepFailureTolerance, "10%", "-1", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "-1 may not be negative")
+	}
+	err = validate(strings.Replace(stepFailureTolerance, "withItems: [a, b, c]", "", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "failureTolerance is only applicable to withItems, withParam, withSequence, withMatrix or withArtifact")
+	}
+}
+
+var stepTypedWhen = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: typed-when-
+spec:
+  entrypoint: typed-when
+  templates:
+  - name: typed-when
+    inputs:
+      parameters:
+      - name: threshold
+        value: "3"
+    steps:
+    - - name: generate
+        template: generate
+    - - name: process
+        template: generate
+        when: "int(steps.generate.outputs.result) > int(inputs.parameters.threshold) && steps.generate.exitCode == 0"
+  - name: generate
+    script:
+      image: python:alpine3.6
+      command: [python]
+      source: print(5)
+`
+
+func TestStepTypedWhen(t *testing.T) {
+	err := validate(stepTypedWhen)
+	assert.NoError(t, err)
+	err = validate(strings.Replace(stepTypedWhen, "inputs.parameters.threshold)", "inputs.parameters.limit)", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.typed-when.steps[1].process.when failed to resolve inputs.parameters.limit")
+	}
+	err = validate(strings.Replace(stepTypedWhen, "> int(", "> > int(", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.typed-when.steps[1].process.when Invalid 'when' expression")
+	}
+}
+
+var expressionSubstitution = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: expression-substitution-
+spec:
+  entrypoint: expression-substitution
+  arguments:
+    parameters:
+    - name: sample-id
+      value: SAMPLE-42
+  templates:
+  - name: expression-substitution
+    steps:
+    - - name: process
+        template: process
+        arguments:
+          parameters:
+          - name: sample
+            value: "{{=lower(workflow.parameters.sample-id)}}"
+          - name: index
+            value: "{{=int(item) + 1}}"
+        withItems: [0, 1]
+  - name: process
+    inputs:
+      parameters:
+      - name: sample
+      - name: index
+    container:
+      image: alpine:latest
+      command: [echo, "{{=upper(inputs.parameters.sample) + '-' + inputs.parameters.index}}"]
+`
+
+func TestExpressionSubstitution(t *testing.T) {
+	err := validate(expressionSubstitution)
+	assert.NoError(t, err)
+	err = validate(strings.Replace(expressionSubstitution, "upper(", "uppercase(", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "unknown function 'uppercase'")
+	}
+	err = validate(strings.Replace(expressionSubstitution, "+ inputs.parameters.index", "+ inputs.parameters.idx", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "failed to resolve inputs.parameters.idx")
+	}
+}
+
+var stepMatrix = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: step-matrix-
+spec:
+  entrypoint: step-matrix
+  templates:
+  - name: step-matrix
+    steps:
+    - - name: list-references
+        template: list-references
+    - - name: align
+        template: align
+        arguments:
+          parameters:
+          - name: sample
+            value: "{{item.sample}}"
+          - name: reference
+            value: "{{item.reference}}"
+        withMatrix:
+        - name: sample
+          items: [a, b, c]
+        - name: reference
+          param: "{{steps.list-references.outputs.result}}"
+    - - name: report
+        template: report
+        arguments:
+          parameters:
+          - name: results
+            value: "{{steps.align.outputs.parameters.result}}"
+  - name: list-references
+    script:
+      image: python:alpine3.6
+      command: [python]
+      source: print('["hg19", "hg38"]')
+  - name: align
+    inputs:
+      parameters:
+      - name: sample
+      - name: reference
+    container:
+      image: alpine:latest
+    outputs:
+      parameters:
+      - name: result
+        valueFrom:
+          path: /tmp/result
+  - name: report
+    inputs:
+      parameters:
+      - name: results
+    container:
+      image: alpine:latest
+`
+
+func TestStepMatrix(t *testing.T) {
+	err := validate(stepMatrix)
+	assert.NoError(t, err)
+	err = validate(strings.Replace(stepMatrix, "{{item.reference}}", "{{item.ref}}", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "failed to resolve {{item.ref}}")
+	}
+	err = validate(strings.Replace(stepMatrix, "- name: reference\n          param", "- name: sample\n          param", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "withMatrix[1].name 'sample' is not unique")
+	}
+	err = validate(strings.Replace(stepMatrix, "items: [a, b, c]", "items: [a, b, c]\n          param: '[\"d\"]'", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "withMatrix[0] exactly one of items or param must be specified")
+	}
+	err = validate(strings.Replace(stepMatrix, "withMatrix:", "withItems: [a]\n        withMatrix:", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "only one of withItems, withParam, withSequence, withMatrix, withArtifact can be specified")
+	}
+}
+
+var stepArtifactLoop = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: step-artifact-loop-
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: list-samples
+        template: list-samples
+    - - name: process
+        template: process
+        arguments:
+          parameters:
+          - name: sample
+            value: "{{item.sample}}"
+        withArtifact:
+          from: "{{steps.list-samples.outputs.artifacts.samples}}"
+          format: lines
+  - name: list-samples
+    container:
+      image: alpine:latest
+    outputs:
+      artifacts:
+      - name: samples
+        path: /tmp/samples.txt
+  - name: process
+    inputs:
+      parameters:
+      - name: sample
+    container:
+      image: alpine:latest
+`
+
+func TestStepArtifactLoop(t *testing.T) {
+	err := validate(stepArtifactLoop)
+	assert.NoError(t, err)
+	err = validate(strings.Replace(stepArtifactLoop, "outputs.artifacts.samples", "outputs.artifacts.sample", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "failed to resolve {{steps.list-samples.outputs.artifacts.sample}}")
+	}
+	err = validate(strings.Replace(stepArtifactLoop, "format: lines", "format: csv", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "withArtifact.format 'csv' is invalid: must be json or lines")
+	}
+	err = validate(strings.Replace(stepArtifactLoop, "withArtifact:", "withParam: '[]'\n        withArtifact:", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "only one of withItems, withParam, withSequence, withMatrix, withArtifact can be specified")
+	}
+}
+
+var stepBatch = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: step-batch-
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: process
+        template: process
+        arguments:
+          parameters:
+          - name: samples
+            value: "{{item}}"
+          - name: range
+            value: "{{batch.start}}-{{batch.end}}"
+        withSequence:
+          count: "1000"
+        batchSize: 100
+  - name: process
+    inputs:
+      parameters:
+      - name: samples
+      - name: range
+    container:
+      image: alpine:latest
+`
+
+func TestStepBatch(t *testing.T) {
+	err := validate(stepBatch)
+	assert.NoError(t, err)
+	err = validate(strings.Replace(stepBatch, "batchSize: 100", "batchSize: -1", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.main.steps[0].process.batchSize -1 must be positive")
+	}
+	err = validate(strings.Replace(stepBatch, "withSequence:\n          count: \"1000\"", "", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "batchSize is only applicable to withItems, withParam, withSequence, withMatrix or withArtifact")
+	}
+}
+
+var stepBatchArtifacts = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: step-batch-artifacts-
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: process
+        template: process
+        arguments:
+          parameters:
+          - name: samples
+            value: "{{item}}"
+        withSequence:
+          count: "1000"
+        batchSize: 100
+    - - name: merge
+        template: merge
+        arguments:
+          artifacts:
+          - name: counts
+            from: "{{steps.process.outputs.artifacts.counts}}"
+  - name: process
+    inputs:
+      parameters:
+      - name: samples
+    outputs:
+      artifacts:
+      - name: counts
+        path: /tmp/counts
+    container:
+      image: alpine:latest
+  - name: merge
+    inputs:
+      artifacts:
+      - name: counts
+        path: /tmp/counts
+    container:
+      image: alpine:latest
+`
+
+// TestStepBatchArtifacts verifies the output artifacts of a batched loop cannot be aggregated
+func TestStepBatchArtifacts(t *testing.T) {
+	err := validate(strings.Replace(stepBatchArtifacts, "batchSize: 100", "", 1))
+	assert.NoError(t, err)
+	err = validate(stepBatchArtifacts)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "failed to resolve {{steps.process.outputs.artifacts.counts}}")
+	}
+}
+
+var stepSequenceOutputs = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: sequence-outputs-
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: generate
+        template: generate
+        withSequence:
+          count: "3"
+    - - name: consume
+        template: consume
+        arguments:
+          parameters:
+          - name: values
+            value: "{{steps.generate.outputs.parameters}}"
+  - name: generate
+    container:
+      image: alpine:latest
+    outputs:
+      parameters:
+      - name: value
+        valueFrom:
+          path: /tmp/value
+  - name: consume
+    inputs:
+      parameters:
+      - name: values
+    container:
+      image: alpine:latest
+`
+
+// TestStepSequenceOutputs verifies the outputs of a withSequence loop are aggregated like those of
+// the other loops
+func TestStepSequenceOutputs(t *testing.T) {
+	err := validate(stepSequenceOutputs)
+	assert.NoError(t, err)
+}
+
+var dynamicDag = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: dynamic-dag-
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: plan
+        template: plan
+    - - name: run
+        template: run
+        arguments:
+          artifacts:
+          - name: tasks
+            from: "{{steps.plan.outputs.artifacts.tasks}}"
+  - name: plan
+    container:
+      image: alpine:latest
+    outputs:
+      artifacts:
+      - name: tasks
+        path: /tmp/tasks.json
+  - name: run
+    inputs:
+      artifacts:
+      - name: tasks
+    dag:
+      tasksFrom: "{{inputs.artifacts.tasks}}"
+`
+
+func TestDynamicDag(t *testing.T) {
+	err := validate(dynamicDag)
+	assert.NoError(t, err)
+	err = validate(strings.Replace(dynamicDag, "{{inputs.artifacts.tasks}}", "{{inputs.artifacts.plan}}", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.run.tasksFrom '{{inputs.artifacts.plan}}' must reference an input parameter or artifact of the template")
+	}
+	err = validate(strings.Replace(dynamicDag, "{{inputs.artifacts.tasks}}\"", "{{inputs.artifacts.tasks}}\"\n      tasks:\n      - name: A\n        template: plan", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.run.tasksFrom cannot be used with tasks")
+	}
+}
+
+var typedParameters = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: typed-parameters-
+spec:
+  entrypoint: main
+  arguments:
+    parameters:
+    - name: reference
+      value: hg38
+  templates:
+  - name: main
+    inputs:
+      parameters:
+      - name: reference
+        enum: [hg19, hg38]
+    steps:
+    - - name: align
+        template: align
+        arguments:
+          parameters:
+          - name: reference
+            value: "{{inputs.parameters.reference}}"
+          - name: threads
+            value: "8"
+  - name: align
+    inputs:
+      parameters:
+      - name: reference
+        enum: [hg19, hg38]
+      - name: threads
+        type: int
+        schema:
+          minimum: 1
+          maximum: 16
+    container:
+      image: alpine:latest
+`
+
+func TestTypedParameters(t *testing.T) {
+	err := validate(typedParameters)
+	assert.NoError(t, err)
+	err = validate(strings.Replace(typedParameters, "value: hg38", "value: hg39", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.main.inputs.parameters.reference value 'hg39' is not one of the allowed values: hg19, hg38")
+	}
+	err = validate(strings.Replace(typedParameters, `value: "8"`, `value: "32"`, 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.main.steps[0].align templates.align.inputs.parameters.threads value does not conform to its schema: $: 32 is greater than the maximum of 16")
+	}
+	err = validate(strings.Replace(typedParameters, "type: int", "type: integer", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.align.inputs.parameters.threads.type 'integer' is invalid: must be string, int, number, bool or json")
+	}
+}
+
 var stepArtReferences = `
 apiVersion: argoproj.io/v1alpha1
 kind: Workflow
@@ -944,6 +1515,178 @@ func TestExitHandler(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+var stepExitHook = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: step-exit-hook-
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: lease
+        template: lease
+        onExit: release
+  - name: lease
+    script:
+      image: alpine:latest
+      command: [sh]
+      source: echo lease-1
+  - name: release
+    container:
+      image: alpine:latest
+      command: [sh, -c]
+      args: ["echo {{status}} {{outputs.result}}"]
+`
+
+var taskExitHookUnknownOutput = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: task-exit-hook-
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    dag:
+      tasks:
+      - name: lease
+        template: lease
+        onExit: release
+  - name: lease
+    container:
+      image: alpine:latest
+  - name: release
+    container:
+      image: alpine:latest
+      command: [sh, -c]
+      args: ["echo {{status}} {{outputs.parameters.lease}}"]
+`
+
+func TestExitHook(t *testing.T) {
+	// ensure the status and outputs of the step are available in its exit hook
+	err := validate(stepExitHook)
+	assert.NoError(t, err)
+
+	err = validate(taskExitHookUnknownOutput)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "templates.main.tasks.lease.onExit")
+		assert.Contains(t, err.Error(), "outputs.parameters.lease")
+	}
+}
+
+var suspendOutputValueFrom = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: suspend-outputs-
+spec:
+  entrypoint: approve
+  templates:
+  - name: approve
+    suspend: {}
+    outputs:
+      parameters:
+      - name: decision
+        valueFrom:
+          path: /tmp/decision
+`
+
+func TestSuspendOutputs(t *testing.T) {
+	err := validate(suspendOutputValueFrom)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "its value is supplied when resuming")
+	}
+	wf := unmarshalWf(suspendOutputValueFrom)
+	wf.Spec.Templates[0].Outputs.Parameters[0].ValueFrom = nil
+	err = ValidateWorkflow(wftmplGetter, wf, ValidateOpts{})
+	assert.NoError(t, err)
+}
+
+var retryResourceEscalation = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: retry-resource-escalation-
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    retryStrategy:
+      limit: 3
+      resourceEscalation:
+        factor: "1.5"
+        max:
+          memory: 4Gi
+    container:
+      image: docker/whalesay:latest
+`
+
+func TestResourceEscalation(t *testing.T) {
+	err := validate(retryResourceEscalation)
+	assert.NoError(t, err)
+
+	wf := unmarshalWf(retryResourceEscalation)
+	wf.Spec.Templates[0].RetryStrategy.ResourceEscalation.Factor = "0.5"
+	err = ValidateWorkflow(wftmplGetter, wf, ValidateOpts{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "factor must be a number greater than 1")
+	}
+	wf = unmarshalWf(retryResourceEscalation)
+	wf.Spec.Templates[0].RetryStrategy.ResourceEscalation.Max["nvidia.com/gpu"] = resource.MustParse("1")
+	err = ValidateWorkflow(wftmplGetter, wf, ValidateOpts{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "max.nvidia.com/gpu is not supported")
+	}
+}
+
+var approvalTemplate = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: approval-
+spec:
+  entrypoint: approve
+  templates:
+  - name: approve
+    approval:
+      users: [alice, bob]
+      required: 2
+      timeout: 3600
+      defaultDecision: Reject
+`
+
+func TestApprovalTemplate(t *testing.T) {
+	err := validate(approvalTemplate)
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		mutate func(approval *wfv1.ApprovalTemplate)
+		err    string
+	}{
+		{func(a *wfv1.ApprovalTemplate) { a.Required = pointer.Int32Ptr(3) }, "exceeds the number of allowed users"},
+		{func(a *wfv1.ApprovalTemplate) { a.Required = pointer.Int32Ptr(0) }, "required must be a positive integer"},
+		{func(a *wfv1.ApprovalTemplate) { a.Users = []string{"alice", "alice"} }, "is listed more than once"},
+		{func(a *wfv1.ApprovalTemplate) { a.Timeout = nil }, "defaultDecision requires a timeout"},
+		{func(a *wfv1.ApprovalTemplate) { a.DefaultDecision = "Maybe" }, "defaultDecision must be one of"},
+	} {
+		wf := unmarshalWf(approvalTemplate)
+		tc.mutate(wf.Spec.Templates[0].Approval)
+		err = ValidateWorkflow(wftmplGetter, wf, ValidateOpts{})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), tc.err)
+		}
+	}
+	// the groups of the approvers are not authenticated
+	wf := unmarshalWf(approvalTemplate)
+	wf.Spec.Templates[0].Approval.Groups = []string{"release-managers"}
+	err = ValidateWorkflow(wftmplGetter, wf, ValidateOpts{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "approval.groups is not supported")
+	}
+}
+
 var workflowWithPriority = `
 apiVersion: argoproj.io/v1alpha1
 kind: Workflow
@@ -1035,6 +1778,130 @@ func TestValidActiveDeadlineSeconds(t *testing.T) {
 	}
 }
 
+var templateTimeout = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: template-timeout-
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    timeout: 60
+    steps:
+    - - name: approve
+        template: approve
+  - name: approve
+    timeout: 3600
+    suspend: {}
+`
+
+func TestTemplateTimeout(t *testing.T) {
+	err := validate(templateTimeout)
+	assert.NoError(t, err)
+	err = validate(strings.Replace(templateTimeout, "timeout: 60", "timeout: 0", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.main.timeout must be a positive integer > 0")
+	}
+}
+
+var stepsRace = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: steps-race-
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    steps:
+    - - name: heuristic
+        template: solve
+        race: true
+      - name: exact
+        template: solve
+        race: true
+    - - name: report
+        template: report
+        arguments:
+          parameters:
+          - name: answer
+            value: "{{steps.exact.outputs.parameters.answer}}"
+  - name: solve
+    container:
+      image: alpine:latest
+    outputs:
+      parameters:
+      - name: answer
+        valueFrom:
+          path: /tmp/answer
+  - name: report
+    inputs:
+      parameters:
+      - name: answer
+    container:
+      image: alpine:latest
+`
+
+var dagRace = `
+apiVersion: argoproj.io/v1alpha1
+kind: Workflow
+metadata:
+  generateName: dag-race-
+spec:
+  entrypoint: main
+  templates:
+  - name: main
+    dag:
+      tasks:
+      - name: fetch
+        template: fetch
+        withItems: [us-east1, europe-west1]
+        race: true
+      - name: report
+        dependencies: [fetch]
+        template: report
+        arguments:
+          parameters:
+          - name: region
+            value: "{{tasks.fetch.outputs.parameters.region}}"
+  - name: fetch
+    container:
+      image: alpine:latest
+    outputs:
+      parameters:
+      - name: region
+        valueFrom:
+          path: /tmp/region
+  - name: report
+    inputs:
+      parameters:
+      - name: region
+    container:
+      image: alpine:latest
+`
+
+func TestRace(t *testing.T) {
+	err := validate(stepsRace)
+	assert.NoError(t, err)
+	err = validate(strings.Replace(stepsRace, "        template: solve\n        race: true\n", "        template: solve\n", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.main.steps[0].exact.race must be set on all the steps of the step group or on none")
+	}
+	err = validate(strings.Replace(stepsRace, "race: true\n", "race: true\n        failureTolerance: 1\n        withItems: [a]\n", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.main.steps[0].heuristic.race cannot be combined with failureTolerance")
+	}
+
+	// the outputs of a race are those of the winner, not a list
+	err = validate(dagRace)
+	assert.NoError(t, err)
+	err = validate(strings.Replace(dagRace, "        withItems: [us-east1, europe-west1]\n", "", 1))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "templates.main.tasks.fetch.race is only applicable to withItems, withParam, withSequence, withMatrix or withArtifact")
+	}
+}
+
 var leafWithParallelism = `
 apiVersion: argoproj.io/v1alpha1
 kind: Workflow